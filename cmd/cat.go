@@ -2,30 +2,79 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/ober/goasciinema/internal/asciicast"
 	"github.com/ober/goasciinema/internal/player"
 	"github.com/spf13/cobra"
 )
 
 var catCmd = &cobra.Command{
-	Use:   "cat <filename>",
+	Use:   "cat <filename...>",
 	Short: "Print full output of recorded session",
 	Long: `Print the full output of an asciicast recording.
 
 This outputs all the terminal output without any timing,
-useful for extracting the raw content of a recording.`,
-	Args: cobra.ExactArgs(1),
+useful for extracting the raw content of a recording. Several files, or
+the files listed in a --playlist, are printed one after another;
+--separator prints a "[m 00:00] <file name>" line before each.
+
+--type selects the event types to print, as a comma-separated list of
+o (output), i (input), m (markers), r (resizes) and x (exit status);
+--stdin adds input to the output. Output and input are printed as
+recorded, other events on their own line with their time. --plain strips
+escape sequences and control characters, leaving readable text.
+
+Example:
+  goasciinema cat demo.cast --type o,m --plain`,
 	RunE: runCat,
 }
 
+var (
+	catPlain     bool
+	catStdin     bool
+	catTypes     string
+	catPlaylist  string
+	catSeparator bool
+)
+
 func init() {
 	rootCmd.AddCommand(catCmd)
+	catCmd.Flags().BoolVar(&catPlain, "plain", false, "Strip escape sequences and control characters")
+	catCmd.Flags().BoolVar(&catStdin, "stdin", false, "Include input events")
+	catCmd.Flags().StringVar(&catPlaylist, "playlist", "", "Print the recordings listed in this file, one per line")
+	catCmd.Flags().BoolVar(&catSeparator, "separator", false, "Print a line with the file name before each recording")
+	catCmd.Flags().StringVar(&catTypes, "type", asciicast.EventTypeOutput, "Event types to print, e.g. o,i,m")
 }
 
 func runCat(cmd *cobra.Command, args []string) error {
-	filename := args[0]
+	filenames := args
+	switch {
+	case catPlaylist != "" && len(args) > 0:
+		return fmt.Errorf("give either filenames or --playlist, not both")
+	case catPlaylist != "":
+		var err error
+		if filenames, err = readPlaylist(catPlaylist); err != nil {
+			return err
+		}
+	case len(args) == 0:
+		return fmt.Errorf("a filename or --playlist is required")
+	}
+
+	var types []string
+	for _, t := range strings.Split(catTypes, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	if catStdin {
+		types = append(types, asciicast.EventTypeInput)
+	}
+	if len(types) == 0 {
+		return fmt.Errorf("--type needs at least one event type")
+	}
 
-	err := player.Cat(filename)
+	err := player.Cat(filenames, player.CatOptions{Types: types, Plain: catPlain, Separator: catSeparator})
 	if err != nil {
 		return fmt.Errorf("cat failed: %w", err)
 	}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/spf13/cobra"
+)
+
+var (
+	concatOutput      string
+	concatMarkers     bool
+	concatAllowResize bool
+)
+
+var concatCmd = &cobra.Command{
+	Use:   "concat <filename>... -o <output>",
+	Short: "Join recordings into one",
+	Long: `Join several recordings into one, each starting where the previous one
+ended. The header (title, env, and so on) is taken from the first file.
+
+All recordings must have the same terminal size, unless --allow-resize is
+given, in which case a resize event is inserted where the size changes.
+--markers adds a marker named after each file where it begins.
+
+Example:
+  goasciinema concat part1.cast part2.cast -o tutorial.cast --markers`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runConcat,
+}
+
+func init() {
+	rootCmd.AddCommand(concatCmd)
+	concatCmd.Flags().StringVarP(&concatOutput, "output", "o", "", "Output file (required)")
+	concatCmd.Flags().BoolVar(&concatMarkers, "markers", false, "Add a marker at the start of each file")
+	concatCmd.Flags().BoolVar(&concatAllowResize, "allow-resize", false, "Join recordings of different sizes with resize events")
+	concatCmd.MarkFlagRequired("output")
+}
+
+func runConcat(cmd *cobra.Command, args []string) error {
+	var merged *asciicast.Recording
+	for _, filename := range args {
+		header, events, err := loadEvents(filename)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		rec := &asciicast.Recording{Header: header, Events: events}
+
+		marker := ""
+		if concatMarkers {
+			marker = filepath.Base(filename)
+		}
+
+		if merged == nil {
+			merged = &asciicast.Recording{Header: header}
+			merged.Append(rec, marker)
+			continue
+		}
+
+		if cols, rows := merged.FinalSize(); !concatAllowResize && (header.Width != cols || header.Height != rows) {
+			return fmt.Errorf("%s is %dx%d but the recording before it ends at %dx%d (use --allow-resize to join them anyway)",
+				filename, header.Width, header.Height, cols, rows)
+		}
+		merged.Append(rec, marker)
+	}
+
+	if err := replaceRecording(concatOutput, merged.Header, merged.Events); err != nil {
+		return fmt.Errorf("failed to write %s: %w", concatOutput, err)
+	}
+	fmt.Printf("Joined %d recording(s) into %s, %s long\n", len(args), concatOutput, formatOffset(merged.Header.Duration))
+	return nil
+}
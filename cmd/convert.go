@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/spf13/cobra"
+)
+
+var convertTo string
+
+var convertCmd = &cobra.Command{
+	Use:   "convert <input> <output>",
+	Short: "Convert a recording between asciicast versions",
+	Long: `Rewrite a recording in another asciicast format version.
+
+Any readable recording (legacy, v1, v2, or v3) can be converted to v1, v2,
+or v3. Header metadata is kept where the target version has a place for
+it. v1 only records output, so input, marker, and resize events are
+dropped when converting to it.
+
+An output name ending in .zst is written compressed.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runConvert,
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+	convertCmd.Flags().StringVar(&convertTo, "to", "v2", "Target format version: v1, v2, or v3")
+}
+
+func runConvert(cmd *cobra.Command, args []string) error {
+	input, output := args[0], args[1]
+
+	var version int
+	var encode func(w io.Writer, header asciicast.Header, events []asciicast.Event) error
+	switch strings.TrimPrefix(convertTo, "v") {
+	case "1":
+		version, encode = asciicast.Version1, asciicast.EncodeV1
+	case "2":
+		version = asciicast.Version2
+	case "3":
+		version, encode = asciicast.Version3, asciicast.EncodeV3
+	default:
+		return fmt.Errorf("invalid --to value %q (expected v1, v2, or v3)", convertTo)
+	}
+
+	header, events, err := loadEvents(input)
+	if err != nil {
+		return err
+	}
+
+	if encode == nil {
+		err = replaceRecording(output, header, events)
+	} else {
+		err = replaceFile(output, func(tmpName string) error {
+			out, err := asciicast.Create(tmpName)
+			if err != nil {
+				return err
+			}
+			if err := encode(out, header, events); err != nil {
+				out.Close()
+				return err
+			}
+			return out.Close()
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	fmt.Printf("Converted %s to %s (asciicast v%d)\n", input, output, version)
+	if version == asciicast.Version1 {
+		if dropped := droppedForV1(events); dropped > 0 {
+			fmt.Printf("Dropped %d non-output event(s), which v1 cannot store\n", dropped)
+		}
+	}
+	return nil
+}
+
+// droppedForV1 counts the events a v1 recording has no place for
+func droppedForV1(events []asciicast.Event) int {
+	var dropped int
+	for _, event := range events {
+		if event.Type != asciicast.EventTypeOutput {
+			dropped++
+		}
+	}
+	return dropped
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/spf13/cobra"
+)
+
+var (
+	editFrom   float64
+	editTo     float64
+	editRanges []string
+	editSpeed  float64
+	editSpan   string
+	editLimit  float64
+)
+
+var editCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the timing of a recording",
+	Long: `Rewrite a recording into a new file with its timing edited.
+
+Each edit reads <input> and writes the result to <output>, leaving the
+input untouched.`,
+}
+
+var editTrimCmd = &cobra.Command{
+	Use:   "trim <input> <output>",
+	Short: "Keep only a time range of a recording",
+	Long: `Write a recording containing only the events between --from and --to
+seconds, with timestamps moved to start at zero.
+
+Example:
+  goasciinema edit trim demo.cast demo-trimmed.cast --from 4.5 --to 62`,
+	Args: cobra.ExactArgs(2),
+	RunE: runEditTrim,
+}
+
+var editCutCmd = &cobra.Command{
+	Use:   "cut <input> <output>",
+	Short: "Remove time ranges from a recording",
+	Long: `Write a recording with the given time ranges removed. Later events are
+moved back to close each gap, so playback continues seamlessly.
+
+Each --range is FROM-TO in seconds; give it more than once to remove
+several segments.
+
+Example:
+  goasciinema edit cut demo.cast demo-clean.cast --range 12.5-20 --range 41-47`,
+	Args: cobra.ExactArgs(2),
+	RunE: runEditCut,
+}
+
+var editSpeedCmd = &cobra.Command{
+	Use:   "speed <input> <output>",
+	Short: "Change the playback speed of a recording",
+	Long: `Write a recording whose timing is sped up or slowed down by --speed,
+so it plays at that pace everywhere. 2 plays twice as fast, 0.5 half as
+fast.
+
+With --range FROM-TO only that part of the recording is rescaled; the
+rest keeps its original pace.
+
+Example:
+  goasciinema edit speed demo.cast demo-fast.cast --speed 1.5
+  goasciinema edit speed demo.cast demo-fixed.cast --speed 4 --range 30-90`,
+	Args: cobra.ExactArgs(2),
+	RunE: runEditSpeed,
+}
+
+var editCompressIdleCmd = &cobra.Command{
+	Use:   "compress-idle <input> <output>",
+	Short: "Shorten long pauses in a recording",
+	Long: `Write a recording with every pause between events longer than --limit
+seconds shortened to --limit, like play --idle-time-limit but stored in
+the file, so it plays without the pauses everywhere.
+
+Example:
+  goasciinema edit compress-idle demo.cast demo-tight.cast --limit 2`,
+	Args: cobra.ExactArgs(2),
+	RunE: runEditCompressIdle,
+}
+
+func init() {
+	rootCmd.AddCommand(editCmd)
+	editCmd.AddCommand(editTrimCmd)
+	editCmd.AddCommand(editCutCmd)
+	editCmd.AddCommand(editSpeedCmd)
+	editCmd.AddCommand(editCompressIdleCmd)
+
+	editTrimCmd.Flags().Float64Var(&editFrom, "from", 0, "Start of the range to keep, in seconds")
+	editTrimCmd.Flags().Float64Var(&editTo, "to", 0, "End of the range to keep, in seconds (default: end of recording)")
+	editCutCmd.Flags().StringArrayVar(&editRanges, "range", nil, "Time range to remove, as FROM-TO in seconds (repeatable)")
+	editSpeedCmd.Flags().Float64Var(&editSpeed, "speed", 0, "Speed factor (e.g. 2 for twice as fast)")
+	editSpeedCmd.Flags().StringVar(&editSpan, "range", "", "Only change the speed within FROM-TO seconds")
+	editSpeedCmd.MarkFlagRequired("speed")
+	editCompressIdleCmd.Flags().Float64Var(&editLimit, "limit", 2, "Longest pause to keep, in seconds")
+}
+
+func runEditTrim(cmd *cobra.Command, args []string) error {
+	if editFrom < 0 || editTo < 0 {
+		return fmt.Errorf("--from and --to must not be negative")
+	}
+	if editTo > 0 && editTo <= editFrom {
+		return fmt.Errorf("--to must be after --from")
+	}
+
+	return editRecording(args[0], args[1], func(rec *asciicast.Recording) {
+		rec.Trim(editFrom, editTo)
+	})
+}
+
+func runEditCut(cmd *cobra.Command, args []string) error {
+	if len(editRanges) == 0 {
+		return fmt.Errorf("at least one --range is required")
+	}
+	var ranges []asciicast.TimeRange
+	for _, value := range editRanges {
+		rng, err := parseTimeRange(value)
+		if err != nil {
+			return err
+		}
+		ranges = append(ranges, rng)
+	}
+
+	return editRecording(args[0], args[1], func(rec *asciicast.Recording) {
+		rec.Cut(ranges)
+	})
+}
+
+func runEditSpeed(cmd *cobra.Command, args []string) error {
+	if editSpeed <= 0 {
+		return fmt.Errorf("--speed must be greater than zero")
+	}
+	var span *asciicast.TimeRange
+	if editSpan != "" {
+		rng, err := parseTimeRange(editSpan)
+		if err != nil {
+			return err
+		}
+		span = &rng
+	}
+
+	return editRecording(args[0], args[1], func(rec *asciicast.Recording) {
+		rec.Scale(1/editSpeed, span)
+	})
+}
+
+func runEditCompressIdle(cmd *cobra.Command, args []string) error {
+	if editLimit <= 0 {
+		return fmt.Errorf("--limit must be greater than zero")
+	}
+
+	return editRecording(args[0], args[1], func(rec *asciicast.Recording) {
+		removed := rec.CompressIdle(editLimit)
+		fmt.Printf("Removed %.1fs of idle time\n", removed)
+	})
+}
+
+// parseTimeRange parses a FROM-TO range in seconds
+func parseTimeRange(value string) (asciicast.TimeRange, error) {
+	fromStr, toStr, ok := strings.Cut(value, "-")
+	from, fromErr := strconv.ParseFloat(strings.TrimSpace(fromStr), 64)
+	to, toErr := strconv.ParseFloat(strings.TrimSpace(toStr), 64)
+	if !ok || fromErr != nil || toErr != nil || from < 0 || to <= from {
+		return asciicast.TimeRange{}, fmt.Errorf("invalid range %q (expected FROM-TO in seconds, e.g. 12.5-20)", value)
+	}
+	return asciicast.TimeRange{From: from, To: to}, nil
+}
+
+// editRecording loads input, applies edit, and writes the result to output
+func editRecording(input, output string, edit func(rec *asciicast.Recording)) error {
+	header, events, err := loadEvents(input)
+	if err != nil {
+		return err
+	}
+	rec := &asciicast.Recording{Header: header, Events: events}
+	before := len(rec.Events)
+
+	edit(rec)
+
+	if err := replaceRecording(output, rec.Header, rec.Events); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	fmt.Printf("Wrote %s: %d of %d event(s), %s long\n", output, len(rec.Events), before, formatOffset(rec.Header.Duration))
+	return nil
+}
@@ -0,0 +1,417 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/ober/goasciinema/internal/raster"
+	"github.com/ober/goasciinema/internal/sanitize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat        string
+	exportTiming        string
+	exportTheme         string
+	exportScale         int
+	exportFPS           int
+	exportSpeed         float64
+	exportIdleTimeLimit float64
+	exportLastFrame     float64
+	exportPlayerJS      string
+	exportPlayerCSS     string
+	exportTimestamps    string
+
+	exportIdleTimeLimitSet bool // --idle-time-limit was given
+)
+
+// defaultGIFIdleTimeLimit caps pauses in GIFs of recordings that don't set
+// an idle time limit
+const defaultGIFIdleTimeLimit = 5.0
+
+var exportCmd = &cobra.Command{
+	Use:   "export <filename> [output]",
+	Short: "Export a recording to another format",
+	Long: `Write a recording in a format for other tools. The output is written
+next to the recording, with the format's extension, unless given.
+
+Formats:
+  gif      an animated GIF, drawn with a built-in font in the recording's
+           theme or --theme; --fps, --speed and --idle-time-limit set the
+           timing
+  html     one HTML page that plays the recording offline; with --player-js
+           and --player-css (asciinema-player's release files) it embeds
+           asciinema-player, otherwise a basic built-in player
+  json     one JSON document: {"header": {...}, "events": [[time, type, data], ...]}
+  txt      the output as plain text, cleaned of escape sequences; with
+           --timestamps relative or wall, each line starts with the time it
+           was printed, from the start or by the clock
+  script   a typescript and timing log for scriptreplay; the timing log is
+           written to --timing (default: the output name with .timing)
+
+Example:
+  goasciinema export --format gif demo.cast --theme dracula --speed 1.5
+  goasciinema export --format html demo.cast --player-js asciinema-player.min.js --player-css asciinema-player.css
+  goasciinema export --format json demo.cast
+  goasciinema export --format script demo.cast
+  goasciinema export --format txt demo.cast --timestamps wall
+  scriptreplay --timing demo.timing demo.typescript`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runExport,
+}
+
+// exporter writes a recording to output in some format
+type exporter struct {
+	Ext    string
+	Export func(header asciicast.Header, events []asciicast.Event, output string) error
+}
+
+// exportFormats are the formats supported by export
+var exportFormats = map[string]exporter{
+	"gif":    {Ext: ".gif", Export: exportGIF},
+	"html":   {Ext: ".html", Export: exportHTML},
+	"json":   {Ext: ".json", Export: exportJSON},
+	"script": {Ext: ".typescript", Export: exportScript},
+	"txt":    {Ext: ".txt", Export: exportText},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVar(&exportFormat, "format", "", "Output format: "+strings.Join(exportFormatNames(), ", "))
+	exportCmd.Flags().StringVar(&exportTiming, "timing", "", "Timing log for --format script (default: output name with .timing)")
+	exportCmd.Flags().StringVar(&exportTheme, "theme", "", "Theme for --format gif: "+strings.Join(raster.ThemeNames(), ", ")+", or bg,fg,palette... in hex (default: the recording's, or "+raster.DefaultTheme+")")
+	exportCmd.Flags().IntVar(&exportScale, "scale", 2, "Font scale for --format gif; characters are 6x10 pixels at scale 1")
+	exportCmd.Flags().IntVar(&exportFPS, "fps", 30, fmt.Sprintf("Maximum frames per second for --format gif (up to %d)", raster.MaxFPS))
+	exportCmd.Flags().Float64Var(&exportSpeed, "speed", 1, "Playback speed for --format gif")
+	exportCmd.Flags().Float64Var(&exportIdleTimeLimit, "idle-time-limit", 0, fmt.Sprintf("Cap pauses at this many seconds for --format gif, 0 for none (default: the recording's, or %g)", defaultGIFIdleTimeLimit))
+	exportCmd.Flags().Float64Var(&exportLastFrame, "last-frame", 3, "Seconds to show the last frame for --format gif before it loops")
+	exportCmd.Flags().StringVar(&exportPlayerJS, "player-js", "", "asciinema-player JavaScript file to embed for --format html")
+	exportCmd.Flags().StringVar(&exportPlayerCSS, "player-css", "", "asciinema-player stylesheet to embed for --format html")
+	exportCmd.Flags().StringVar(&exportTimestamps, "timestamps", "", "Prefix lines for --format txt with their time: relative or wall")
+	exportCmd.MarkFlagRequired("format")
+}
+
+// exportFormatNames returns the supported export formats
+func exportFormatNames() []string {
+	var names []string
+	for name := range exportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	format, ok := exportFormats[exportFormat]
+	if !ok {
+		return fmt.Errorf("invalid --format value %q (expected %s)", exportFormat, strings.Join(exportFormatNames(), ", "))
+	}
+	exportIdleTimeLimitSet = cmd.Flags().Changed("idle-time-limit")
+
+	input := args[0]
+	base := strings.TrimSuffix(input, asciicast.ZstdExt)
+	output := strings.TrimSuffix(base, filepath.Ext(base)) + format.Ext
+	if len(args) > 1 {
+		output = args[1]
+	}
+	if output == input {
+		return fmt.Errorf("output would overwrite %s; give an output file", input)
+	}
+
+	header, events, err := loadEvents(input)
+	if err != nil {
+		return err
+	}
+	if err := format.Export(header, events, output); err != nil {
+		return fmt.Errorf("failed to export %s: %w", input, err)
+	}
+	fmt.Printf("Exported %s to %s\n", input, output)
+	return nil
+}
+
+// exportScript writes a typescript to output and its timing log beside it
+func exportScript(header asciicast.Header, events []asciicast.Event, output string) error {
+	timing := exportTiming
+	if timing == "" {
+		timing = strings.TrimSuffix(output, filepath.Ext(output)) + ".timing"
+	}
+	if timing == output {
+		return fmt.Errorf("the timing log would overwrite %s; give --timing", output)
+	}
+
+	ts, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer ts.Close()
+	tm, err := os.Create(timing)
+	if err != nil {
+		return err
+	}
+	defer tm.Close()
+
+	if err := asciicast.EncodeScript(ts, tm, header, events); err != nil {
+		return err
+	}
+	if err := ts.Close(); err != nil {
+		return err
+	}
+	return tm.Close()
+}
+
+// exportJSON writes the recording as a single JSON document
+func exportJSON(header asciicast.Header, events []asciicast.Event, output string) error {
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := asciicast.EncodeJSON(file, header, events); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// exportGIF writes the recording as an animated GIF
+func exportGIF(header asciicast.Header, events []asciicast.Event, output string) error {
+	if exportSpeed <= 0 {
+		return fmt.Errorf("--speed must be positive")
+	}
+	if exportFPS < 1 || exportFPS > raster.MaxFPS {
+		return fmt.Errorf("--fps must be between 1 and %d", raster.MaxFPS)
+	}
+	if exportScale < 1 {
+		return fmt.Errorf("--scale must be at least 1")
+	}
+
+	theme, err := pickTheme(exportTheme, header)
+	if err != nil {
+		return err
+	}
+
+	idleTimeLimit := exportIdleTimeLimit
+	if !exportIdleTimeLimitSet {
+		idleTimeLimit = header.IdleTimeLimit
+		if idleTimeLimit <= 0 {
+			idleTimeLimit = defaultGIFIdleTimeLimit
+		}
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	renderer := raster.NewRenderer(theme, exportScale)
+	err = renderer.WriteGIF(file, header, events, raster.GIFOptions{
+		FPS:           exportFPS,
+		Speed:         exportSpeed,
+		IdleTimeLimit: idleTimeLimit,
+		LastFrame:     exportLastFrame,
+	})
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// pickTheme returns the named theme, or if name is empty the recording's
+// own theme, falling back to the default
+func pickTheme(name string, header asciicast.Header) (raster.Theme, error) {
+	if name == "" {
+		if theme, ok := raster.HeaderTheme(header.Theme); ok {
+			return theme, nil
+		}
+		name = raster.DefaultTheme
+	}
+	return raster.ParseTheme(name)
+}
+
+// exportHTML writes the recording as a self-contained HTML page that plays
+// it, embedding asciinema-player if its files are given
+func exportHTML(header asciicast.Header, events []asciicast.Event, output string) error {
+	if (exportPlayerJS == "") != (exportPlayerCSS == "") {
+		return fmt.Errorf("--player-js and --player-css must be given together")
+	}
+
+	// The page carries the recording as asciicast v2 text
+	var cast bytes.Buffer
+	header.Version = asciicast.Version2
+	writer, err := asciicast.NewWriterTo(&cast, header)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := writer.WriteEvent(event); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	// Marshalling escapes <, > and &, so the data can't end the script
+	data, err := json.Marshal(cast.String())
+	if err != nil {
+		return err
+	}
+
+	title := header.Title
+	if title == "" {
+		title = filepath.Base(output)
+	}
+	var page string
+	if exportPlayerJS != "" {
+		js, err := os.ReadFile(exportPlayerJS)
+		if err != nil {
+			return fmt.Errorf("failed to read --player-js: %w", err)
+		}
+		css, err := os.ReadFile(exportPlayerCSS)
+		if err != nil {
+			return fmt.Errorf("failed to read --player-css: %w", err)
+		}
+		page = fmt.Sprintf(playerPage, html.EscapeString(title), css, scriptSafe(js), data)
+	} else {
+		page = fmt.Sprintf(basicPlayerPage, html.EscapeString(title), data)
+	}
+	return os.WriteFile(output, []byte(page), 0644)
+}
+
+// scriptSafe keeps embedded JavaScript from closing its script element
+func scriptSafe(js []byte) string {
+	return strings.ReplaceAll(string(js), "</script", "<\\/script")
+}
+
+// playerPage embeds asciinema-player: title, CSS, JavaScript, cast data
+const playerPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+%s
+</style>
+</head>
+<body>
+<div id="player"></div>
+<script>
+%s
+</script>
+<script>
+AsciinemaPlayer.create({data: %s}, document.getElementById("player"), {fit: "width"});
+</script>
+</body>
+</html>
+`
+
+// basicPlayerPage replays a recording's output with its timing, without
+// emulating the terminal: title, cast data
+const basicPlayerPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>body{background:#111;color:#ddd}pre{font-family:monospace;white-space:pre-wrap}button{margin:4px 0}</style>
+</head>
+<body>
+<button id="toggle">Pause</button>
+<pre id="screen"></pre>
+<script>
+var lines = %s.split("\n").filter(function(l) { return l !== ""; });
+var header = JSON.parse(lines[0]);
+var events = lines.slice(1).map(function(l) { return JSON.parse(l); });
+var screen = document.getElementById("screen");
+var toggle = document.getElementById("toggle");
+var ansi = /\x1b\[[0-9;?]*[ -\/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-_]|\r/g;
+var next = 0, prev = 0, timer = null;
+function step() {
+  var ev = events[next++];
+  if (ev && ev[1] === "o") {
+    screen.textContent += ev[2].replace(ansi, "");
+    window.scrollTo(0, document.body.scrollHeight);
+  }
+  schedule();
+}
+function schedule() {
+  if (next >= events.length) { timer = null; toggle.textContent = "Replay"; return; }
+  var delay = events[next][0] - prev;
+  if (header.idle_time_limit && delay > header.idle_time_limit) { delay = header.idle_time_limit; }
+  prev = events[next][0];
+  timer = setTimeout(step, Math.max(delay, 0) * 1000);
+}
+toggle.onclick = function() {
+  if (timer) { clearTimeout(timer); timer = null; toggle.textContent = "Play"; return; }
+  if (next >= events.length) { next = 0; prev = 0; screen.textContent = ""; }
+  toggle.textContent = "Pause";
+  step();
+};
+schedule();
+</script>
+</body>
+</html>
+`
+
+// exportText writes the output as plain text, each line optionally
+// prefixed with the time it started
+func exportText(header asciicast.Header, events []asciicast.Event, output string) error {
+	var stamp func(t float64) string
+	switch exportTimestamps {
+	case "":
+	case "relative":
+		stamp = func(t float64) string { return "[" + formatOffset(t) + "] " }
+	case "wall":
+		if header.Timestamp <= 0 {
+			return fmt.Errorf("the recording has no start time for --timestamps wall")
+		}
+		start := time.Unix(header.Timestamp, 0)
+		stamp = func(t float64) string {
+			at := start.Add(time.Duration(t * float64(time.Second)))
+			return "[" + displayTime(at, header.Timezone).Format("2006-01-02 15:04:05") + "] "
+		}
+	default:
+		return fmt.Errorf("invalid --timestamps value %q (expected relative or wall)", exportTimestamps)
+	}
+
+	var out strings.Builder
+	var line strings.Builder
+	var lineTime float64
+	emit := func() {
+		text := strings.TrimRight(sanitize.StripANSI(line.String()), " \t")
+		line.Reset()
+		if text == "" {
+			return
+		}
+		if stamp != nil {
+			out.WriteString(stamp(lineTime))
+		}
+		out.WriteString(text)
+		out.WriteByte('\n')
+	}
+	for _, event := range events {
+		if event.Type != asciicast.EventTypeOutput {
+			continue
+		}
+		data := event.Data
+		for data != "" {
+			if line.Len() == 0 {
+				lineTime = event.Time
+			}
+			text, rest, found := strings.Cut(data, "\n")
+			line.WriteString(text)
+			data = rest
+			if found {
+				emit()
+			}
+		}
+	}
+	emit()
+
+	return os.WriteFile(output, []byte(out.String()), 0644)
+}
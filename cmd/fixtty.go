@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ober/goasciinema/internal/tty"
+	"github.com/spf13/cobra"
+)
+
+var fixTTYCmd = &cobra.Command{
+	Use:   "fix-tty",
+	Short: "Restore a terminal left in raw mode",
+	Long: `Restore a terminal left unusable by a crashed recorder or player.
+
+Resets line discipline settings (like 'stty sane') and sends escape
+sequences that leave the alternate screen, show the cursor, and turn off
+mouse reporting. If the terminal doesn't echo, type the command blind
+and press Ctrl+J instead of Enter.`,
+	Args: cobra.NoArgs,
+	RunE: runFixTTY,
+}
+
+func init() {
+	rootCmd.AddCommand(fixTTYCmd)
+}
+
+func runFixTTY(cmd *cobra.Command, args []string) error {
+	if err := tty.Fix(); err != nil {
+		return fmt.Errorf("failed to reset terminal: %w", err)
+	}
+	return nil
+}
+
+// superviseTerminal runs the current command under a supervisor process
+// that restores the terminal if the command dies while the terminal is in
+// raw mode. It returns the exit code and true if the command was run by
+// the supervisor, in which case the caller should exit with that code.
+func superviseTerminal() (int, bool) {
+	if !tty.ShouldSupervise() {
+		return 0, false
+	}
+	code, err := tty.Supervise()
+	if err != nil {
+		// Fall back to running unsupervised
+		return 0, false
+	}
+	return code, true
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	frameAt    string
+	frameColor bool
+)
+
+var frameCmd = &cobra.Command{
+	Use:   "frame <filename>",
+	Short: "Print the terminal screen at a point in a recording",
+	Long: `Replay a recording through a virtual terminal up to a point in time and
+print the screen as it was then, one line per row. --at takes seconds,
+[HH:]MM:SS or a duration like 1m30s, and defaults to the end.
+
+With --color the screen keeps its colors and text attributes, as ANSI
+escape sequences.
+
+Example:
+  goasciinema frame demo.cast --at 35s --color`,
+	Args: cobra.ExactArgs(1),
+	RunE: runFrame,
+}
+
+func init() {
+	rootCmd.AddCommand(frameCmd)
+	frameCmd.Flags().StringVar(&frameAt, "at", "", "Time of the frame (default: the end of the recording)")
+	frameCmd.Flags().BoolVar(&frameColor, "color", false, "Keep colors and attributes as ANSI escape sequences")
+}
+
+func runFrame(cmd *cobra.Command, args []string) error {
+	at := -1.0
+	if frameAt != "" {
+		var err error
+		if at, err = parseOffset(frameAt); err != nil {
+			return fmt.Errorf("invalid --at value: %w", err)
+		}
+	}
+
+	term, _, err := emulate(args[0], at)
+	if err != nil {
+		return fmt.Errorf("frame failed: %w", err)
+	}
+
+	width, _ := term.Size()
+	var out strings.Builder
+	for _, l := range term.Lines() {
+		if frameColor {
+			out.WriteString(l.ANSI(width))
+		} else {
+			out.WriteString(l.Text())
+		}
+		out.WriteByte('\n')
+	}
+	fmt.Print(out.String())
+	return nil
+}
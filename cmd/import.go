@@ -0,0 +1,460 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ober/goasciinema/internal/api"
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/ober/goasciinema/internal/config"
+	"github.com/ober/goasciinema/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importFromServer bool
+	importServerDump string
+	importDir        string
+	importFormat     string
+	importCols       int
+	importRows       int
+	importTiming     string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import [input] [output]",
+	Short: "Import recordings into the local database",
+	Long: `Import recordings from an external source and index them into
+the local SQLite database.
+
+With --from-server, recordings associated with this machine's install ID
+are listed on the configured server, downloaded, and processed.
+
+With --server-dump, a tar archive (optionally gzipped) exported from an
+asciinema-server instance is unpacked. Cast files are taken from the
+uploads tree (for example asciicast/file/<id>/<name>.cast), and titles and
+creation times are applied from an asciicasts.json metadata file when the
+archive contains one.
+
+With --format, a recording made by another tool is converted to asciicast
+v2 and indexed. It is written to output, or next to the input with a .cast
+extension. Formats:
+  ttyrec   ttyrec/ttyplay binary recordings; the terminal size is not
+           stored, so it defaults to 80x24 unless --cols/--rows are given
+  script   a util-linux script typescript, with the log it wrote with
+           --timing given as --timing (classic or advanced format)
+
+Example:
+  goasciinema import --format ttyrec session.tty session.cast
+  goasciinema import --format script typescript --timing timing.txt`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: runImport,
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().BoolVar(&importFromServer, "from-server", false, "Import recordings uploaded to the configured server")
+	importCmd.Flags().StringVar(&importServerDump, "server-dump", "", "Import recordings from an asciinema-server export archive")
+	importCmd.Flags().StringVar(&importDir, "dir", "", "Directory to save downloaded recordings (default: database directory)")
+	importCmd.Flags().StringVar(&importFormat, "format", "", "Convert and import a recording in another format: ttyrec or script")
+	importCmd.Flags().StringVar(&importTiming, "timing", "", "Timing log of a script typescript (--format script)")
+	importCmd.Flags().IntVar(&importCols, "cols", 0, "Terminal columns of the imported recording")
+	importCmd.Flags().IntVar(&importRows, "rows", 0, "Terminal rows of the imported recording")
+	importCmd.Flags().BoolVarP(&processForce, "force", "f", false, "Force reprocessing of already processed files")
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	if importFormat != "" {
+		if len(args) == 0 {
+			return fmt.Errorf("--format requires an input file")
+		}
+		return importFile(importFormat, args)
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("importing a file requires --format")
+	}
+	if !importFromServer && importServerDump == "" {
+		return fmt.Errorf("no import source given (use --from-server, --server-dump or --format)")
+	}
+
+	dir := importDir
+	if dir == "" {
+		dir = filepath.Dir(GetDefaultDatabasePath())
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Open database
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if importServerDump != "" {
+		return importDump(db, importServerDump, dir)
+	}
+	return importFromServerRecordings(db, dir)
+}
+
+// importFile converts a recording in another tool's format to asciicast v2
+// and processes it
+func importFile(format string, args []string) error {
+	input := args[0]
+	output := strings.TrimSuffix(input, filepath.Ext(input)) + ".cast"
+	if len(args) > 1 {
+		output = args[1]
+	}
+	if output == input {
+		return fmt.Errorf("output would overwrite %s; give an output file", input)
+	}
+
+	var header asciicast.Header
+	var events []asciicast.Event
+	switch format {
+	case "ttyrec":
+		f, err := os.Open(input)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		header, events, err = asciicast.ReadTtyrec(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", input, err)
+		}
+	case "script":
+		if importTiming == "" {
+			return fmt.Errorf("--format script requires the timing log as --timing")
+		}
+		var err error
+		header, events, err = readScript(input, importTiming)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --format value %q (expected ttyrec or script)", format)
+	}
+
+	if importCols > 0 {
+		header.Width = importCols
+	}
+	if importRows > 0 {
+		header.Height = importRows
+	}
+	if n := len(events); n > 0 && header.Duration == 0 {
+		header.Duration = events[n-1].Time
+	}
+	if err := replaceRecording(output, header, events); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	fmt.Printf("Converted %s to %s: %d event(s), %s long\n", input, output, len(events), formatOffset(header.Duration))
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	wasProcessed, err := processFile(db, output)
+	if err != nil {
+		return fmt.Errorf("failed to process %s: %w", output, err)
+	}
+	if wasProcessed {
+		fmt.Printf("Imported: %s\n", filepath.Base(output))
+	}
+	return nil
+}
+
+// readScript reads a typescript and its timing log
+func readScript(typescript, timing string) (asciicast.Header, []asciicast.Event, error) {
+	ts, err := os.Open(typescript)
+	if err != nil {
+		return asciicast.Header{}, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer ts.Close()
+	tm, err := os.Open(timing)
+	if err != nil {
+		return asciicast.Header{}, nil, fmt.Errorf("failed to open timing log: %w", err)
+	}
+	defer tm.Close()
+
+	header, events, err := asciicast.ReadScript(ts, tm)
+	if err != nil {
+		return header, nil, fmt.Errorf("failed to read %s: %w", typescript, err)
+	}
+	return header, events, nil
+}
+
+// importFromServerRecordings downloads and processes the recordings listed
+// for this install ID on the configured server
+func importFromServerRecordings(db *database.DB, dir string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	installID, err := cfg.GetInstallID()
+	if err != nil {
+		return fmt.Errorf("failed to get install ID: %w", err)
+	}
+
+	client := api.NewClient(cfg.API.URL, installID)
+
+	recordings, err := client.ListRecordings()
+	if err != nil {
+		return fmt.Errorf("failed to list recordings: %w", err)
+	}
+
+	if len(recordings) == 0 {
+		fmt.Println("No recordings found on server.")
+		return nil
+	}
+
+	var downloaded, processed, skipped int
+	for _, rec := range recordings {
+		name, err := importedCastName(rec.ID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping recording: %v\n", err)
+			continue
+		}
+		dest := filepath.Join(dir, name)
+
+		if _, err := os.Stat(dest); err != nil {
+			if err := client.Download(rec, dest); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to download %s: %v\n", rec.ID, err)
+				continue
+			}
+			downloaded++
+		}
+
+		wasProcessed, err := processFile(db, dest)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to process %s: %v\n", dest, err)
+			continue
+		}
+		if wasProcessed {
+			processed++
+			fmt.Printf("Imported: %s\n", filepath.Base(dest))
+		} else {
+			skipped++
+		}
+	}
+
+	fmt.Printf("\nSummary: %d downloaded, %d processed, %d skipped\n", downloaded, processed, skipped)
+	return nil
+}
+
+// importedCastName returns the file name for an imported recording. IDs
+// come from the server or the archive, so only letters, digits, '-' and
+// '_' are kept, which keeps the file inside the import directory.
+func importedCastName(id string) (string, error) {
+	clean := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return -1
+	}, filepath.Base(id))
+	if clean == "" {
+		return "", fmt.Errorf("invalid recording ID %q", id)
+	}
+	return fmt.Sprintf("asciinema-%s.cast", clean), nil
+}
+
+// importDump unpacks an asciinema-server export archive into dir, applies
+// the archive's metadata to each cast, and processes the results
+func importDump(db *database.DB, archive, dir string) error {
+	casts, metadata, err := extractDump(archive, dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, tmp := range casts {
+			os.Remove(tmp)
+		}
+	}()
+
+	if len(casts) == 0 {
+		fmt.Println("No recordings found in dump.")
+		return nil
+	}
+
+	ids := make([]string, 0, len(casts))
+	for id := range casts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var converted, processed, skipped int
+	for _, id := range ids {
+		tmp := casts[id]
+		name, err := importedCastName(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping recording: %v\n", err)
+			continue
+		}
+		dest := filepath.Join(dir, name)
+
+		if _, err := os.Stat(dest); err != nil {
+			if err := convertDumpCast(tmp, dest, metadata[id]); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to convert %s: %v\n", id, err)
+				continue
+			}
+			converted++
+		}
+
+		wasProcessed, err := processFile(db, dest)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to process %s: %v\n", dest, err)
+			continue
+		}
+		if wasProcessed {
+			processed++
+			fmt.Printf("Imported: %s\n", filepath.Base(dest))
+		} else {
+			skipped++
+		}
+	}
+
+	fmt.Printf("\nSummary: %d converted, %d processed, %d skipped\n", converted, processed, skipped)
+	return nil
+}
+
+// extractDump copies every cast in the archive to a temporary file in dir
+// and returns them keyed by recording ID, along with any metadata found
+func extractDump(archive, dir string) (map[string]string, map[string]api.RemoteRecording, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open dump: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	if magic, _ := r.(*bufio.Reader).Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decompress dump: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	casts := make(map[string]string)
+	metadata := make(map[string]api.RemoteRecording)
+	fail := func(err error) (map[string]string, map[string]api.RemoteRecording, error) {
+		for _, tmp := range casts {
+			os.Remove(tmp)
+		}
+		return nil, nil, err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fail(fmt.Errorf("failed to read dump: %w", err))
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		switch {
+		case path.Base(name) == "asciicasts.json":
+			var recordings []api.RemoteRecording
+			if err := json.NewDecoder(tr).Decode(&recordings); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to parse %s: %v\n", name, err)
+				continue
+			}
+			for _, rec := range recordings {
+				metadata[rec.ID] = rec
+			}
+		case strings.HasSuffix(name, ".cast"):
+			id := dumpRecordingID(name)
+			if _, seen := casts[id]; seen {
+				continue
+			}
+			tmp, err := os.CreateTemp(dir, ".import-*.cast")
+			if err != nil {
+				return fail(fmt.Errorf("failed to create temporary file: %w", err))
+			}
+			_, err = io.Copy(tmp, tr)
+			tmp.Close()
+			if err != nil {
+				os.Remove(tmp.Name())
+				return fail(fmt.Errorf("failed to extract %s: %w", name, err))
+			}
+			casts[id] = tmp.Name()
+		}
+	}
+
+	return casts, metadata, nil
+}
+
+// dumpRecordingID derives a recording ID from a path in the dump. The
+// server stores uploads as <kind>/file/<id>/<name>, so a numeric parent
+// directory wins over the file name
+func dumpRecordingID(name string) string {
+	parent := path.Base(path.Dir(name))
+	if _, err := strconv.Atoi(parent); err == nil {
+		return parent
+	}
+	return strings.TrimSuffix(path.Base(name), ".cast")
+}
+
+// convertDumpCast writes src to dest as asciicast v2 with the server's
+// title and creation time filled into the header
+func convertDumpCast(src, dest string, meta api.RemoteRecording) error {
+	reader, err := asciicast.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer reader.Close()
+
+	header := reader.Header
+	header.Version = asciicast.Version2
+	if header.Title == "" {
+		header.Title = meta.Title
+	}
+	if header.Timestamp == 0 && meta.CreatedAt != "" {
+		if t, err := time.Parse(time.RFC3339, meta.CreatedAt); err == nil {
+			header.Timestamp = t.Unix()
+		}
+	}
+
+	writer, err := asciicast.NewWriter(dest, header, false)
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := reader.ReadEvent()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			writer.Close()
+			os.Remove(dest)
+			return fmt.Errorf("failed to read event: %w", err)
+		}
+		if err := writer.WriteEvent(*event); err != nil {
+			writer.Close()
+			os.Remove(dest)
+			return err
+		}
+	}
+	return writer.Close()
+}
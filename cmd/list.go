@@ -2,40 +2,79 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/ober/goasciinema/internal/database"
 	"github.com/spf13/cobra"
 )
 
-var listDatabase string
+var (
+	listFile    string
+	listSeries  string
+	listPreview bool
+)
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List processed sessions",
-	Long:  `List all processed asciinema sessions stored in the database.`,
-	RunE:  runList,
+	Long: `List all processed asciinema sessions stored in the database.
+
+With --file, recordings in the given directory (or a single file) are
+read directly, without a database.
+
+With --series, only the parts of that series are listed, in recording
+order, followed by the series totals.
+
+With --preview, the first command (or first line of output) of each
+session is shown, which identifies sessions with timestamp-only names.
+Sessions processed before previews were stored need 'process --force'.`,
+	RunE: runList,
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
-	listCmd.Flags().StringVarP(&listDatabase, "database", "d", "", "SQLite database file (default: from ~/.goasciinema or ~/console-logs/asciinema_logs.db)")
+	listCmd.Flags().StringVar(&listFile, "file", "", "List recordings in a directory or file instead of the database")
+	listCmd.Flags().StringVar(&listSeries, "series", "", "Only list recordings in this series")
+	listCmd.Flags().BoolVar(&listPreview, "preview", false, "Show the first command or line of output of each session")
+	addTimezoneFlags(listCmd)
 }
 
 func runList(cmd *cobra.Command, args []string) error {
-	// Use config default if no database specified
-	dbPath := listDatabase
-	if dbPath == "" {
-		dbPath = GetDefaultDatabasePath()
+	if err := resolveDisplayZone(); err != nil {
+		return err
+	}
+
+	if listFile != "" {
+		scanned, err := scanSessions(listFile)
+		if err != nil {
+			return err
+		}
+		sessions := scanned.Sessions
+		if listSeries != "" {
+			sessions = filterSeries(sessions, listSeries)
+		}
+		if len(sessions) == 0 {
+			fmt.Printf("No recordings found in %s\n", listFile)
+			return nil
+		}
+		printSessions(sessions)
+		printSeriesTotals(sessions)
+		return nil
 	}
 
 	// Open database
-	db, err := database.Open(dbPath)
+	db, err := openDatabase()
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
 	defer db.Close()
 
-	sessions, err := db.ListSessions()
+	var sessions []database.SessionInfo
+	if listSeries != "" {
+		sessions, err = db.ListSeries(listSeries)
+	} else {
+		sessions, err = db.ListSessions()
+	}
 	if err != nil {
 		return fmt.Errorf("failed to list sessions: %w", err)
 	}
@@ -45,20 +84,85 @@ func runList(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	printSessions(sessions)
+	printSeriesTotals(sessions)
+	return nil
+}
+
+// filterSeries returns the sessions belonging to series
+func filterSeries(sessions []database.SessionInfo, series string) []database.SessionInfo {
+	var out []database.SessionInfo
+	for _, s := range sessions {
+		if s.Series == series {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// printSeriesTotals summarizes a series as one logical recording
+func printSeriesTotals(sessions []database.SessionInfo) {
+	if listSeries == "" {
+		return
+	}
+	var duration, idleDuration float64
+	var markers int
+	for _, s := range sessions {
+		duration += s.Duration
+		idleDuration += s.IdleDuration
+		markers += s.Markers
+	}
+	fmt.Printf("\nSeries %s: %d part(s), %s (%s idle-capped), %d marker(s)\n",
+		listSeries, len(sessions), formatOffset(duration), formatOffset(idleDuration), markers)
+}
+
+// printSessions prints sessions as a table, with a preview column when
+// --preview is set
+func printSessions(sessions []database.SessionInfo) {
 	// Print header
-	fmt.Printf("%-35s %-20s %-10s %-10s\n", "Filename", "Session Date", "Size", "Chars")
-	fmt.Println(repeatString("=", 80))
+	header := fmt.Sprintf("%-35s %-26s %-10s %-10s %-19s %-7s", "Filename", "Session Date", "Size", "Chars", "Duration (idle)", "Markers")
+	width := 112
+	if listPreview {
+		header += " Preview"
+		width += 1 + previewWidth
+	}
+	fmt.Println(header)
+	fmt.Println(repeatString("=", width))
 
 	for _, s := range sessions {
-		fmt.Printf("%-35s %-20s %-10s %-10d\n",
+		row := fmt.Sprintf("%-35s %-26s %-10s %-10d %-19s %-7d",
 			truncateString(s.Filename, 35),
-			s.SessionDate,
-			s.Dimensions,
+			formatSessionDate(s.Time, s.Timezone),
+			formatDimensions(s.Width, s.Height),
 			s.ContentSize,
+			fmt.Sprintf("%s (%s)", formatOffset(s.Duration), formatOffset(s.IdleDuration)),
+			s.Markers,
 		)
+		if listPreview {
+			row += " " + truncateString(s.Preview, previewWidth)
+		}
+		fmt.Println(row)
 	}
+}
 
-	return nil
+// previewWidth is the width of the --preview column
+const previewWidth = 50
+
+// formatSessionDate formats a session time, recorded in zone tz, for
+// display with its zone
+func formatSessionDate(t time.Time, tz string) string {
+	if t.IsZero() {
+		return "Unknown"
+	}
+	return displayTime(t, tz).Format("2006-01-02 15:04:05 MST")
+}
+
+// formatDimensions formats a terminal size for display
+func formatDimensions(width, height int) string {
+	if width == 0 || height == 0 {
+		return "Unknown"
+	}
+	return fmt.Sprintf("%dx%d", width, height)
 }
 
 func repeatString(s string, count int) string {
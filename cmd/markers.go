@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/spf13/cobra"
+)
+
+var markersOutput string
+
+var markersCmd = &cobra.Command{
+	Use:   "markers",
+	Short: "Manage markers in a recording",
+	Long: `List, add and remove the marker events of a cast file.
+
+Markers label points in a recording, such as chapters. Unlike notes, they
+are stored in the cast file itself.`,
+}
+
+var markersListCmd = &cobra.Command{
+	Use:   "list <filename>",
+	Short: "List the markers in a recording",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runMarkersList,
+}
+
+var markersAddCmd = &cobra.Command{
+	Use:   "add <filename> <time> <label>",
+	Short: "Add a marker to a recording",
+	Long: `Add a marker at the given time, in seconds or as [HH:]MM:SS.
+
+Example:
+  goasciinema markers add demo.cast 42.5 "deploy step"`,
+	Args: cobra.ExactArgs(3),
+	RunE: runMarkersAdd,
+}
+
+var markersRmCmd = &cobra.Command{
+	Use:   "rm <filename> <number>",
+	Short: "Remove a marker from a recording",
+	Long: `Remove a marker by its number, as shown by "markers list".
+
+Example:
+  goasciinema markers rm demo.cast 2`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMarkersRm,
+}
+
+func init() {
+	rootCmd.AddCommand(markersCmd)
+	markersCmd.AddCommand(markersListCmd)
+	markersCmd.AddCommand(markersAddCmd)
+	markersCmd.AddCommand(markersRmCmd)
+
+	for _, c := range []*cobra.Command{markersAddCmd, markersRmCmd} {
+		c.Flags().StringVarP(&markersOutput, "output", "o", "", "Output file (default: rewrite in place)")
+	}
+}
+
+func runMarkersList(cmd *cobra.Command, args []string) error {
+	header, events, err := loadEvents(args[0])
+	if err != nil {
+		return err
+	}
+	rec := &asciicast.Recording{Header: header, Events: events}
+
+	markers := rec.Markers()
+	if len(markers) == 0 {
+		fmt.Printf("No markers in %s\n", filepath.Base(args[0]))
+		return nil
+	}
+	for _, m := range markers {
+		fmt.Printf("%3d  [%s] %10.3f  %s\n", m.Index+1, formatOffset(m.Time), m.Time, m.Label)
+	}
+	return nil
+}
+
+func runMarkersAdd(cmd *cobra.Command, args []string) error {
+	filename, label := args[0], args[2]
+	at, err := parseOffset(args[1])
+	if err != nil {
+		return err
+	}
+
+	return rewriteMarkers(filename, func(rec *asciicast.Recording) error {
+		rec.AddMarker(at, label)
+		fmt.Printf("Added marker %q at %s\n", label, formatOffset(at))
+		return nil
+	})
+}
+
+func runMarkersRm(cmd *cobra.Command, args []string) error {
+	filename := args[0]
+	number, err := strconv.Atoi(args[1])
+	if err != nil || number < 1 {
+		return fmt.Errorf("invalid marker number %q (see markers list)", args[1])
+	}
+
+	return rewriteMarkers(filename, func(rec *asciicast.Recording) error {
+		m, ok := rec.RemoveMarker(number - 1)
+		if !ok {
+			return fmt.Errorf("%s has no marker %d", filepath.Base(filename), number)
+		}
+		fmt.Printf("Removed marker %q at %s\n", m.Label, formatOffset(m.Time))
+		return nil
+	})
+}
+
+// rewriteMarkers loads filename, applies change, and writes it back, or
+// to --output
+func rewriteMarkers(filename string, change func(rec *asciicast.Recording) error) error {
+	header, events, err := loadEvents(filename)
+	if err != nil {
+		return err
+	}
+	rec := &asciicast.Recording{Header: header, Events: events}
+	if err := change(rec); err != nil {
+		return err
+	}
+
+	output := markersOutput
+	if output == "" {
+		output = filename
+	}
+	return replaceRecording(output, rec.Header, rec.Events)
+}
+
+// parseOffset parses a time offset given in seconds, as [HH:]MM:SS, or as
+// a duration such as 1m30s
+func parseOffset(value string) (float64, error) {
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d.Seconds(), nil
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q (expected seconds, [HH:]MM:SS or a duration like 1m30s)", value)
+	}
+	var offset float64
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid time %q (expected seconds, [HH:]MM:SS or a duration like 1m30s)", value)
+		}
+		offset = offset*60 + n
+	}
+	return offset, nil
+}
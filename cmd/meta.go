@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/spf13/cobra"
+)
+
+var (
+	metaTitle         string
+	metaCommand       string
+	metaEnv           []string
+	metaIdleTimeLimit float64
+	metaThemeFg       string
+	metaThemeBg       string
+	metaThemePalette  string
+	metaOutput        string
+)
+
+var metaCmd = &cobra.Command{
+	Use:   "meta <filename>",
+	Short: "Show or edit a recording's header metadata",
+	Long: `Print the header fields of a recording, or change them with flags.
+
+--env KEY=VALUE sets an environment variable and --env KEY= removes it.
+An empty --title or --command clears the field, and --idle-time-limit 0
+removes the limit.
+
+Changes are written to a temporary file that replaces the recording, so it
+is never left half-written. The file is rewritten in place unless --output
+is given.
+
+Example:
+  goasciinema meta demo.cast --title "Deploying v2" --env TERM=xterm-256color`,
+	Args: cobra.ExactArgs(1),
+	RunE: runMeta,
+}
+
+func init() {
+	rootCmd.AddCommand(metaCmd)
+	metaCmd.Flags().StringVar(&metaTitle, "title", "", "Set the title")
+	metaCmd.Flags().StringVar(&metaCommand, "command", "", "Set the recorded command")
+	metaCmd.Flags().StringArrayVar(&metaEnv, "env", nil, "Set (KEY=VALUE) or remove (KEY=) an env variable (repeatable)")
+	metaCmd.Flags().Float64Var(&metaIdleTimeLimit, "idle-time-limit", 0, "Set the idle time limit in seconds")
+	metaCmd.Flags().StringVar(&metaThemeFg, "theme-fg", "", "Set the theme foreground color")
+	metaCmd.Flags().StringVar(&metaThemeBg, "theme-bg", "", "Set the theme background color")
+	metaCmd.Flags().StringVar(&metaThemePalette, "theme-palette", "", "Set the theme palette (colon-separated colors)")
+	metaCmd.Flags().StringVarP(&metaOutput, "output", "o", "", "Output file (default: rewrite in place)")
+}
+
+func runMeta(cmd *cobra.Command, args []string) error {
+	filename := args[0]
+	flags := cmd.Flags()
+
+	changed := false
+	for _, name := range []string{"title", "command", "env", "idle-time-limit", "theme-fg", "theme-bg", "theme-palette"} {
+		changed = changed || flags.Changed(name)
+	}
+	if !changed {
+		reader, err := asciicast.Open(filename)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer reader.Close()
+		printHeader(reader.Header)
+		return nil
+	}
+
+	if metaIdleTimeLimit < 0 {
+		return fmt.Errorf("--idle-time-limit must not be negative")
+	}
+	env := make(map[string]string)
+	for _, kv := range metaEnv {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid --env value %q (expected KEY=VALUE)", kv)
+		}
+		env[key] = value
+	}
+
+	header, events, err := loadEvents(filename)
+	if err != nil {
+		return err
+	}
+
+	if flags.Changed("title") {
+		header.Title = metaTitle
+	}
+	if flags.Changed("command") {
+		header.Command = metaCommand
+	}
+	if flags.Changed("idle-time-limit") {
+		header.IdleTimeLimit = metaIdleTimeLimit
+	}
+	for key, value := range env {
+		if value == "" {
+			delete(header.Env, key)
+			continue
+		}
+		if header.Env == nil {
+			header.Env = make(map[string]string)
+		}
+		header.Env[key] = value
+	}
+	if flags.Changed("theme-fg") || flags.Changed("theme-bg") || flags.Changed("theme-palette") {
+		theme := asciicast.Theme{}
+		if header.Theme != nil {
+			theme = *header.Theme
+		}
+		if flags.Changed("theme-fg") {
+			theme.Foreground = metaThemeFg
+		}
+		if flags.Changed("theme-bg") {
+			theme.Background = metaThemeBg
+		}
+		if flags.Changed("theme-palette") {
+			theme.Palette = metaThemePalette
+		}
+		header.Theme = &theme
+		if theme == (asciicast.Theme{}) {
+			header.Theme = nil
+		}
+	}
+
+	output := metaOutput
+	if output == "" {
+		output = filename
+	}
+	if err := replaceRecording(output, header, events); err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated %s\n", output)
+	return nil
+}
+
+// printHeader prints the header fields of a recording, one per line
+func printHeader(h asciicast.Header) {
+	fmt.Printf("Version: %d\n", h.Version)
+	fmt.Printf("Size: %dx%d\n", h.Width, h.Height)
+	if h.Timestamp > 0 {
+		fmt.Printf("Recorded: %s\n", formatSessionDate(time.Unix(h.Timestamp, 0), h.Timezone))
+	}
+	if h.Duration > 0 {
+		fmt.Printf("Duration: %s\n", formatOffset(h.Duration))
+	}
+	if h.IdleTimeLimit > 0 {
+		fmt.Printf("Idle time limit: %g\n", h.IdleTimeLimit)
+	}
+	if h.Title != "" {
+		fmt.Printf("Title: %s\n", h.Title)
+	}
+	if h.Command != "" {
+		fmt.Printf("Command: %s\n", h.Command)
+	}
+	if h.Series != "" {
+		fmt.Printf("Series: %s\n", h.Series)
+	}
+	if h.Audio != "" {
+		fmt.Printf("Audio: %s\n", h.Audio)
+	}
+	if h.Theme != nil {
+		fmt.Printf("Theme: fg=%s bg=%s palette=%s\n", h.Theme.Foreground, h.Theme.Background, h.Theme.Palette)
+	}
+	if len(h.Env) > 0 {
+		keys := make([]string, 0, len(h.Env))
+		for key := range h.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Println("Env:")
+		for _, key := range keys {
+			fmt.Printf("  %s=%s\n", key, h.Env[key])
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/spf13/cobra"
+)
+
+var normalizeOutput string
+
+var normalizeCmd = &cobra.Command{
+	Use:   "normalize <filename>",
+	Short: "Clean up a recording's event stream",
+	Long: `Rewrite a recording with redundant events removed.
+
+Consecutive resize events with no output between them, as produced by
+drag-resizing a window, are collapsed into the final size.
+
+The file is rewritten in place unless --output is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runNormalize,
+}
+
+func init() {
+	rootCmd.AddCommand(normalizeCmd)
+	normalizeCmd.Flags().StringVarP(&normalizeOutput, "output", "o", "", "Output file (default: rewrite in place)")
+}
+
+func runNormalize(cmd *cobra.Command, args []string) error {
+	filename := args[0]
+
+	header, events, err := loadEvents(filename)
+	if err != nil {
+		return err
+	}
+
+	normalized := asciicast.CollapseResizes(events)
+
+	output := normalizeOutput
+	if output == "" {
+		output = filename
+	}
+	if err := replaceRecording(output, header, normalized); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed %d redundant resize event(s) from %s\n", len(events)-len(normalized), output)
+	return nil
+}
+
+// loadEvents reads a recording's header and all of its events
+func loadEvents(filename string) (asciicast.Header, []asciicast.Event, error) {
+	rec, err := asciicast.Load(filename)
+	if err != nil {
+		return asciicast.Header{}, nil, err
+	}
+	return rec.Header, rec.Events, nil
+}
+
+// replaceRecording writes a recording to output through a temporary file,
+// so an existing file is never left half-written. Recordings are always
+// written as asciicast v2.
+func replaceRecording(output string, header asciicast.Header, events []asciicast.Event) error {
+	rec := &asciicast.Recording{Header: header, Events: events}
+	return rec.Save(output)
+}
+
+// replaceFile has write create a temporary file next to output, then
+// renames it over output
+func replaceFile(output string, write func(tmpName string) error) error {
+	// The temporary name keeps the extension, which selects compression
+	tmpName := filepath.Join(filepath.Dir(output), ".tmp-"+filepath.Base(output))
+	if err := write(tmpName); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, output); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to replace %s: %w", output, err)
+	}
+	return nil
+}
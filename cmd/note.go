@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/ober/goasciinema/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	noteAt     float64
+	noteOutput string
+)
+
+var noteCmd = &cobra.Command{
+	Use:   "note",
+	Short: "Manage session notes and annotations",
+	Long: `Attach notes to moments in a recording.
+
+Notes are stored in the database, shown alongside search results,
+and can be written back into the cast file as marker events.`,
+}
+
+var noteAddCmd = &cobra.Command{
+	Use:   "add <filename> <note>",
+	Short: "Add a note to a recording",
+	Long: `Add a note to a recording at the given time offset.
+
+Example:
+  goasciinema note add session.cast --at 123 "found the root cause here"`,
+	Args: cobra.ExactArgs(2),
+	RunE: runNoteAdd,
+}
+
+var noteListCmd = &cobra.Command{
+	Use:   "list <filename>",
+	Short: "List notes for a recording",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runNoteList,
+}
+
+var noteExportCmd = &cobra.Command{
+	Use:   "export <filename>",
+	Short: "Write notes into the recording as markers",
+	Long: `Rewrite a recording with its notes inserted as marker events.
+
+The file is rewritten in place unless --output is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runNoteExport,
+}
+
+func init() {
+	rootCmd.AddCommand(noteCmd)
+	noteCmd.AddCommand(noteAddCmd)
+	noteCmd.AddCommand(noteListCmd)
+	noteCmd.AddCommand(noteExportCmd)
+
+	noteAddCmd.Flags().Float64Var(&noteAt, "at", 0, "Time offset in seconds")
+	noteExportCmd.Flags().StringVarP(&noteOutput, "output", "o", "", "Output file (default: rewrite in place)")
+}
+
+func runNoteAdd(cmd *cobra.Command, args []string) error {
+	filename, text := args[0], args[1]
+
+	if noteAt < 0 {
+		return fmt.Errorf("--at must not be negative")
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.AddAnnotation(filename, noteAt, text); err != nil {
+		return fmt.Errorf("failed to add note: %w", err)
+	}
+
+	fmt.Printf("Added note to %s at %s\n", filepath.Base(filename), formatOffset(noteAt))
+	return nil
+}
+
+func runNoteList(cmd *cobra.Command, args []string) error {
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	notes, err := db.GetAnnotations(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %w", err)
+	}
+
+	if len(notes) == 0 {
+		fmt.Printf("No notes for %s\n", filepath.Base(args[0]))
+		return nil
+	}
+
+	for _, n := range notes {
+		fmt.Printf("[%s] %s\n", formatOffset(n.At), n.Note)
+	}
+	return nil
+}
+
+func runNoteExport(cmd *cobra.Command, args []string) error {
+	filename := args[0]
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	notes, err := db.GetAnnotations(filename)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %w", err)
+	}
+	if len(notes) == 0 {
+		fmt.Printf("No notes for %s\n", filepath.Base(filename))
+		return nil
+	}
+
+	header, events, err := loadEvents(filename)
+	if err != nil {
+		return err
+	}
+
+	// Notes already in the recording as markers, such as from an earlier
+	// export, aren't added again
+	existing := make(map[string]bool)
+	for _, e := range events {
+		if e.Type == asciicast.EventTypeMarker {
+			existing[markerKey(e.Time, e.Data)] = true
+		}
+	}
+	added := 0
+	for _, n := range notes {
+		if existing[markerKey(n.At, n.Note)] {
+			continue
+		}
+		events = append(events, asciicast.Event{Time: n.At, Type: asciicast.EventTypeMarker, Data: n.Note})
+		added++
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time < events[j].Time
+	})
+
+	output := noteOutput
+	if output == "" {
+		output = filename
+	}
+	if added == 0 && output == filename {
+		fmt.Printf("All notes are already markers in %s\n", output)
+		return nil
+	}
+
+	if err := replaceRecording(output, header, events); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %d marker(s) to %s\n", added, output)
+	return nil
+}
+
+// markerKey identifies a marker by its label and its time as written to a
+// recording, truncated to microseconds like the writer does
+func markerKey(at float64, label string) string {
+	return fmt.Sprintf("%d %s", int64(at*1000000), label)
+}
+
+// formatOffset formats a time offset in seconds as HH:MM:SS
+func formatOffset(seconds float64) string {
+	total := int(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d", total/3600, (total%3600)/60, total%60)
+}
+
+// formatNotes renders notes as an org-mode list
+func formatNotes(notes []database.Annotation) string {
+	var lines []string
+	for _, n := range notes {
+		lines = append(lines, fmt.Sprintf("- [%s] %s", formatOffset(n.At), n.Note))
+	}
+	return strings.Join(lines, "\n")
+}
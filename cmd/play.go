@@ -1,21 +1,57 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/ober/goasciinema/internal/api"
+	"github.com/ober/goasciinema/internal/asciicast"
 	"github.com/ober/goasciinema/internal/config"
 	"github.com/ober/goasciinema/internal/player"
+	"github.com/ober/goasciinema/internal/tty"
 	"github.com/spf13/cobra"
 )
 
 var playCmd = &cobra.Command{
-	Use:   "play <filename>",
+	Use:   "play [filename...]",
 	Short: "Replay recorded terminal session",
 	Long: `Play back a recorded asciicast file.
 
-Supports both local files and URLs.
-Use -s to adjust playback speed, -i to limit idle time.`,
-	Args: cobra.ExactArgs(1),
+Supports both local files and URLs. Several files, or the files listed
+in a --playlist, are played back to back; --separator starts each one
+with a marker named after it. URLs are downloaded to a cache in
+the temporary directory, following redirects, and recording pages such as
+https://asciinema.org/a/123 are resolved to their cast file.
+Use -s to adjust playback speed, -i to limit idle time.
+Use --series to play every part of a recording series from the database
+back to back. --id and --db-file play a session's recording as stored in
+the database by 'process', so the original file isn't needed.
+
+--render replays output through a terminal emulator and redraws the
+emulated screen, which keeps recordings made at other sizes, for other
+terminal types, or with corrupted escape sequences watchable.
+
+Keys: space pauses and resumes, left/right arrow (or , and ;) seek
+back/forward 5 seconds, m jumps to the next marker, b pauses and steps
+back one piece of output at a time, + (or =) and - (or _) double and
+halve the speed, s shows or hides the status line (--status shows it
+from the start), Ctrl+C quits.
+--from and --to play only part of a recording: output before --from is
+rendered instantly, and playback stops at --to.
+--pace manual writes each chunk of output only when a key is pressed,
+and --pace line each line, to present pre-recorded commands live.
+--follow tails a recording that another goasciinema process is still
+writing: the screen so far is drawn at once, then new output is shown as
+it lands, until the recording ends.
+--pause-on-markers stops at each marker, for presenting a recording
+chapter by chapter.`,
 	RunE: runPlay,
 }
 
@@ -24,6 +60,26 @@ var (
 	playIdleTimeLimit float64
 	playMaxWait       float64
 	playLoop          bool
+	playThrottleBytes int
+	playSeries        string
+	playRender        bool
+	playPauseMarkers  bool
+	playFrom          string
+	playTo            string
+	playStatus        bool
+	playNoAltScreen   bool
+	playPace          string
+	playFollow        bool
+	playID            string
+	playDBFile        string
+	playPlaylist      string
+	playSeparator     bool
+	playHeadless      bool
+	playPreview       string
+	playSubtitles     string
+	playFit           bool
+	playPTY           bool
+	playPTYLink       string
 )
 
 func init() {
@@ -33,15 +89,139 @@ func init() {
 	playCmd.Flags().Float64VarP(&playIdleTimeLimit, "idle-time-limit", "i", 0, "Limit replayed idle time to given seconds")
 	playCmd.Flags().Float64VarP(&playMaxWait, "maxwait", "m", 0, "Maximum wait time between frames")
 	playCmd.Flags().BoolVarP(&playLoop, "loop", "l", false, "Loop playback")
+	playCmd.Flags().StringVar(&playSeries, "series", "", "Play all recordings in a series from the database")
+	playCmd.Flags().StringVar(&playPlaylist, "playlist", "", "Play the recordings listed in this file, one per line")
+	playCmd.Flags().BoolVar(&playSeparator, "separator", false, "Start each recording with a marker named after its file")
+	playCmd.Flags().StringVar(&playID, "id", "", "Play the recording stored in the database for this session ID")
+	playCmd.Flags().StringVar(&playDBFile, "db-file", "", "Play the recording stored in the database for this file name")
+	playCmd.Flags().BoolVar(&playRender, "render", false, "Redraw an emulated screen instead of writing raw escape sequences")
+	playCmd.Flags().StringVar(&playFrom, "from", "", "Start playback at this offset, e.g. 1m10s or 01:10")
+	playCmd.Flags().StringVar(&playTo, "to", "", "Stop playback at this offset")
+	playCmd.Flags().StringVar(&playPreview, "preview", "", "Play only this much of each recording, e.g. 10s, then print a summary of each")
+	playCmd.Flags().BoolVar(&playNoAltScreen, "no-alt-screen", false, "Play on the main screen instead of the alternate screen, leaving the output in the scrollback")
+	playCmd.Flags().StringVar(&playPace, "pace", player.PaceTime, "What drives playback: time (recorded timing), manual (one output chunk per key press) or line (one line per key press)")
+	playCmd.Flags().BoolVarP(&playFollow, "follow", "f", false, "Follow a recording that is still being written, showing new output as it lands")
+	playCmd.Flags().BoolVar(&playFit, "fit", false, "Play recordings larger than the terminal through the terminal emulator, cropped around the cursor")
+	playCmd.Flags().BoolVar(&playStatus, "status", false, "Show a status line with the position, speed and pause state")
+	playCmd.Flags().StringVar(&playSubtitles, "subtitles", "", "Show the last marker's label as a chapter title on the top or bottom row")
+	playCmd.Flags().BoolVar(&playPauseMarkers, "pause-on-markers", false, "Pause at each marker; press space to resume")
+	playCmd.Flags().BoolVar(&playHeadless, "headless", false, "Play into a terminal emulator as fast as possible and print statistics instead of playing")
+	playCmd.Flags().BoolVar(&playPTY, "pty", false, "Play into a new pseudo-terminal instead of this one and print its device path")
+	playCmd.Flags().StringVar(&playPTYLink, "pty-link", "", "With --pty, also make this path a symlink to the device")
+	playCmd.Flags().IntVar(&playThrottleBytes, "throttle-bytes", 0, "Maximum bytes written to the terminal per frame (0 = unlimited)")
 }
 
 func runPlay(cmd *cobra.Command, args []string) error {
+	if code, ok := superviseTerminal(); ok {
+		os.Exit(code)
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	filename := args[0]
+	sources := 0
+	for _, given := range []bool{len(args) > 0, playPlaylist != "", playSeries != "", playID != "", playDBFile != ""} {
+		if given {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("give only one of filenames, --playlist, --series, --id or --db-file")
+	}
+
+	var filenames []string
+	switch {
+	case playSeries != "":
+		filenames, err = seriesFiles(playSeries)
+		if err != nil {
+			return err
+		}
+	case playID != "" || playDBFile != "":
+		filename, err := storedRecording(playID + playDBFile)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(filename)
+		filenames = []string{filename}
+	case playPlaylist != "":
+		filenames, err = readPlaylist(playPlaylist)
+		if err != nil {
+			return err
+		}
+	case len(args) > 0:
+		filenames = args
+	default:
+		return fmt.Errorf("a filename, --playlist, --series, --id or --db-file is required")
+	}
+
+	if playFollow && (playLoop || playFrom != "" || playTo != "" || playPreview != "" || playPace != player.PaceTime) {
+		return fmt.Errorf("--follow can't be combined with --loop, --from, --to, --preview or --pace")
+	}
+	if playPTY && (playHeadless || playFollow || playPace != player.PaceTime) {
+		return fmt.Errorf("--pty can't be combined with --headless, --follow or --pace")
+	}
+	if playPTYLink != "" && !playPTY {
+		return fmt.Errorf("--pty-link needs --pty")
+	}
+
+	switch playPace {
+	case player.PaceTime:
+	case player.PaceManual, player.PaceLine:
+		if !playHeadless && !tty.IsTerminal(tty.GetStdinFd()) {
+			return fmt.Errorf("--pace %s needs a terminal to read key presses from", playPace)
+		}
+	default:
+		return fmt.Errorf("unknown pace %q (use time, manual or line)", playPace)
+	}
+
+	switch playSubtitles {
+	case "", player.SubtitlesTop, player.SubtitlesBottom:
+	default:
+		return fmt.Errorf("unknown subtitles row %q (use top or bottom)", playSubtitles)
+	}
+
+	var from, to float64
+	if playFrom != "" {
+		if from, err = parseOffset(playFrom); err != nil {
+			return fmt.Errorf("invalid --from value: %w", err)
+		}
+	}
+	if playTo != "" {
+		if to, err = parseOffset(playTo); err != nil {
+			return fmt.Errorf("invalid --to value: %w", err)
+		}
+		if to <= from {
+			return fmt.Errorf("--to must be after --from")
+		}
+	}
+	if playPreview != "" {
+		preview, err := parseOffset(playPreview)
+		if err != nil {
+			return fmt.Errorf("invalid --preview value: %w", err)
+		}
+		if preview <= 0 {
+			return fmt.Errorf("--preview must be positive")
+		}
+		if to == 0 || from+preview < to {
+			to = from + preview
+		}
+	}
+
+	for i, name := range filenames {
+		if !api.IsURL(name) {
+			continue
+		}
+		client := api.NewClient(cfg.API.URL, "")
+		if filenames[i], err = client.FetchCast(name, filepath.Join(os.TempDir(), "goasciinema-cache")); err != nil {
+			return fmt.Errorf("failed to fetch %s: %w", name, err)
+		}
+	}
+
+	if playHeadless {
+		return playHeadlessFiles(filenames)
+	}
 
 	// Apply config defaults
 	if playSpeed == 1.0 && cfg.Play.Speed > 0 {
@@ -54,19 +234,235 @@ func runPlay(cmd *cobra.Command, args []string) error {
 		playMaxWait = cfg.Play.MaxWait
 	}
 
-	// Create player
-	p := player.New(player.Options{
-		Speed:         playSpeed,
-		IdleTimeLimit: playIdleTimeLimit,
-		MaxWait:       playMaxWait,
-		Loop:          playLoop,
-	})
+	options := player.Options{
+		Speed:          playSpeed,
+		IdleTimeLimit:  playIdleTimeLimit,
+		MaxWait:        playMaxWait,
+		Loop:           playLoop,
+		ThrottleBytes:  playThrottleBytes,
+		Render:         playRender,
+		PauseOnMarkers: playPauseMarkers,
+		From:           from,
+		To:             to,
+		Status:         playStatus,
+		Subtitles:      playSubtitles,
+		Fit:            playFit,
+		AltScreen:      !playNoAltScreen,
+		Pace:           playPace,
+		Follow:         playFollow,
+		Separator:      playSeparator,
+	}
+	if playPTY {
+		return playIntoPTY(filenames, options, playPTYLink)
+	}
 
 	// Play
-	err = p.Play(filename)
+	err = player.New(options).PlayFiles(filenames)
 	if err != nil {
 		return fmt.Errorf("playback failed: %w", err)
 	}
 
+	if playPreview != "" {
+		for _, filename := range filenames {
+			printSummary(filename)
+		}
+	}
 	return nil
 }
+
+// playIntoPTY plays recordings into a new pseudo-terminal rather than this
+// one, so tests of TUI programs and screen scrapers can read a
+// deterministic stream from its device. The device path is printed, and
+// playback waits for the reader: it only ends once everything written has
+// been read, or on Ctrl+C.
+func playIntoPTY(filenames []string, options player.Options, link string) error {
+	pt, err := tty.OpenPTY()
+	if err != nil {
+		return err
+	}
+	defer pt.Close()
+
+	if link != "" {
+		if err := os.Symlink(pt.Name(), link); err != nil {
+			return fmt.Errorf("failed to link pty: %w", err)
+		}
+		defer os.Remove(link)
+	}
+	fmt.Println(pt.Name())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// A write blocked on a reader that stopped reading is cut short too
+	context.AfterFunc(ctx, func() { pt.Master.SetWriteDeadline(time.Now()) })
+
+	// Hiding that the output is a terminal keeps the player from writing
+	// window size requests into the stream
+	options.Output = struct{ io.Writer }{pt.Master}
+	p := player.New(options)
+	for {
+		for _, filename := range filenames {
+			if err := playFileInto(ctx, p, pt, filename); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("playback failed: %w", err)
+			}
+		}
+		if !options.Loop {
+			break
+		}
+	}
+
+	for ctx.Err() == nil {
+		if pending, err := pt.Pending(); err != nil || pending == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// playFileInto plays one recording into a pseudo-terminal, first sizing it
+// like the recording
+func playFileInto(ctx context.Context, p *player.Player, pt *tty.PTY, filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := asciicast.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	err = pt.SetSize(reader.Header.Width, reader.Header.Height)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to size pty: %w", err)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	return p.Play(ctx, file)
+}
+
+// printSummary prints a line with a recording's title, duration and
+// size, after previewing it
+func printSummary(filename string) {
+	rec, err := asciicast.Load(filename)
+	if err != nil {
+		fmt.Printf("%s: %v\n", filename, err)
+		return
+	}
+	duration := rec.Header.Duration
+	if n := len(rec.Events); n > 0 {
+		duration = max(duration, rec.Events[n-1].Time)
+	}
+	title := rec.Header.Title
+	if title == "" {
+		title = "(untitled)"
+	}
+	fmt.Printf("%s: %s, %s, %dx%d\n", filename, title, formatOffset(duration), rec.Header.Width, rec.Header.Height)
+}
+
+// playHeadlessFiles plays recordings without output and prints their
+// statistics, failing if any of them can't be played
+func playHeadlessFiles(filenames []string) error {
+	var failed int
+	for _, filename := range filenames {
+		stats, err := player.Headless(filename)
+		if err != nil {
+			fmt.Printf("%s: %v\n", filename, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: %d events, %.3fs, screen %s\n", filename, stats.Events, stats.Duration, stats.ScreenHash)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d recording(s) failed to play", failed, len(filenames))
+	}
+	return nil
+}
+
+// readPlaylist reads a playlist file: one recording path or URL per line,
+// with blank lines and lines starting with # ignored. Relative paths are
+// relative to the playlist's directory.
+func readPlaylist(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read playlist: %w", err)
+	}
+
+	var filenames []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !api.IsURL(line) && !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(path), line)
+		}
+		filenames = append(filenames, line)
+	}
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("playlist %s lists no recordings", path)
+	}
+	return filenames, nil
+}
+
+// storedRecording writes the recording the database holds for a session,
+// given by ID or file name, to a temporary file and returns its name
+func storedRecording(ref string) (string, error) {
+	db, err := openDatabase()
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	session, err := db.LookupSession(ref)
+	if err != nil {
+		return "", err
+	}
+	recording, err := db.SessionRecording(session.Filename)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp("", "goasciinema-*.cast")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	if _, err := file.Write(recording); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// seriesFiles returns the files of a series from the database
+func seriesFiles(series string) ([]string, error) {
+	db, err := openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	files, err := db.SeriesFiles(series)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no recordings in series %q (run 'process' first)", series)
+	}
+	return files, nil
+}
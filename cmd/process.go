@@ -1,21 +1,36 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ober/goasciinema/internal/asciicast"
 	"github.com/ober/goasciinema/internal/database"
+	"github.com/ober/goasciinema/internal/redact"
 	"github.com/ober/goasciinema/internal/sanitize"
 	"github.com/spf13/cobra"
 )
 
 var (
-	processForce    bool
-	processDatabase string
+	processForce           bool
+	processWatch           bool
+	processInterval        time.Duration
+	processNotify          bool
+	processNotifyCommand   string
+	processDryRun          bool
+	processVerbose         bool
+	processValidate        bool
+	processSkipBadLines    bool
+	processStoreRecordings bool
 )
 
 var processCmd = &cobra.Command{
@@ -27,7 +42,27 @@ This command reads .asc or .cast files, extracts the terminal output,
 strips ANSI escape codes, and stores the clean content in a searchable
 SQLite database.
 
-Files are tracked by hash - unchanged files will be skipped unless --force is used.`,
+Files are tracked by hash - unchanged files will be skipped unless --force is used.
+
+With --watch, the directory is rescanned periodically. Use --notify for a
+desktop notification, or --notify-command to run a hook (for example to send
+an email), whenever new sessions are indexed. The summary lists each new
+session with the number of secrets the redact command would mask in it
+(redaction hits), and the hook receives it on stdin and in
+$GOASCIINEMA_SUMMARY.
+
+With --dry-run, nothing is written to the database; instead each file is
+checked and counted as one that would be processed (new), reprocessed (hash
+changed), skipped (hash match), or failed to parse, with the reason. Add -v
+to list every file rather than only failures.
+
+With --validate, each file is checked as by the validate command first,
+and files with problems are reported and left out.
+
+With --store-recordings (or store_recordings = yes in ~/.goasciinema), a
+compressed copy of each recording is kept in the database, so it can be
+played after the file is gone. Reprocessing an unchanged file keeps the
+copy already stored.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runProcess,
 }
@@ -35,7 +70,15 @@ Files are tracked by hash - unchanged files will be skipped unless --force is us
 func init() {
 	rootCmd.AddCommand(processCmd)
 	processCmd.Flags().BoolVarP(&processForce, "force", "f", false, "Force reprocessing of already processed files")
-	processCmd.Flags().StringVarP(&processDatabase, "database", "d", "", "SQLite database file (default: from ~/.goasciinema or ~/console-logs/asciinema_logs.db)")
+	processCmd.Flags().BoolVarP(&processWatch, "watch", "w", false, "Keep running and rescan the directory periodically")
+	processCmd.Flags().DurationVar(&processInterval, "interval", 30*time.Second, "Rescan interval in watch mode")
+	processCmd.Flags().BoolVar(&processNotify, "notify", false, "Send a desktop notification when new sessions are indexed")
+	processCmd.Flags().StringVar(&processNotifyCommand, "notify-command", "", "Shell command to run when new sessions are indexed")
+	processCmd.Flags().BoolVarP(&processDryRun, "dry-run", "n", false, "Report what would be processed without touching the database")
+	processCmd.Flags().BoolVarP(&processVerbose, "verbose", "v", false, "Report every file, including skipped ones")
+	processCmd.Flags().BoolVar(&processValidate, "validate", false, "Check each file for problems first and skip invalid ones")
+	processCmd.Flags().BoolVar(&processSkipBadLines, "skip-bad-lines", false, "Index recordings with unparseable event lines, skipping those lines")
+	processCmd.Flags().BoolVar(&processStoreRecordings, "store-recordings", false, "Keep a compressed copy of each recording in the database")
 }
 
 func runProcess(cmd *cobra.Command, args []string) error {
@@ -44,16 +87,17 @@ func runProcess(cmd *cobra.Command, args []string) error {
 		path = args[0]
 	}
 
-	// Use config default if no database specified
-	dbPath := processDatabase
-	if dbPath == "" {
-		dbPath = GetDefaultDatabasePath()
+	if processDryRun {
+		if processWatch {
+			return fmt.Errorf("--dry-run cannot be combined with --watch")
+		}
+		return dryRunProcess(path)
 	}
 
 	// Open database
-	db, err := database.Open(dbPath)
+	db, err := openDatabase()
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
 	defer db.Close()
 
@@ -67,7 +111,12 @@ func runProcess(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
-		fmt.Printf("\nSummary: %d processed, %d skipped\n", processed, skipped)
+		fmt.Printf("\nSummary: %d processed, %d skipped\n", len(processed), skipped)
+		notifyProcessed(processed)
+
+		if processWatch {
+			return watchDirectory(db, path)
+		}
 	} else {
 		wasProcessed, err := processFile(db, path)
 		if err != nil {
@@ -83,43 +132,231 @@ func runProcess(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func processDirectory(db *database.DB, dir string) (int, int, error) {
-	var processed, skipped int
+// processDirectory processes every recording in dir and returns the paths
+// of newly processed files and the number skipped
+func processDirectory(db *database.DB, dir string) ([]string, int, error) {
+	var processed []string
+	var skipped int
 
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to read directory: %w", err)
+		return nil, 0, fmt.Errorf("failed to read directory: %w", err)
 	}
 
-	// Sort and filter for .asc and .cast files
-	var files []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		name := entry.Name()
-		if strings.HasSuffix(name, ".asc") || strings.HasSuffix(name, ".cast") {
-			files = append(files, filepath.Join(dir, name))
-		}
-	}
-
-	for _, file := range files {
+	for _, file := range recordingFiles(entries, dir) {
 		wasProcessed, err := processFile(db, file)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to process %s: %v\n", file, err)
 			continue
 		}
 		if wasProcessed {
-			processed++
+			processed = append(processed, file)
 			fmt.Printf("Processed: %s\n", filepath.Base(file))
 		} else {
 			skipped++
+			if processVerbose {
+				fmt.Printf("Skipped (unchanged): %s\n", filepath.Base(file))
+			}
 		}
 	}
 
 	return processed, skipped, nil
 }
 
+// dryRunProcess reports what processing path would do. The database is
+// opened read-only, and a missing database means every file is new.
+func dryRunProcess(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("path not found: %w", err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("failed to read directory: %w", err)
+		}
+		files = recordingFiles(entries, path)
+	}
+
+	var db *database.DB
+	dbPath := GetDefaultDatabasePath()
+	if _, err := os.Stat(dbPath); err == nil {
+		db, err = database.OpenReadOnly(dbPath)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	var process, reprocess, skip, fail int
+	for _, file := range files {
+		name := filepath.Base(file)
+
+		state := database.FileNew
+		if db != nil {
+			state, err = db.FileStatus(file)
+			if err != nil {
+				fail++
+				fmt.Printf("fail       %s: %v\n", name, err)
+				continue
+			}
+		}
+		if state == database.FileUnchanged && !processForce {
+			skip++
+			if processVerbose {
+				fmt.Printf("skip       %s (hash match)\n", name)
+			}
+			continue
+		}
+
+		if _, _, err := readRecording(file); err != nil {
+			fail++
+			fmt.Printf("fail       %s: %v\n", name, err)
+			continue
+		}
+
+		var reason string
+		switch state {
+		case database.FileNew:
+			process++
+			reason = "process    %s (new)\n"
+		case database.FileChanged:
+			reprocess++
+			reason = "reprocess  %s (hash changed)\n"
+		default:
+			reprocess++
+			reason = "reprocess  %s (forced)\n"
+		}
+		if processVerbose {
+			fmt.Printf(reason, name)
+		}
+	}
+
+	fmt.Printf("\nDry run: %d to process, %d to reprocess, %d to skip, %d failed (database not modified)\n",
+		process, reprocess, skip, fail)
+	return nil
+}
+
+// recordingFiles filters directory entries down to .asc and .cast files
+// (compressed or not), and .json files holding asciicast v1 recordings
+func recordingFiles(entries []os.DirEntry, dir string) []string {
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), asciicast.ZstdExt)
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case strings.HasSuffix(name, ".asc"), strings.HasSuffix(name, ".cast"):
+			files = append(files, path)
+		case strings.HasSuffix(name, ".json"):
+			if version, err := asciicast.DetectVersion(path); err == nil && version == asciicast.Version1 {
+				files = append(files, path)
+			}
+		}
+	}
+	return files
+}
+
+// watchDirectory rescans dir every processInterval until interrupted
+func watchDirectory(db *database.DB, dir string) error {
+	fmt.Printf("Watching %s (every %s)...\n", dir, processInterval)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	ticker := time.NewTicker(processInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sigCh:
+			return nil
+		case <-ticker.C:
+			processed, _, err := processDirectory(db, dir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			notifyProcessed(processed)
+		}
+	}
+}
+
+// notifyProcessed sends the configured notifications for newly indexed files
+func notifyProcessed(processed []string) {
+	if len(processed) == 0 || (!processNotify && processNotifyCommand == "") {
+		return
+	}
+
+	summary := processedSummary(processed)
+
+	if processNotify {
+		if err := desktopNotify("goasciinema", summary); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: desktop notification failed: %v\n", err)
+		}
+	}
+
+	if processNotifyCommand != "" {
+		hook := exec.Command("/bin/sh", "-c", processNotifyCommand)
+		hook.Env = append(os.Environ(), "GOASCIINEMA_SUMMARY="+summary)
+		hook.Stdin = strings.NewReader(summary)
+		hook.Stdout = os.Stdout
+		hook.Stderr = os.Stderr
+		if err := hook.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: notify command failed: %v\n", err)
+		}
+	}
+}
+
+// processedSummary lists newly indexed files, each with its redaction hits
+func processedSummary(processed []string) string {
+	var patterns []string
+	if AppConfig != nil {
+		patterns = AppConfig.Redact.Patterns
+	}
+	redactor, err := redact.New(patterns)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: not counting redaction hits: %v\n", err)
+	}
+
+	var lines []string
+	flagged := 0
+	for _, file := range processed {
+		line := filepath.Base(file)
+		if redactor != nil {
+			if _, events, err := loadEvents(file); err == nil {
+				if _, hits := redactor.Redact(events); hits > 0 {
+					line += fmt.Sprintf(" (%d redaction hit(s))", hits)
+					flagged++
+				}
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	return fmt.Sprintf("goasciinema indexed %d new session(s), %d with redaction hits:\n%s\n",
+		len(processed), flagged, strings.Join(lines, "\n"))
+}
+
+// desktopNotify shows a desktop notification using the platform's notifier
+func desktopNotify(title, body string) error {
+	var notifier *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", body, title)
+		notifier = exec.Command("osascript", "-e", script)
+	default:
+		notifier = exec.Command("notify-send", title, body)
+	}
+	return notifier.Run()
+}
+
 func processFile(db *database.DB, filepath string) (bool, error) {
 	// Check if already processed (unless force)
 	if !processForce {
@@ -132,38 +369,169 @@ func processFile(db *database.DB, filepath string) (bool, error) {
 		}
 	}
 
+	if processValidate {
+		if err := validateRecording(filepath); err != nil {
+			return false, err
+		}
+	}
+
+	header, cleanContent, err := readRecording(filepath)
+	if err != nil {
+		return false, err
+	}
+
+	var recording []byte
+	if storeRecordings() {
+		if recording, err = recordingToStore(db, filepath); err != nil {
+			return false, err
+		}
+	}
+
+	// Insert into database
+	if err := db.InsertFile(filepath, header, cleanContent, recording); err != nil {
+		return false, fmt.Errorf("failed to insert into database: %w", err)
+	}
+
+	return true, nil
+}
+
+// storeRecordings reports whether processed recordings are copied into
+// the database
+func storeRecordings() bool {
+	return processStoreRecordings || (AppConfig != nil && AppConfig.Database.StoreRecordings)
+}
+
+// recordingToStore returns the copy of a recording to store with its
+// session: the one already stored if the file hasn't changed, otherwise a
+// new encoding
+func recordingToStore(db *database.DB, path string) ([]byte, error) {
+	if state, err := db.FileStatus(path); err == nil && state == database.FileUnchanged {
+		if recording, err := db.SessionRecording(path); err == nil {
+			return recording, nil
+		}
+	}
+	return encodeRecording(path)
+}
+
+// encodeRecording reads a recording and encodes it as compressed
+// asciicast v2, the form recordings are stored in the database in
+func encodeRecording(path string) ([]byte, error) {
+	reader, err := asciicast.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer reader.Close()
+	reader.SetLenient(processSkipBadLines)
+
+	header := reader.Header
+	header.Version = asciicast.Version2
+	var buf bytes.Buffer
+	zw, err := asciicast.NewCompressor(&buf)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := asciicast.NewWriterTo(zw, header)
+	if err != nil {
+		return nil, err
+	}
+	for reader.Next() {
+		if err := writer.WriteEvent(reader.Event()); err != nil {
+			return nil, err
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress recording: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// validateRecording returns an error describing the first problem found
+// in a recording, if any. Warnings are ignored.
+func validateRecording(path string) error {
+	problems, err := asciicast.Validate(path)
+	if err != nil {
+		return err
+	}
+	problems = asciicast.Errors(problems)
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("invalid recording: %s", problems[0])
+	default:
+		return fmt.Errorf("invalid recording: %s (and %d more problem(s))", problems[0], len(problems)-1)
+	}
+}
+
+// readRecording reads a recording and returns its database header (with
+// duration and marker counts) and the ANSI-stripped output content
+func readRecording(path string) (database.Header, string, error) {
 	// Open and read the asciicast file
-	reader, err := asciicast.Open(filepath)
+	reader, err := asciicast.Open(path)
 	if err != nil {
-		return false, fmt.Errorf("failed to open file: %w", err)
+		return database.Header{}, "", fmt.Errorf("failed to open file: %w", err)
 	}
 	defer reader.Close()
+	reader.SetLenient(processSkipBadLines)
 
-	// Extract all output content
+	// Extract all output content, timing, and markers
 	var content strings.Builder
+	var duration, idleDuration, prevTime float64
+	var markers int
+	idleLimit := reader.Header.IdleTimeLimit
 	for {
 		event, err := reader.ReadEvent()
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
-			return false, fmt.Errorf("failed to read event: %w", err)
+			return database.Header{}, "", fmt.Errorf("failed to read event: %w", err)
+		}
+
+		delay := event.Time - prevTime
+		if idleLimit > 0 && delay > idleLimit {
+			delay = idleLimit
+		}
+		if delay > 0 {
+			idleDuration += delay
+		}
+		prevTime = event.Time
+		if event.Time > duration {
+			duration = event.Time
 		}
 
-		if event.Type == asciicast.EventTypeOutput {
+		switch event.Type {
+		case asciicast.EventTypeOutput:
 			content.WriteString(event.Data)
+		case asciicast.EventTypeMarker:
+			markers++
 		}
 	}
+	if n := reader.Skipped(); n > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: skipped %d unparseable line(s) in %s\n", n, path)
+	}
 
 	// Strip ANSI codes
 	cleanContent := sanitize.StripANSI(content.String())
 
 	// Get header info for database
 	header := database.Header{
-		Version:   reader.Header.Version,
-		Width:     reader.Header.Width,
-		Height:    reader.Header.Height,
-		Timestamp: reader.Header.Timestamp,
+		Version:      reader.Header.Version,
+		Width:        reader.Header.Width,
+		Height:       reader.Header.Height,
+		Timestamp:    reader.Header.Timestamp,
+		Duration:     duration,
+		IdleDuration: idleDuration,
+		Markers:      markers,
+		Series:       reader.Header.Series,
+		Preview:      sanitize.Preview(sanitize.CleanLines(content.String())),
+		Timezone:     reader.Header.Timezone,
 	}
 
 	// Extract shell and term from env if present
@@ -172,10 +540,5 @@ func processFile(db *database.DB, filepath string) (bool, error) {
 		header.Term = reader.Header.Env["TERM"]
 	}
 
-	// Insert into database
-	if err := db.InsertFile(filepath, header, cleanContent); err != nil {
-		return false, fmt.Errorf("failed to insert into database: %w", err)
-	}
-
-	return true, nil
+	return header, cleanContent, nil
 }
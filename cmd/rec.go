@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/ober/goasciinema/internal/asciicast"
 	"github.com/ober/goasciinema/internal/config"
 	"github.com/ober/goasciinema/internal/recorder"
+	"github.com/ober/goasciinema/internal/tty"
 	"github.com/spf13/cobra"
 )
 
@@ -15,8 +21,53 @@ var recCmd = &cobra.Command{
 	Short: "Record terminal session",
 	Long: `Record a terminal session to a file.
 
-If no filename is specified, a temporary file will be used.
-The recording will be saved in asciicast v2 format.`,
+If no filename is specified, a private (0600) temporary file is created
+in the tmp_dir from the [record] config section, $XDG_RUNTIME_DIR, or the
+system temporary directory. Set tmp_max_age to remove old temporary
+recordings automatically.
+The recording will be saved in asciicast v2 format.
+
+--audio records microphone narration to a separate file while the session
+is recorded, with "audio sync" markers for alignment every 10 seconds and
+wherever the recording clock skips (pauses and cut idle time).
+The capture command defaults to ffmpeg and can be changed with
+audio_command in the [record] config section; it receives the output path
+in $GOASCIINEMA_AUDIO. 'render video --audio' muxes the two into a video.
+
+--headless records without a controlling terminal, e.g. from cron or CI:
+  goasciinema rec --headless -c "./build.sh" build.cast
+The terminal is left alone, there is no keyboard input, the size comes
+from --cols/--rows or cols/rows in the [record] config section (default
+80x24), and the output is only copied to stdout with --mirror.
+
+--raw writes the output exactly as the terminal received it, without
+JSON framing or timestamps (like script(1)), for piping into other tools.
+It needs a filename and replaces the cast file; use --raw-log to keep the
+same stream next to a cast.
+
+--raw-log and --relay write the session to additional outputs at the same
+time: the plain output stream to a file, and the asciicast stream to a TCP
+address. These run independently of the cast file, so a dropped
+connection never stalls or corrupts the local recording.
+
+--transport ships events to an external collector in near real time, one
+JSON message per event tagged with a session ID, host and user:
+  udp://host:port            one datagram per message
+  syslog: / syslog://host    local or remote syslog (syslog+tcp:// for TCP)
+  kafka://broker/topic       a Kafka topic, through the kcat producer
+  tcp://host:port            the asciicast stream, like --relay
+It may be repeated, or set with transports in the [record] config section.
+
+--compress zstd writes the recording with streaming zstd compression as
+<filename>.zst, which every reading command opens transparently. Set
+compress = zstd in the [record] config section to make it the default.
+
+--max-duration and --max-size finish the cast file once its recorded time
+or size (e.g. 500K, 10M, 1G) goes over the limit. With --rotate the
+recording goes on in demo.2.cast, demo.3.cast and so on, all in one series
+so 'play --series' plays them as one; without it the session is hung up.
+Both can be set with max_duration, max_size and rotate in the [record]
+config section, e.g. for always-on shell recording.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runRec,
 }
@@ -26,11 +77,32 @@ var (
 	recAppend        bool
 	recCommand       string
 	recTitle         string
+	recSeries        string
 	recIdleTimeLimit float64
 	recCols          int
 	recRows          int
 	recQuiet         bool
 	recOverwrite     bool
+	recNotifyMarkers string
+	recCaptureEnvExt bool
+	recCaptureTheme  bool
+	recSecretScan    string
+	recPauseOnLock   bool
+	recIndicator     string
+	recAudio         string
+	recHeadless      bool
+	recMirror        bool
+	recRaw           bool
+	recRawLog        string
+	recRelay         string
+	recTransports    []string
+	recEnv           []string
+	recCompress      string
+	recFlushInterval time.Duration
+	recFsync         bool
+	recMaxDuration   time.Duration
+	recMaxSize       string
+	recRotate        bool
 )
 
 func init() {
@@ -38,32 +110,85 @@ func init() {
 
 	recCmd.Flags().BoolVar(&recStdin, "stdin", false, "Enable stdin recording")
 	recCmd.Flags().BoolVar(&recAppend, "append", false, "Append to existing recording")
-	recCmd.Flags().StringVarP(&recCommand, "command", "c", "", "Command to record (default: $SHELL)")
+	recCmd.Flags().StringVarP(&recCommand, "command", "c", "", "Command line to record, run with sh -c (default: $SHELL)")
 	recCmd.Flags().StringVarP(&recTitle, "title", "t", "", "Title of the recording")
+	recCmd.Flags().StringVar(&recSeries, "series", "", "Series this recording belongs to (e.g. one part of a tutorial)")
 	recCmd.Flags().Float64VarP(&recIdleTimeLimit, "idle-time-limit", "i", 0, "Limit recorded idle time to given seconds")
 	recCmd.Flags().IntVar(&recCols, "cols", 0, "Override terminal columns")
 	recCmd.Flags().IntVar(&recRows, "rows", 0, "Override terminal rows")
 	recCmd.Flags().BoolVarP(&recQuiet, "quiet", "q", false, "Quiet mode (suppress notices)")
 	recCmd.Flags().BoolVarP(&recOverwrite, "overwrite", "y", false, "Overwrite existing file without asking")
+	recCmd.Flags().StringArrayVar(&recEnv, "env", nil, "Environment variables to record: NAME or PATTERN to add, -NAME or -PATTERN to drop, after the env list in config (repeatable, comma-separated)")
+	recCmd.Flags().BoolVar(&recCaptureEnvExt, "capture-env-extended", false, "Record terminal capabilities (COLORTERM, LANG, truecolor) in the header")
+	recCmd.Flags().BoolVar(&recCaptureTheme, "capture-theme", false, "Ask the terminal for its colors and record them as the theme")
+	recCmd.Flags().StringVar(&recSecretScan, "secret-scan", "", "Scan output for secrets: mask (redact before writing) or warn (add a marker)")
+	recCmd.Flags().BoolVar(&recPauseOnLock, "pause-on-lock", false, "Pause the recording clock while the screen is locked or the machine sleeps")
+	recCmd.Flags().StringVar(&recAudio, "audio", "", "Record audio narration to this file alongside the session")
+	recCmd.Flags().BoolVar(&recHeadless, "headless", false, "Record without a terminal (cron, CI): no raw mode, input or resizing")
+	recCmd.Flags().BoolVar(&recMirror, "mirror", false, "With --headless, also copy the session output to stdout")
+	recCmd.Flags().BoolVar(&recRaw, "raw", false, "Write the raw output stream to the file instead of a cast (no timing)")
+	recCmd.Flags().StringVar(&recRawLog, "raw-log", "", "Also write the raw output stream to this file")
+	recCmd.Flags().StringVar(&recRelay, "relay", "", "Also stream the recording to this TCP address (host:port)")
+	recCmd.Flags().StringArrayVar(&recTransports, "transport", nil, "Also ship events to a collector: udp://, syslog:[//host], kafka://broker/topic or tcp:// (repeatable)")
+	recCmd.Flags().StringVar(&recCompress, "compress", "", "Compress the recording as it is written: zstd (adds .zst to the filename)")
+	recCmd.Flags().DurationVar(&recFlushInterval, "flush-interval", 0, "How often to write buffered events to disk (default 1s)")
+	recCmd.Flags().BoolVar(&recFsync, "fsync", false, "Commit the recording to stable storage at every flush")
+	recCmd.Flags().DurationVar(&recMaxDuration, "max-duration", 0, "Finish the recording once its recorded time goes over this (e.g. 1h)")
+	recCmd.Flags().StringVar(&recMaxSize, "max-size", "", "Finish the recording once the file goes over this size (e.g. 10M)")
+	recCmd.Flags().BoolVar(&recRotate, "rotate", false, "At --max-duration or --max-size, continue in a new file instead of ending the session")
+	recCmd.Flags().StringVar(&recIndicator, "indicator", "", "Show elapsed time and state while recording: title (window title) or status (status line)")
+	recCmd.Flags().StringVar(&recNotifyMarkers, "notify-markers", "", "Record notifications as markers: osc (OSC 9/777) or all (also bells)")
 }
 
 func runRec(cmd *cobra.Command, args []string) error {
+	if !recHeadless {
+		if code, ok := superviseTerminal(); ok {
+			os.Exit(code)
+		}
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	if recCompress == "" {
+		recCompress = cfg.Record.Compress
+	}
+	switch recCompress {
+	case "", "no", "off", "none":
+		recCompress = asciicast.CompressNone
+	case asciicast.CompressZstd:
+	default:
+		return fmt.Errorf("invalid --compress value %q (expected zstd)", recCompress)
+	}
+
+	if recRaw {
+		if len(args) == 0 {
+			return fmt.Errorf("--raw needs a filename")
+		}
+		if cmd.Flags().Changed("compress") {
+			return fmt.Errorf("--raw can't be combined with --compress")
+		}
+		recCompress = asciicast.CompressNone
+	}
+
 	// Determine filename
 	var filename string
 	if len(args) > 0 {
 		filename = args[0]
+		if recCompress == asciicast.CompressZstd && !asciicast.IsCompressedName(filename) {
+			filename += asciicast.ZstdExt
+		}
 	} else {
-		// Generate temporary filename
-		filename = fmt.Sprintf("/tmp/goasciinema-%d.cast", time.Now().Unix())
+		filename, err = createTempRecording(cfg, recCompress)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Check if file exists
-	if !recAppend && !recOverwrite {
+	if len(args) > 0 && !recAppend && !recOverwrite {
 		if _, err := os.Stat(filename); err == nil {
 			fmt.Fprintf(os.Stderr, "File %s already exists. Use --overwrite to overwrite.\n", filename)
 			return nil
@@ -74,27 +199,150 @@ func runRec(cmd *cobra.Command, args []string) error {
 	if recCommand == "" {
 		recCommand = cfg.Record.Command
 	}
+	if recHeadless && recCommand == "" {
+		return fmt.Errorf("--headless needs a command to record (-c)")
+	}
+	if recCols == 0 {
+		recCols = cfg.Record.Cols
+	}
+	if recRows == 0 {
+		recRows = cfg.Record.Rows
+	}
 	if recIdleTimeLimit == 0 {
 		recIdleTimeLimit = cfg.Record.IdleTimeLimit
 	}
 	if !recStdin {
 		recStdin = cfg.Record.Stdin
 	}
+	if !recCaptureEnvExt {
+		recCaptureEnvExt = cfg.Record.CaptureEnvExtended
+	}
+	if !recCaptureTheme {
+		recCaptureTheme = cfg.Record.CaptureTheme
+	}
+	if !recPauseOnLock {
+		recPauseOnLock = cfg.Record.PauseOnLock
+	}
+	if recFlushInterval == 0 {
+		recFlushInterval = cfg.Record.FlushInterval
+	}
+	if recFlushInterval < 0 {
+		return fmt.Errorf("--flush-interval must not be negative")
+	}
+	if !recFsync {
+		recFsync = cfg.Record.Fsync
+	}
+
+	if recMaxDuration == 0 {
+		recMaxDuration = cfg.Record.MaxDuration
+	}
+	if recMaxDuration < 0 {
+		return fmt.Errorf("--max-duration must not be negative")
+	}
+	if recMaxSize == "" {
+		recMaxSize = cfg.Record.MaxSize
+	}
+	var maxSize int64
+	if recMaxSize != "" {
+		if maxSize, err = parseSize(recMaxSize); err != nil {
+			return fmt.Errorf("invalid --max-size value: %w", err)
+		}
+	}
+	if !recRotate {
+		recRotate = cfg.Record.Rotate
+	}
+	if recRotate && recMaxDuration == 0 && maxSize == 0 {
+		return fmt.Errorf("--rotate needs --max-duration or --max-size")
+	}
+	if recRaw && (recMaxDuration > 0 || maxSize > 0) {
+		return fmt.Errorf("--raw can't be combined with --max-duration or --max-size")
+	}
+	// Rotated parts share a series, so they can be played as one
+	if recRotate && recSeries == "" {
+		recSeries = recordingBaseName(filename)
+	}
+	if recNotifyMarkers == "" {
+		recNotifyMarkers = cfg.Record.NotifyMarkers
+	}
+	switch recNotifyMarkers {
+	case "", "no", "off":
+		recNotifyMarkers = ""
+	case recorder.NotifyMarkersOSC, recorder.NotifyMarkersAll:
+	default:
+		return fmt.Errorf("invalid --notify-markers value %q (expected osc or all)", recNotifyMarkers)
+	}
+
+	if recSecretScan == "" {
+		recSecretScan = cfg.Record.SecretScan
+	}
+	switch recSecretScan {
+	case "", "no", "off":
+		recSecretScan = ""
+	case recorder.SecretScanMask, recorder.SecretScanWarn:
+	default:
+		return fmt.Errorf("invalid --secret-scan value %q (expected mask or warn)", recSecretScan)
+	}
+	if recRaw && recSecretScan == recorder.SecretScanMask {
+		return fmt.Errorf("--raw output can't be masked; use --secret-scan warn or off")
+	}
+
+	if recIndicator == "" {
+		recIndicator = cfg.Record.Indicator
+	}
+	switch recIndicator {
+	case "", "no", "off":
+		recIndicator = ""
+	case recorder.IndicatorTitle, recorder.IndicatorStatus:
+	default:
+		return fmt.Errorf("invalid --indicator value %q (expected title or status)", recIndicator)
+	}
+
+	if len(recTransports) == 0 {
+		recTransports = cfg.Record.Transports
+	}
+	for _, transport := range recTransports {
+		if _, err := recorder.ParseTransport(transport); err != nil {
+			return err
+		}
+	}
 
 	if !recQuiet && !cfg.Record.Quiet {
 		fmt.Fprintf(os.Stderr, "Recording terminal session to %s\n", filename)
-		fmt.Fprintf(os.Stderr, "Press Ctrl+D or type 'exit' to end recording.\n")
+		if !recHeadless {
+			fmt.Fprintf(os.Stderr, "Press Ctrl+D or type 'exit' to end recording.\n")
+		}
 	}
 
 	// Create recorder
 	rec := recorder.New(recorder.Options{
-		Command:       recCommand,
-		Title:         recTitle,
-		IdleTimeLimit: recIdleTimeLimit,
-		RecordStdin:   recStdin,
-		Append:        recAppend,
-		Cols:          recCols,
-		Rows:          recRows,
+		Command:            recCommand,
+		Title:              recTitle,
+		Series:             recSeries,
+		MaxDuration:        recMaxDuration,
+		MaxSize:            maxSize,
+		Rotate:             recRotate,
+		IdleTimeLimit:      recIdleTimeLimit,
+		RecordStdin:        recStdin,
+		Append:             recAppend,
+		Cols:               recCols,
+		Rows:               recRows,
+		Env:                envRules(cfg.Record.Env, recEnv),
+		NotifyMarkers:      recNotifyMarkers,
+		SecretScan:         recSecretScan,
+		CaptureEnvExtended: recCaptureEnvExt,
+		CaptureTheme:       recCaptureTheme,
+		PauseOnLock:        recPauseOnLock,
+		Indicator:          recIndicator,
+		Audio:              recAudio,
+		AudioCommand:       cfg.Record.AudioCommand,
+		Headless:           recHeadless,
+		Mirror:             recMirror,
+		Raw:                recRaw,
+		RawLog:             recRawLog,
+		Relay:              recRelay,
+		Transports:         recTransports,
+		FlushInterval:      recFlushInterval,
+		Fsync:              recFsync,
 	})
 
 	// Start recording
@@ -103,9 +351,211 @@ func runRec(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("recording failed: %w", err)
 	}
 
-	if !recQuiet && !cfg.Record.Quiet {
+	// Without a filename, ask what to do with the temporary recording
+	files := rec.Files()
+	if len(files) > 1 {
+		filename = strings.Join(files, ", ")
+	}
+	if len(args) == 0 && len(files) <= 1 && !recHeadless && tty.IsTerminal(tty.GetStdinFd()) {
+		fmt.Fprintf(os.Stderr, "\nRecording finished.\n")
+		if err := afterRecording(filename); err != nil {
+			return err
+		}
+	} else if !recQuiet && !cfg.Record.Quiet {
 		fmt.Fprintf(os.Stderr, "\nRecording finished. Saved to %s\n", filename)
 	}
 
+	// Exit like the recorded command, so wrappers can check how it went
+	if code := rec.ExitCode(); code != 0 {
+		os.Exit(code)
+	}
 	return nil
 }
+
+// afterRecording asks what to do with a recording made without a
+// filename: save it, upload it or discard it. A title can be given first,
+// unless --title was.
+func afterRecording(filename string) error {
+	in := bufio.NewReader(os.Stdin)
+	if recTitle == "" {
+		if title, _ := prompt(in, "Title (Enter to skip): "); title != "" {
+			header, events, err := loadEvents(filename)
+			if err != nil {
+				return err
+			}
+			header.Title = title
+			if err := replaceRecording(filename, header, events); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		answer, err := prompt(in, "(s)ave, (u)pload or (d)iscard? [s] ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nSaved to %s\n", filename)
+			return nil
+		}
+		switch strings.ToLower(answer) {
+		case "", "s", "save":
+			return saveRecording(in, filename)
+		case "u", "upload":
+			if err := runUpload(uploadCmd, []string{filename}); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "The recording is also saved at %s\n", filename)
+			return nil
+		case "d", "discard":
+			if err := os.Remove(filename); err != nil {
+				return fmt.Errorf("failed to remove recording: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "Discarded the recording\n")
+			return nil
+		}
+	}
+}
+
+// saveRecording asks where to keep a temporary recording and moves it
+// there. The default keeps it where it is.
+func saveRecording(in *bufio.Reader, filename string) error {
+	for {
+		path, err := prompt(in, fmt.Sprintf("Save to [%s]: ", filename))
+		if err != nil || path == "" || path == filename {
+			fmt.Fprintf(os.Stderr, "Saved to %s\n", filename)
+			return nil
+		}
+		if asciicast.IsCompressedName(filename) && !asciicast.IsCompressedName(path) {
+			path += asciicast.ZstdExt
+		}
+		if _, err := os.Stat(path); err == nil {
+			fmt.Fprintf(os.Stderr, "%s already exists\n", path)
+			continue
+		}
+		if err := moveFile(filename, path); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Saved to %s\n", path)
+		return nil
+	}
+}
+
+// moveFile renames a file, copying it when the destination is on another
+// file system
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return fmt.Errorf("failed to read recording: %w", err)
+	}
+	if err := os.WriteFile(to, data, 0600); err != nil {
+		return fmt.Errorf("failed to save recording: %w", err)
+	}
+	return os.Remove(from)
+}
+
+// prompt writes a question to stderr and reads a line of answer
+func prompt(in *bufio.Reader, question string) (string, error) {
+	fmt.Fprint(os.Stderr, question)
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// sizeUnits are the suffixes accepted by parseSize
+var sizeUnits = map[string]int64{
+	"":  1,
+	"B": 1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+}
+
+// parseSize parses a byte count with an optional K, M or G suffix (powers
+// of 1024, with an optional trailing B or iB), like 500K or 1.5GB
+func parseSize(value string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	number := strings.TrimRight(upper, "KMGIB")
+	unit := strings.TrimSuffix(strings.TrimSuffix(upper[len(number):], "B"), "I")
+	scale, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit in %q (expected K, M or G)", value)
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not a positive size", value)
+	}
+	return int64(n * float64(scale)), nil
+}
+
+// recordingBaseName returns a recording's file name without its directory
+// and extensions, like demo for /tmp/demo.cast.zst
+func recordingBaseName(filename string) string {
+	name := strings.TrimSuffix(filepath.Base(filename), asciicast.ZstdExt)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// envRules appends the --env rules, which may be comma-separated, to the
+// configured env list
+func envRules(configured, flags []string) []string {
+	rules := append([]string{}, configured...)
+	for _, flag := range flags {
+		for _, rule := range strings.Split(flag, ",") {
+			if rule = strings.TrimSpace(rule); rule != "" {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return rules
+}
+
+// tempRecordingPattern names recordings created without a filename
+const tempRecordingPattern = "goasciinema-*.cast"
+
+// createTempRecording creates a private temporary recording file, first
+// removing temporary recordings older than the configured maximum age
+func createTempRecording(cfg *config.Config, compress string) (string, error) {
+	dir := cfg.RecordTempDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	if cfg.Record.TmpMaxAge > 0 {
+		cleanTempRecordings(dir, cfg.Record.TmpMaxAge)
+	}
+
+	pattern := tempRecordingPattern
+	if compress == asciicast.CompressZstd {
+		pattern += asciicast.ZstdExt
+	}
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	file.Close()
+	return file.Name(), nil
+}
+
+// cleanTempRecordings removes temporary recordings in dir last modified
+// more than maxAge ago
+func cleanTempRecordings(dir string, maxAge time.Duration) {
+	matches, err := filepath.Glob(filepath.Join(dir, tempRecordingPattern+"*"))
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-maxAge)
+	for _, path := range matches {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove old recording %s: %v\n", path, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ober/goasciinema/internal/config"
+	"github.com/ober/goasciinema/internal/redact"
+	"github.com/spf13/cobra"
+)
+
+var (
+	redactOutput   string
+	redactPatterns []string
+)
+
+var redactCmd = &cobra.Command{
+	Use:   "redact <filename>",
+	Short: "Mask secrets in a recording",
+	Long: `Rewrite a recording with secrets in its output and input replaced by ****.
+Event timing is left unchanged.
+
+Well-known credential formats (AWS keys, GitHub and Slack tokens, private
+keys, ...) are always redacted, as is input typed at a password prompt such
+as sudo's. Add more regular expressions with --pattern, or with "pattern ="
+lines in the [redact] config section. Secrets split over several events
+are found too.
+
+The file is rewritten in place unless --output is given.
+
+Example:
+  goasciinema redact demo.cast -o demo-public.cast --pattern 'db-[0-9a-f]{32}'`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRedact,
+}
+
+func init() {
+	rootCmd.AddCommand(redactCmd)
+	redactCmd.Flags().StringVarP(&redactOutput, "output", "o", "", "Output file (default: rewrite in place)")
+	redactCmd.Flags().StringArrayVar(&redactPatterns, "pattern", nil, "Regular expression to redact (repeatable)")
+}
+
+func runRedact(cmd *cobra.Command, args []string) error {
+	filename := args[0]
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	redactor, err := redact.New(append(cfg.Redact.Patterns, redactPatterns...))
+	if err != nil {
+		return err
+	}
+
+	header, events, err := loadEvents(filename)
+	if err != nil {
+		return err
+	}
+	events, count := redactor.Redact(events)
+
+	output := redactOutput
+	if output == "" {
+		output = filename
+	}
+	if err := replaceRecording(output, header, events); err != nil {
+		return err
+	}
+
+	fmt.Printf("Redacted %d secret(s) in %s\n", count, output)
+	return nil
+}
@@ -0,0 +1,487 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/ober/goasciinema/internal/database"
+	"github.com/ober/goasciinema/internal/raster"
+	"github.com/ober/goasciinema/internal/vt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	renderWidth  int
+	renderHeight int
+
+	renderAll    bool
+	renderFromDB bool
+	renderSeries string
+	renderSearch string
+	renderFormat string
+	renderOutDir string
+	renderJobs   int
+
+	renderOutput string
+	renderAudio  string
+	renderFPS    int
+	renderTheme  string
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Render recordings through a terminal emulator",
+	Long: `Render a recording by replaying it through a virtual terminal,
+producing the text the viewer would actually have seen.
+
+With --from-db, every session in the database (--all), a series
+(--series), or the sessions matching a search (--search) are rendered
+into --out-dir in parallel, for building a browsable archive.
+
+Example:
+  goasciinema render --from-db --all --format text --out-dir renders/`,
+	Args: cobra.NoArgs,
+	RunE: runRenderBatch,
+}
+
+// renderFormatter renders an emulated recording to a file. Animated
+// formats set RenderEvents instead, to replay the events themselves.
+type renderFormatter struct {
+	Ext          string
+	Render       func(term *vt.Terminal, w io.Writer) error
+	RenderEvents func(header asciicast.Header, events []asciicast.Event, w io.Writer) error
+}
+
+// renderFormats are the output formats supported by batch rendering
+var renderFormats = map[string]renderFormatter{
+	"text": {Ext: ".txt", Render: renderTranscript},
+	"ansi": {Ext: ".ans", Render: renderANSIScreen},
+	"gif":  {Ext: ".gif", RenderEvents: renderGIF},
+}
+
+var renderTextCmd = &cobra.Command{
+	Use:   "text <filename>",
+	Short: "Render the final terminal text of a recording",
+	Long: `Render the scrollback and final screen of a recording as plain text.
+
+Unlike 'cat', cursor movement, overwrites and screen clears are applied,
+so the output matches what was on screen.
+
+--width re-flows the text to a different column count, re-wrapping lines
+that were soft-wrapped at the recording's terminal width. --height limits
+the output to the last N rows, i.e. the visible screen at that height.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRenderText,
+}
+
+var renderVideoCmd = &cobra.Command{
+	Use:   "video <filename>",
+	Short: "Render a recording as a video",
+	Long: `Render a recording as an MP4 video with ffmpeg, in real time and in the
+recording's own theme unless --theme is given.
+
+--audio muxes in narration recorded with 'rec --audio'. It is aligned
+with the recording by the "audio start" and "audio sync" markers rec
+writes, so narration stays in step across pauses and idle time cut by
+--idle-time-limit.
+
+Example:
+  goasciinema render video demo.cast --audio demo.ogg -o demo.mp4`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRenderVideo,
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+	renderCmd.AddCommand(renderTextCmd)
+	renderCmd.AddCommand(renderVideoCmd)
+
+	renderTextCmd.Flags().IntVar(&renderWidth, "width", 0, "Re-flow output to this many columns (default: recording width)")
+	renderTextCmd.Flags().IntVar(&renderHeight, "height", 0, "Only output the last N rows (default: everything)")
+
+	renderVideoCmd.Flags().StringVarP(&renderOutput, "output", "o", "", "Output file (default: the recording's name with .mp4)")
+	renderVideoCmd.Flags().StringVar(&renderAudio, "audio", "", "Narration to mux in, as recorded by rec --audio")
+	renderVideoCmd.Flags().IntVar(&renderFPS, "fps", 30, "Frames per second")
+	renderVideoCmd.Flags().StringVar(&renderTheme, "theme", "", "Theme: "+strings.Join(raster.ThemeNames(), ", ")+", or bg,fg,palette... in hex (default: the recording's, or "+raster.DefaultTheme+")")
+
+	renderCmd.Flags().BoolVar(&renderAll, "all", false, "Render every session (with --from-db)")
+	renderCmd.Flags().BoolVar(&renderFromDB, "from-db", false, "Render sessions from the database")
+	renderCmd.Flags().StringVar(&renderSeries, "series", "", "Render the sessions of a series")
+	renderCmd.Flags().StringVar(&renderSearch, "search", "", "Render the sessions matching a search term")
+	renderCmd.Flags().StringVar(&renderFormat, "format", "text", "Output format: "+strings.Join(renderFormatNames(), ", "))
+	renderCmd.Flags().StringVar(&renderOutDir, "out-dir", "renders", "Directory to write rendered files to")
+	renderCmd.Flags().IntVarP(&renderJobs, "jobs", "j", runtime.NumCPU(), "Number of recordings rendered in parallel")
+}
+
+// renderFormatNames returns the supported format names in sorted order
+func renderFormatNames() []string {
+	var names []string
+	for name := range renderFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// emulate replays a recording's output and resize events up to at
+// seconds (the whole recording if at is negative) through a virtual
+// terminal
+func emulate(filename string, at float64) (*vt.Terminal, asciicast.Header, error) {
+	reader, err := asciicast.Open(filename)
+	if err != nil {
+		return nil, asciicast.Header{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer reader.Close()
+
+	term := vt.New(reader.Header.Width, reader.Header.Height)
+	for reader.Next() {
+		event := reader.Event()
+		if at >= 0 && event.Time > at {
+			break
+		}
+		switch event.Type {
+		case asciicast.EventTypeOutput:
+			term.Write(event.Data)
+		case asciicast.EventTypeResize:
+			if cols, rows, ok := asciicast.ParseResize(event.Data); ok {
+				term.Resize(cols, rows)
+			}
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return nil, asciicast.Header{}, err
+	}
+
+	return term, reader.Header, nil
+}
+
+func runRenderText(cmd *cobra.Command, args []string) error {
+	if renderWidth < 0 || renderHeight < 0 {
+		return fmt.Errorf("--width and --height must not be negative")
+	}
+
+	term, _, err := emulate(args[0], -1)
+	if err != nil {
+		return fmt.Errorf("render failed: %w", err)
+	}
+
+	var lines []string
+	if renderWidth > 0 {
+		lines = vt.Reflow(vt.LogicalLines(term.Transcript()), renderWidth)
+	} else {
+		for _, l := range term.Transcript() {
+			lines = append(lines, l.Text())
+		}
+	}
+
+	if renderHeight > 0 && len(lines) > renderHeight {
+		lines = lines[len(lines)-renderHeight:]
+	}
+
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// renderTranscript writes the scrollback and final screen as plain text
+func renderTranscript(term *vt.Terminal, w io.Writer) error {
+	for _, l := range term.Transcript() {
+		if _, err := fmt.Fprintln(w, l.Text()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderANSIScreen writes the final screen with its colors and attributes
+func renderANSIScreen(term *vt.Terminal, w io.Writer) error {
+	width, _ := term.Size()
+	for _, l := range term.Lines() {
+		if _, err := fmt.Fprintln(w, l.ANSI(width)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderGIF writes the recording as an animated GIF with export's default
+// settings, in the recording's own theme
+func renderGIF(header asciicast.Header, events []asciicast.Event, w io.Writer) error {
+	theme, err := pickTheme("", header)
+	if err != nil {
+		return err
+	}
+	idleTimeLimit := header.IdleTimeLimit
+	if idleTimeLimit <= 0 {
+		idleTimeLimit = defaultGIFIdleTimeLimit
+	}
+	return raster.NewRenderer(theme, 2).WriteGIF(w, header, events, raster.GIFOptions{
+		FPS:           30,
+		Speed:         1,
+		IdleTimeLimit: idleTimeLimit,
+		LastFrame:     3,
+	})
+}
+
+func runRenderVideo(cmd *cobra.Command, args []string) error {
+	filename := args[0]
+	if renderFPS < 1 {
+		return fmt.Errorf("--fps must be at least 1")
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return fmt.Errorf("ffmpeg is needed to render video: %w", err)
+	}
+	if renderAudio != "" {
+		if _, err := os.Stat(renderAudio); err != nil {
+			return fmt.Errorf("failed to read audio: %w", err)
+		}
+	}
+
+	header, events, err := loadEvents(filename)
+	if err != nil {
+		return err
+	}
+	theme, err := pickTheme(renderTheme, header)
+	if err != nil {
+		return err
+	}
+	output := renderOutput
+	if output == "" {
+		output = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".mp4"
+	}
+
+	renderer := raster.NewRenderer(theme, 2)
+	width, height := renderer.VideoSize(header, events)
+	ffmpeg := exec.Command("ffmpeg", videoArgs(width, height, events, output)...)
+	var stderr bytes.Buffer
+	ffmpeg.Stderr = &stderr
+	frames, err := ffmpeg.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	if err := ffmpeg.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	// If ffmpeg fails, writing frames fails too; its own error says more
+	duration, writeErr := renderer.WriteFrames(frames, header, events, raster.VideoOptions{FPS: renderFPS, LastFrame: 1})
+	frames.Close()
+	if err := ffmpeg.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("ffmpeg failed: %v: %s", err, msg)
+		}
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	if writeErr != nil {
+		return fmt.Errorf("failed to write frames: %w", writeErr)
+	}
+
+	fmt.Printf("Rendered %s (%s) to %s\n", filepath.Base(filename), formatOffset(duration), output)
+	return nil
+}
+
+// videoArgs returns the ffmpeg arguments that encode raw frames from stdin
+// into output, muxing in --audio aligned by the recording's markers
+func videoArgs(width, height int, events []asciicast.Event, output string) []string {
+	args := []string{"-nostdin", "-loglevel", "error", "-y",
+		"-f", "rawvideo", "-pix_fmt", "rgba", "-s", fmt.Sprintf("%dx%d", width, height),
+		"-r", strconv.Itoa(renderFPS), "-i", "-"}
+	// H.264 in yuv420p, which players expect, needs even dimensions
+	const evenSize = "pad=ceil(iw/2)*2:ceil(ih/2)*2"
+	if renderAudio == "" {
+		args = append(args, "-vf", evenSize)
+	} else {
+		// Padding the audio with silence and stopping at the shortest
+		// stream ends the video with the recording, whichever is longer
+		graph := "[0:v]" + evenSize + "[vout];" + audioFilter(audioSyncPoints(events)) + ",apad[aout]"
+		args = append(args, "-i", renderAudio, "-filter_complex", graph,
+			"-map", "[vout]", "-map", "[aout]", "-shortest", "-c:a", "aac")
+	}
+	return append(args, "-c:v", "libx264", "-pix_fmt", "yuv420p", output)
+}
+
+// audioSyncPoint pairs a time in the recording with the position in the
+// narration recorded at that moment
+type audioSyncPoint struct {
+	at, audio float64
+}
+
+// audioSyncPoints returns the points rec --audio marked with its "audio
+// start" and "audio sync <position>" markers. Both clocks run at the same
+// rate between two points; rec adds a point wherever the recording clock
+// skips ahead of or behind the audio, at pauses and cut idle time.
+func audioSyncPoints(events []asciicast.Event) []audioSyncPoint {
+	var points []audioSyncPoint
+	for _, event := range events {
+		if event.Type != asciicast.EventTypeMarker {
+			continue
+		}
+		if position, ok := strings.CutPrefix(event.Data, "audio sync "); ok {
+			if audio, err := strconv.ParseFloat(position, 64); err == nil {
+				points = append(points, audioSyncPoint{at: event.Time, audio: audio})
+			}
+		} else if event.Data == "audio start" {
+			points = append(points, audioSyncPoint{at: event.Time})
+		}
+	}
+	if len(points) == 0 {
+		return []audioSyncPoint{{}}
+	}
+	// Narration from before the first point plays up to it
+	shift := min(points[0].at, points[0].audio)
+	points[0].at -= shift
+	points[0].audio -= shift
+	return points
+}
+
+// audioFilter returns an ffmpeg filter graph that lays out the narration
+// in input 1 along the recording: the audio from each sync point plays
+// until the next point's time in the recording, and whatever it runs
+// over or under by is cut or filled with silence
+func audioFilter(points []audioSyncPoint) string {
+	// Points that are followed at the same time by another have nothing
+	// to play
+	var kept []audioSyncPoint
+	for i, p := range points {
+		if i+1 == len(points) || points[i+1].at > p.at {
+			kept = append(kept, p)
+		}
+	}
+
+	var graph, split, segments strings.Builder
+	fmt.Fprintf(&split, "[1:a]asplit=%d", len(kept))
+	for i, p := range kept {
+		fmt.Fprintf(&split, "[s%d]", i)
+		fmt.Fprintf(&graph, "[s%d]atrim=start=%.3f", i, p.audio)
+		if i+1 < len(kept) {
+			length := kept[i+1].at - p.at
+			fmt.Fprintf(&graph, ":end=%.3f,asetpts=PTS-STARTPTS,apad=whole_dur=%.3f", p.audio+length, length)
+		} else {
+			graph.WriteString(",asetpts=PTS-STARTPTS")
+		}
+		fmt.Fprintf(&graph, "[a%d];", i)
+		fmt.Fprintf(&segments, "[a%d]", i)
+	}
+	return fmt.Sprintf("%s;%s%sconcat=n=%d:v=0:a=1,adelay=delays=%d:all=1",
+		split.String(), graph.String(), segments.String(), len(kept), int64(math.Round(kept[0].at*1000)))
+}
+
+func runRenderBatch(cmd *cobra.Command, args []string) error {
+	if !renderFromDB {
+		return cmd.Help()
+	}
+
+	formatter, ok := renderFormats[renderFormat]
+	if !ok {
+		return fmt.Errorf("unknown --format %q (expected one of: %s)", renderFormat, strings.Join(renderFormatNames(), ", "))
+	}
+	if renderJobs < 1 {
+		renderJobs = 1
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	files, err := renderSelection(db)
+	db.Close()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Println("No sessions to render.")
+		return nil
+	}
+
+	if err := os.MkdirAll(renderOutDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var done, failed int
+	var wg sync.WaitGroup
+	for i := 0; i < renderJobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				out := filepath.Join(renderOutDir, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))+formatter.Ext)
+				err := renderFile(file, out, formatter)
+
+				mu.Lock()
+				done++
+				if err != nil {
+					failed++
+					fmt.Fprintf(os.Stderr, "[%d/%d] Warning: failed to render %s: %v\n", done, len(files), file, err)
+				} else {
+					fmt.Fprintf(os.Stderr, "[%d/%d] %s -> %s\n", done, len(files), filepath.Base(file), out)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, file := range files {
+		jobs <- file
+	}
+	close(jobs)
+	wg.Wait()
+
+	fmt.Printf("\nSummary: %d rendered, %d failed\n", len(files)-failed, failed)
+	return nil
+}
+
+// renderSelection returns the database files chosen by --all, --series or
+// --search
+func renderSelection(db *database.DB) ([]string, error) {
+	switch {
+	case renderAll:
+		return db.Files()
+	case renderSeries != "":
+		return db.SeriesFiles(renderSeries)
+	case renderSearch != "":
+		return db.MatchingFiles(renderSearch)
+	default:
+		return nil, fmt.Errorf("choose sessions with --all, --series or --search")
+	}
+}
+
+// renderFile emulates one recording and writes it in the given format
+func renderFile(filename, out string, formatter renderFormatter) error {
+	var render func(w io.Writer) error
+	if formatter.RenderEvents != nil {
+		header, events, err := loadEvents(filename)
+		if err != nil {
+			return err
+		}
+		render = func(w io.Writer) error { return formatter.RenderEvents(header, events, w) }
+	} else {
+		term, _, err := emulate(filename, -1)
+		if err != nil {
+			return err
+		}
+		render = func(w io.Writer) error { return formatter.Render(term, w) }
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if err := render(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/spf13/cobra"
+)
+
+var repairOutput string
+
+var repairCmd = &cobra.Command{
+	Use:   "repair <filename>",
+	Short: "Finalize a recording that was interrupted",
+	Long: `Rewrite a recording left behind by a recorder that crashed or was
+killed: the partly written last event is dropped and the duration is set
+from the last complete event.
+
+Interrupted recordings can be played and processed as they are, since the
+reader already skips the partial event; repair makes the file itself
+valid again, for uploading or appending.
+
+The file is rewritten in place unless --output is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRepair,
+}
+
+func init() {
+	rootCmd.AddCommand(repairCmd)
+	repairCmd.Flags().StringVarP(&repairOutput, "output", "o", "", "Output file (default: rewrite in place)")
+}
+
+func runRepair(cmd *cobra.Command, args []string) error {
+	filename := args[0]
+
+	reader, err := asciicast.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	var events []asciicast.Event
+	for reader.Next() {
+		events = append(events, reader.Event())
+	}
+	reader.Close()
+	if err := reader.Err(); err != nil {
+		return fmt.Errorf("failed to read event: %w", err)
+	}
+
+	header := reader.Header
+	var end float64
+	if len(events) > 0 {
+		end = events[len(events)-1].Time
+	}
+	if !reader.Truncated() && header.Duration >= end {
+		fmt.Printf("%s: nothing to repair\n", filename)
+		return nil
+	}
+	header.Duration = end
+
+	output := repairOutput
+	if output == "" {
+		output = filename
+	}
+	if err := replaceRecording(output, header, events); err != nil {
+		return err
+	}
+
+	if reader.Truncated() {
+		fmt.Printf("Repaired %s: dropped the partial last event, %d event(s), %s long\n", output, len(events), formatOffset(end))
+	} else {
+		fmt.Printf("Repaired %s: set the duration, %d event(s), %s long\n", output, len(events), formatOffset(end))
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/ober/goasciinema/internal/config"
+	"github.com/ober/goasciinema/internal/database"
 	"github.com/spf13/cobra"
 )
 
@@ -13,6 +14,10 @@ var version = "1.0.0"
 // AppConfig holds the loaded configuration
 var AppConfig *config.Config
 
+// databaseFlags holds the persistent --database flag values; commands that
+// work on a single database use the first
+var databaseFlags []string
+
 var rootCmd = &cobra.Command{
 	Use:   "goasciinema",
 	Short: "Record and share terminal sessions",
@@ -45,9 +50,19 @@ func GetDefaultDatabasePath() string {
 	return "asciinema_logs.db"
 }
 
+// openDatabase opens the database selected by --database or the config
+func openDatabase() (*database.DB, error) {
+	db, err := database.Open(GetDefaultDatabasePath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.PersistentFlags().StringArrayVarP(&databaseFlags, "database", "d", nil, "SQLite database file (default: from ~/.goasciinema or ~/console-logs/asciinema_logs.db; repeatable for search)")
 }
 
 func initConfig() {
@@ -56,4 +71,7 @@ func initConfig() {
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to load config: %v\n", err)
 	}
+	if AppConfig != nil && len(databaseFlags) > 0 {
+		AppConfig.SetDatabasePath(databaseFlags[0])
+	}
 }
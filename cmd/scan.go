@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ober/goasciinema/internal/database"
+)
+
+// scannedSessions holds sessions read directly from cast files
+type scannedSessions struct {
+	Sessions   []database.SessionInfo
+	TotalBytes int64
+	TotalChars int64
+	Failed     int
+}
+
+// scanSessions reads recordings straight from disk, without a database.
+// path may be a single file or a directory of .asc/.cast files.
+func scanSessions(path string) (*scannedSessions, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("path not found: %w", err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory: %w", err)
+		}
+		files = recordingFiles(entries, path)
+	}
+
+	result := &scannedSessions{}
+	for _, file := range files {
+		fileInfo, err := os.Stat(file)
+		if err != nil {
+			result.Failed++
+			continue
+		}
+
+		header, content, err := readRecording(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", file, err)
+			result.Failed++
+			continue
+		}
+
+		var sessionTime time.Time
+		if header.Timestamp != 0 {
+			sessionTime = time.Unix(header.Timestamp, 0)
+		}
+
+		result.Sessions = append(result.Sessions, database.SessionInfo{
+			ID:           database.SessionID(filepath.Base(file)),
+			Filename:     filepath.Base(file),
+			Filepath:     file,
+			Time:         sessionTime,
+			Timezone:     header.Timezone,
+			Width:        header.Width,
+			Height:       header.Height,
+			Shell:        header.Shell,
+			ContentSize:  len(content),
+			Duration:     header.Duration,
+			IdleDuration: header.IdleDuration,
+			Markers:      header.Markers,
+			Series:       header.Series,
+			Preview:      header.Preview,
+		})
+		result.TotalBytes += fileInfo.Size()
+		result.TotalChars += int64(len(content))
+	}
+
+	return result, nil
+}
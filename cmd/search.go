@@ -1,9 +1,20 @@
 package cmd
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/ober/goasciinema/internal/asciicast"
 	"github.com/ober/goasciinema/internal/database"
 	"github.com/spf13/cobra"
 )
@@ -11,7 +22,14 @@ import (
 var (
 	searchContext  int
 	searchLimit    int
-	searchDatabase string
+	searchLinks    bool
+	searchLinkBase string
+	searchSort     string
+	searchCount    bool
+	searchMatching string
+
+	searchExport       string
+	searchExportFormat string
 )
 
 var searchCmd = &cobra.Command{
@@ -20,7 +38,19 @@ var searchCmd = &cobra.Command{
 	Long: `Search for a term in processed asciinema recordings.
 
 Returns matching lines with surrounding context, formatted in org-mode style.
-The search is case-insensitive.`,
+The search is case-insensitive.
+
+Pass --database more than once (or set search_databases in ~/.goasciinema)
+to query several databases concurrently and merge the results.
+
+--export writes the results to a file as org, json, or csv (chosen by the
+file extension or --export-format). Exports include each session's stable
+ID and the byte and time offsets of every match.
+
+--match token matches whole tokens instead of substrings. Tokens split on
+whitespace, quotes, brackets and shell operators but keep paths, flags,
+IP addresses and UUIDs intact, so "--force" does not match
+"--force-with-lease" and "10.0.0.1" does not match "10.0.0.12".`,
 	Args: cobra.ExactArgs(1),
 	RunE: runSearch,
 }
@@ -29,28 +59,69 @@ func init() {
 	rootCmd.AddCommand(searchCmd)
 	searchCmd.Flags().IntVarP(&searchContext, "context", "c", 5, "Number of context lines before/after match")
 	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 50, "Maximum number of results")
-	searchCmd.Flags().StringVarP(&searchDatabase, "database", "d", "", "SQLite database file (default: from ~/.goasciinema or ~/console-logs/asciinema_logs.db)")
+	searchCmd.Flags().StringVar(&searchSort, "sort", "filename", "Result order: filename, newest, or relevance")
+	searchCmd.Flags().BoolVar(&searchCount, "count-only", false, "Only print the number of matches")
+	searchCmd.Flags().StringVar(&searchMatching, "match", "substring", "Match mode: substring, or token for whole paths, flags, and addresses")
+	searchCmd.Flags().StringVar(&searchExport, "export", "", "Write results to a file (.org, .json, or .csv)")
+	searchCmd.Flags().StringVar(&searchExportFormat, "export-format", "", "Export format: org, json, or csv (default: from the file extension)")
+	searchCmd.Flags().BoolVar(&searchLinks, "links", false, "Print a 'serve' playback link for each match")
+	searchCmd.Flags().StringVar(&searchLinkBase, "link-base", "http://localhost:8080", "Base URL of the running 'serve' instance")
+	addTimezoneFlags(searchCmd)
 }
 
 func runSearch(cmd *cobra.Command, args []string) error {
 	term := args[0]
 
-	// Use config default if no database specified
-	dbPath := searchDatabase
-	if dbPath == "" {
-		dbPath = GetDefaultDatabasePath()
+	if err := resolveDisplayZone(); err != nil {
+		return err
 	}
 
-	// Open database
-	db, err := database.Open(dbPath)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+	order := database.SearchOrder(searchSort)
+	switch order {
+	case database.OrderFilename, database.OrderNewest, database.OrderRelevance:
+	default:
+		return fmt.Errorf("invalid --sort value %q (expected filename, newest, or relevance)", searchSort)
+	}
+
+	mode := database.MatchMode(searchMatching)
+	switch mode {
+	case database.MatchSubstring, database.MatchToken:
+	default:
+		return fmt.Errorf("invalid --match value %q (expected substring or token)", searchMatching)
 	}
-	defer db.Close()
 
-	results, err := db.Search(term, searchContext, searchLimit)
+	paths := searchDatabasePaths()
+	var dbs []*database.DB
+	for _, path := range paths {
+		db, err := database.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open database %s: %w", path, err)
+		}
+		defer db.Close()
+		dbs = append(dbs, db)
+	}
+
+	if searchCount {
+		var matches, sessions int
+		for i, db := range dbs {
+			m, s, err := db.CountMatches(term, mode)
+			if err != nil {
+				return fmt.Errorf("search failed in %s: %w", paths[i], err)
+			}
+			matches += m
+			sessions += s
+		}
+		fmt.Printf("%d match(es) in %d session(s)\n", matches, sessions)
+		return nil
+	}
+
+	results, err := searchAll(dbs, paths, term, order)
 	if err != nil {
-		return fmt.Errorf("search failed: %w", err)
+		return err
+	}
+
+	if searchExport != "" {
+		return exportSearchResults(searchExport, term, results, len(dbs) > 1)
 	}
 
 	if len(results) == 0 {
@@ -58,30 +129,343 @@ func runSearch(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	return writeOrgResults(os.Stdout, term, results, len(dbs) > 1, false)
+}
+
+// writeOrgResults writes search results as an org-mode document. With
+// offsets set, each match also records the time its line was printed.
+func writeOrgResults(w io.Writer, term string, results []searchMatch, multiDB, offsets bool) error {
 	// Org-mode header
-	fmt.Printf("#+TITLE: Search Results for \"%s\"\n", term)
-	fmt.Printf("#+DATE: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	fmt.Printf("#+RESULTS: %d match(es)\n", len(results))
-	fmt.Println()
-
-	for i, result := range results {
-		fmt.Printf("* Match %d: %s\n", i+1, result.Filename)
-		fmt.Println(":PROPERTIES:")
-		fmt.Printf(":SESSION_DATE: %s\n", result.SessionDate)
-		fmt.Printf(":LINE_NUMBER: %d\n", result.LineNumber)
+	fmt.Fprintf(w, "#+TITLE: Search Results for \"%s\"\n", term)
+	fmt.Fprintf(w, "#+DATE: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "#+RESULTS: %d match(es)\n", len(results))
+	fmt.Fprintln(w)
+
+	var offsetsByLine timeOffsets
+	if offsets || searchLinks {
+		offsetsByLine = matchOffsets(results)
+	}
+	// Notes are looked up once per file
+	notesByFile := make(map[string][]database.Annotation)
+
+	for i, match := range results {
+		result := match.SearchResult
+		fmt.Fprintf(w, "* Match %d: %s\n", i+1, result.Filename)
+		fmt.Fprintln(w, ":PROPERTIES:")
+		fmt.Fprintf(w, ":SESSION_ID: %s\n", result.ID)
+		fmt.Fprintf(w, ":SESSION_DATE: %s\n", formatSessionDate(result.Time, result.Timezone))
+		fmt.Fprintf(w, ":LINE_NUMBER: %d\n", result.LineNumber)
+		if offsets {
+			fmt.Fprintf(w, ":BYTE_OFFSET: %d\n", result.ByteOffset)
+			if offset, ok := offsetsByLine[result.Filepath][result.LineNumber]; ok {
+				fmt.Fprintf(w, ":TIME_OFFSET: %.3f\n", offset)
+			}
+		}
+		if multiDB {
+			fmt.Fprintf(w, ":DATABASE: %s\n", match.path)
+		}
 		// Truncate matched text to 80 chars
 		matchedText := result.MatchedText
 		if len(matchedText) > 80 {
 			matchedText = matchedText[:80]
 		}
-		fmt.Printf(":MATCHED_TEXT: %s\n", matchedText)
-		fmt.Println(":END:")
-		fmt.Println()
-		fmt.Println("#+begin_src shell")
-		fmt.Println(result.Context)
-		fmt.Println("#+end_src")
-		fmt.Println()
+		fmt.Fprintf(w, ":MATCHED_TEXT: %s\n", matchedText)
+		if searchLinks {
+			fmt.Fprintf(w, ":LINK: %s\n", matchLink(result, offsetsByLine))
+		}
+		fmt.Fprintln(w, ":END:")
+		fmt.Fprintln(w)
+		notes, ok := notesByFile[result.Filename]
+		if !ok {
+			var err error
+			notes, err = match.db.GetAnnotations(result.Filename)
+			if err != nil {
+				return fmt.Errorf("failed to get notes: %w", err)
+			}
+			notesByFile[result.Filename] = notes
+		}
+		if len(notes) > 0 {
+			fmt.Fprintln(w, formatNotes(notes))
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "#+begin_src shell")
+		fmt.Fprintln(w, result.Context)
+		fmt.Fprintln(w, "#+end_src")
+		fmt.Fprintln(w)
 	}
 
 	return nil
 }
+
+// exportedMatch is the JSON and CSV form of a search result
+type exportedMatch struct {
+	ID          string   `json:"id"`
+	Filename    string   `json:"filename"`
+	Filepath    string   `json:"filepath"`
+	Database    string   `json:"database,omitempty"`
+	SessionDate string   `json:"session_date"`
+	Timestamp   int64    `json:"timestamp"`
+	Timezone    string   `json:"timezone,omitempty"`
+	LineNumber  int      `json:"line_number"`
+	ByteOffset  int      `json:"byte_offset"`
+	TimeOffset  *float64 `json:"time_offset"`
+	MatchedText string   `json:"matched_text"`
+	Context     string   `json:"context"`
+}
+
+// exportSearchResults writes results to path in the format given by
+// --export-format or the file extension
+func exportSearchResults(path, term string, results []searchMatch, multiDB bool) error {
+	format := searchExportFormat
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	switch format {
+	case "org", "json", "csv":
+	default:
+		return fmt.Errorf("unknown export format %q (expected org, json, or csv)", format)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	if format == "org" {
+		err = writeOrgResults(f, term, results, multiDB, true)
+	} else {
+		offsets := matchOffsets(results)
+		var exported []exportedMatch
+		for _, match := range results {
+			r := match.SearchResult
+			e := exportedMatch{
+				ID:          r.ID,
+				Filename:    r.Filename,
+				Filepath:    r.Filepath,
+				SessionDate: formatSessionDate(r.Time, r.Timezone),
+				Timestamp:   unixTimestamp(r.Time),
+				Timezone:    r.Timezone,
+				LineNumber:  r.LineNumber,
+				ByteOffset:  r.ByteOffset,
+				MatchedText: r.MatchedText,
+				Context:     r.Context,
+			}
+			if multiDB {
+				e.Database = match.path
+			}
+			if offset, ok := offsets[r.Filepath][r.LineNumber]; ok {
+				e.TimeOffset = &offset
+			}
+			exported = append(exported, e)
+		}
+		if format == "json" {
+			err = writeJSONResults(f, exported)
+		} else {
+			err = writeCSVResults(f, exported)
+		}
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	fmt.Printf("Exported %d match(es) to %s\n", len(results), path)
+	return nil
+}
+
+// unixTimestamp returns t as a Unix timestamp, or 0 for the zero time
+func unixTimestamp(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func writeJSONResults(w io.Writer, exported []exportedMatch) error {
+	if exported == nil {
+		exported = []exportedMatch{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(exported)
+}
+
+func writeCSVResults(w io.Writer, exported []exportedMatch) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "filename", "filepath", "database", "session_date", "timestamp",
+		"timezone", "line_number", "byte_offset", "time_offset", "matched_text", "context"})
+	for _, e := range exported {
+		timeOffset := ""
+		if e.TimeOffset != nil {
+			timeOffset = strconv.FormatFloat(*e.TimeOffset, 'f', 3, 64)
+		}
+		cw.Write([]string{e.ID, e.Filename, e.Filepath, e.Database, e.SessionDate,
+			strconv.FormatInt(e.Timestamp, 10), e.Timezone, strconv.Itoa(e.LineNumber),
+			strconv.Itoa(e.ByteOffset), timeOffset, e.MatchedText, e.Context})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// searchMatch is a search result along with the database it came from
+type searchMatch struct {
+	database.SearchResult
+	db   *database.DB
+	path string
+}
+
+// searchDatabasePaths returns the databases to search: every --database
+// flag if given, otherwise the configured databases
+func searchDatabasePaths() []string {
+	if len(databaseFlags) > 0 {
+		return databaseFlags
+	}
+	if AppConfig != nil {
+		return AppConfig.GetSearchDatabasePaths()
+	}
+	return []string{GetDefaultDatabasePath()}
+}
+
+// searchAll queries every database concurrently and merges the results in
+// the requested order, keeping the first match for files present in several
+// databases and at most searchLimit results overall
+func searchAll(dbs []*database.DB, paths []string, term string, order database.SearchOrder) ([]searchMatch, error) {
+	opts := database.SearchOptions{
+		ContextLines: searchContext,
+		Limit:        searchLimit,
+		Order:        order,
+		Match:        database.MatchMode(searchMatching),
+	}
+
+	perDB := make([][]database.SearchResult, len(dbs))
+	errs := make([]error, len(dbs))
+
+	var wg sync.WaitGroup
+	for i, db := range dbs {
+		wg.Add(1)
+		go func(i int, db *database.DB) {
+			defer wg.Done()
+			perDB[i], errs[i] = db.Search(term, opts)
+		}(i, db)
+	}
+	wg.Wait()
+
+	var merged []searchMatch
+	seenIn := make(map[string]int)
+	for i, results := range perDB {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("search failed in %s: %w", paths[i], errs[i])
+		}
+		for _, r := range results {
+			if owner, ok := seenIn[r.Filename]; ok && owner != i {
+				continue
+			}
+			seenIn[r.Filename] = i
+			merged = append(merged, searchMatch{SearchResult: r, db: dbs[i], path: paths[i]})
+		}
+	}
+
+	// Results from one database are already ordered; only interleave
+	// results from different databases
+	if len(dbs) > 1 {
+		sort.SliceStable(merged, func(a, b int) bool {
+			return matchLess(merged[a], merged[b], order)
+		})
+	}
+
+	if len(merged) > searchLimit {
+		merged = merged[:searchLimit]
+	}
+	return merged, nil
+}
+
+// matchLess orders two matches from different databases
+func matchLess(a, b searchMatch, order database.SearchOrder) bool {
+	if a.Filename == b.Filename {
+		return a.LineNumber < b.LineNumber
+	}
+	switch order {
+	case database.OrderNewest:
+		if !a.Time.Equal(b.Time) {
+			return a.Time.After(b.Time)
+		}
+	case database.OrderRelevance:
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.Time.Equal(b.Time) {
+			return a.Time.After(b.Time)
+		}
+	}
+	return a.Filename < b.Filename
+}
+
+// matchLink builds a playback URL for a search result, seeking to the time
+// at which the matched line was printed when the recording is available
+func matchLink(result database.SearchResult, offsets timeOffsets) string {
+	link := fmt.Sprintf("%s/play/%s", strings.TrimRight(searchLinkBase, "/"), url.PathEscape(result.Filename))
+	if offset, ok := offsets[result.Filepath][result.LineNumber]; ok {
+		link += fmt.Sprintf("#t=%.1f", offset)
+	}
+	return link
+}
+
+// timeOffsets holds the times at which matched lines were printed, by
+// recording path and line number
+type timeOffsets map[string]map[int]float64
+
+// matchOffsets finds the time offset of every matched line, reading each
+// recording once. Recordings that can't be read have no offsets.
+func matchOffsets(results []searchMatch) timeOffsets {
+	lines := make(map[string][]int)
+	for _, match := range results {
+		lines[match.Filepath] = append(lines[match.Filepath], match.LineNumber)
+	}
+	offsets := make(timeOffsets)
+	for path, pathLines := range lines {
+		if found, err := lineOffsets(path, pathLines); err == nil {
+			offsets[path] = found
+		}
+	}
+	return offsets
+}
+
+// lineOffsets returns the time of the output event that starts each of
+// the given 1-based lines, in one pass over the recording. Stored content
+// keeps the recording's newlines, so line numbers map directly onto
+// newlines in the raw output. Lines past the end, or past an event that
+// can't be read, are left out.
+func lineOffsets(path string, lines []int) (map[int]float64, error) {
+	reader, err := asciicast.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	pending := append([]int(nil), lines...)
+	sort.Ints(pending)
+	found := make(map[int]float64)
+	newlines := 0
+	for len(pending) > 0 && reader.Next() {
+		event := reader.Event()
+		if event.Type != asciicast.EventTypeOutput {
+			continue
+		}
+		for len(pending) > 0 && newlines >= pending[0]-1 {
+			found[pending[0]] = event.Time
+			pending = pending[1:]
+		}
+		newlines += strings.Count(event.Data, "\n")
+		if !strings.HasSuffix(event.Data, "\n") {
+			for len(pending) > 0 && newlines >= pending[0]-1 {
+				found[pending[0]] = event.Time
+				pending = pending[1:]
+			}
+		}
+	}
+	return found, nil
+}
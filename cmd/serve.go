@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ober/goasciinema/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr string
+	serveDir  string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve recordings over HTTP",
+	Long: `Serve recordings from a directory over HTTP.
+
+Endpoints:
+  GET /stream/<file>?speed=N&from=T
+      Replay a recording with its original timing, as server-sent events
+      or over a WebSocket when the request asks to upgrade
+  GET /play/<file>#t=T
+      Minimal web player starting at offset T`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVarP(&serveAddr, "addr", "a", "localhost:8080", "Address to listen on")
+	serveCmd.Flags().StringVar(&serveDir, "dir", ".", "Directory containing recordings")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	srv := server.New(server.Options{Dir: serveDir})
+
+	fmt.Printf("Serving %s on http://%s\n", serveDir, serveAddr)
+	if err := srv.ListenAndServe(serveAddr); err != nil {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return nil
+}
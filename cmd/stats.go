@@ -2,36 +2,42 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
-	"github.com/ober/goasciinema/internal/database"
 	"github.com/spf13/cobra"
 )
 
-var statsDatabase string
+var statsFile string
 
 var statsCmd = &cobra.Command{
 	Use:   "stats",
 	Short: "Show database statistics",
-	Long:  `Display statistics about the processed asciinema recordings database.`,
-	RunE:  runStats,
+	Long: `Display statistics about the processed asciinema recordings database.
+
+With --file, recordings in the given directory (or a single file) are
+scanned directly, without a database.`,
+	RunE: runStats,
 }
 
 func init() {
 	rootCmd.AddCommand(statsCmd)
-	statsCmd.Flags().StringVarP(&statsDatabase, "database", "d", "", "SQLite database file (default: from ~/.goasciinema or ~/console-logs/asciinema_logs.db)")
+	statsCmd.Flags().StringVar(&statsFile, "file", "", "Report on recordings in a directory or file instead of the database")
+	addTimezoneFlags(statsCmd)
 }
 
 func runStats(cmd *cobra.Command, args []string) error {
-	// Use config default if no database specified
-	dbPath := statsDatabase
-	if dbPath == "" {
-		dbPath = GetDefaultDatabasePath()
+	if err := resolveDisplayZone(); err != nil {
+		return err
+	}
+
+	if statsFile != "" {
+		return runStatsFile(statsFile)
 	}
 
 	// Open database
-	db, err := database.Open(dbPath)
+	db, err := openDatabase()
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
 	defer db.Close()
 
@@ -40,10 +46,59 @@ func runStats(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get stats: %w", err)
 	}
 
-	fmt.Printf("Database: %s\n", dbPath)
+	fmt.Printf("Database: %s\n", GetDefaultDatabasePath())
 	fmt.Printf("Processed files: %d\n", stats.ProcessedFiles)
 	fmt.Printf("Sessions: %d\n", stats.Sessions)
 	fmt.Printf("Total characters: %s\n", formatNumber(stats.TotalChars))
+	printSessionRange(stats.FirstSession, stats.LastSession)
+
+	return nil
+}
+
+// printSessionRange prints when the first and last sessions were recorded
+func printSessionRange(first, last time.Time) {
+	if first.IsZero() {
+		return
+	}
+	fmt.Printf("First session: %s\n", formatSessionDate(first, ""))
+	fmt.Printf("Last session: %s\n", formatSessionDate(last, ""))
+}
+
+// runStatsFile reports statistics for recordings read directly from disk
+func runStatsFile(path string) error {
+	scanned, err := scanSessions(path)
+	if err != nil {
+		return err
+	}
+
+	var duration, idleDuration float64
+	var markers int
+	var first, last time.Time
+	for _, s := range scanned.Sessions {
+		duration += s.Duration
+		idleDuration += s.IdleDuration
+		markers += s.Markers
+		if s.Time.IsZero() {
+			continue
+		}
+		if first.IsZero() || s.Time.Before(first) {
+			first = s.Time
+		}
+		if s.Time.After(last) {
+			last = s.Time
+		}
+	}
+
+	fmt.Printf("Path: %s\n", path)
+	fmt.Printf("Recordings: %d\n", len(scanned.Sessions))
+	if scanned.Failed > 0 {
+		fmt.Printf("Unreadable: %d\n", scanned.Failed)
+	}
+	fmt.Printf("Total duration: %s (idle-capped %s)\n", formatOffset(duration), formatOffset(idleDuration))
+	fmt.Printf("Markers: %d\n", markers)
+	fmt.Printf("Total size: %s bytes\n", formatNumber(scanned.TotalBytes))
+	fmt.Printf("Total characters: %s\n", formatNumber(scanned.TotalChars))
+	printSessionRange(first, last)
 
 	return nil
 }
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/spf13/cobra"
+)
+
+// Session dates are shown in local time unless --utc or --tz is given.
+// --tz session shows each session in the zone it was recorded in.
+var (
+	displayUTC bool
+	displayTZ  string
+
+	displayLocation = time.Local // nil for each session's own zone
+)
+
+// tzSession selects each session's recorded zone for --tz
+const tzSession = "session"
+
+// addTimezoneFlags adds the date display flags to a command
+func addTimezoneFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&displayUTC, "utc", false, "Show session dates in UTC")
+	cmd.Flags().StringVar(&displayTZ, "tz", "", "Show session dates in this time zone, or 'session' for the zone each was recorded in")
+}
+
+// resolveDisplayZone validates the date display flags
+func resolveDisplayZone() error {
+	switch {
+	case displayUTC && displayTZ != "":
+		return fmt.Errorf("give either --utc or --tz, not both")
+	case displayUTC:
+		displayLocation = time.UTC
+	case displayTZ == tzSession:
+		displayLocation = nil
+	case displayTZ != "":
+		loc, err := asciicast.LoadTimezone(displayTZ)
+		if err != nil {
+			return fmt.Errorf("invalid --tz value: %w", err)
+		}
+		displayLocation = loc
+	default:
+		displayLocation = time.Local
+	}
+	return nil
+}
+
+// displayTime converts a session time for display. tz is the zone the
+// session was recorded in, used with --tz session; sessions without one
+// are shown in local time.
+func displayTime(t time.Time, tz string) time.Time {
+	if displayLocation != nil {
+		return t.In(displayLocation)
+	}
+	if tz != "" {
+		if loc, err := asciicast.LoadTimezone(tz); err == nil {
+			return t.In(loc)
+		}
+	}
+	return t.Local()
+}
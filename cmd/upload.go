@@ -2,8 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/ober/goasciinema/internal/api"
+	"github.com/ober/goasciinema/internal/asciicast"
 	"github.com/ober/goasciinema/internal/config"
 	"github.com/spf13/cobra"
 )
@@ -14,13 +18,38 @@ var uploadCmd = &cobra.Command{
 	Long: `Upload an asciicast recording to asciinema.org.
 
 The recording will be available at the returned URL.
-Use 'goasciinema auth' to link the recording to your account.`,
+Use 'goasciinema auth' to link the recording to your account.
+
+Large recordings (or any recording with --resumable) are sent in chunks
+when the server supports resumable uploads. An interrupted upload
+continues where it left off when the command is run again.
+
+Compressed recordings are decompressed to a temporary file, which is
+uploaded instead. An interrupted upload of a compressed recording starts
+over.
+
+The recording is validated before anything is sent, and problems are
+reported locally. Use --no-validate to upload anyway.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runUpload,
 }
 
+var (
+	uploadResumable  bool
+	uploadChunkSize  int64
+	uploadMaxSize    int64
+	uploadNoValidate bool
+)
+
+// resumableThreshold is the file size above which uploads are chunked
+const resumableThreshold = 64 * 1024 * 1024
+
 func init() {
 	rootCmd.AddCommand(uploadCmd)
+	uploadCmd.Flags().BoolVar(&uploadResumable, "resumable", false, "Use a chunked, resumable upload regardless of file size")
+	uploadCmd.Flags().Int64Var(&uploadChunkSize, "chunk-size", api.DefaultChunkSize, "Chunk size in bytes for resumable uploads")
+	uploadCmd.Flags().Int64Var(&uploadMaxSize, "max-size", 0, "Refuse files larger than this many bytes (0 for no limit)")
+	uploadCmd.Flags().BoolVar(&uploadNoValidate, "no-validate", false, "Skip local validation before uploading")
 }
 
 func runUpload(cmd *cobra.Command, args []string) error {
@@ -30,6 +59,40 @@ func runUpload(cmd *cobra.Command, args []string) error {
 	}
 
 	filename := args[0]
+	path := filename
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	// The server only takes plain asciicast, so send a decompressed copy
+	if info.Size() > 0 && asciicast.IsCompressed(filename) {
+		dir, err := os.MkdirTemp("", "goasciinema-upload-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+
+		plain, err := decompressedCopy(filename, dir)
+		if err != nil {
+			return err
+		}
+		if info, err = os.Stat(plain); err != nil {
+			return fmt.Errorf("failed to stat file: %w", err)
+		}
+		path = plain
+	}
+
+	if !uploadNoValidate {
+		problems := validateUpload(path, info.Size())
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", filename, p)
+		}
+		if len(asciicast.Errors(problems)) > 0 {
+			return fmt.Errorf("%s is not a valid recording, not uploading (use --no-validate to override)", filename)
+		}
+	}
 
 	installID, err := cfg.GetInstallID()
 	if err != nil {
@@ -40,7 +103,15 @@ func runUpload(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Uploading %s...\n", filename)
 
-	resp, err := client.Upload(filename)
+	var resp *api.UploadResponse
+	if (uploadResumable || info.Size() >= resumableThreshold) && client.SupportsResumable() {
+		resp, err = client.UploadResumable(path, cfg.UploadStateDir(), uploadChunkSize)
+	} else {
+		if uploadResumable {
+			fmt.Println("Server does not support resumable uploads, sending in one request.")
+		}
+		resp, err = client.Upload(path)
+	}
 	if err != nil {
 		return fmt.Errorf("upload failed: %w", err)
 	}
@@ -54,3 +125,51 @@ func runUpload(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// validateUpload checks a recording the way the server would, so problems
+// are reported before any data is sent
+func validateUpload(filename string, size int64) []asciicast.Problem {
+	var problems []asciicast.Problem
+	if size == 0 {
+		return append(problems, asciicast.Problem{Message: "file is empty"})
+	}
+	if uploadMaxSize > 0 && size > uploadMaxSize {
+		problems = append(problems, asciicast.Problem{
+			Message: fmt.Sprintf("file is %d bytes, larger than the %d byte limit", size, uploadMaxSize),
+		})
+	}
+
+	reader, err := asciicast.Open(filename)
+	if err != nil {
+		return append(problems, asciicast.Problem{Line: 1, Message: err.Error()})
+	}
+	legacy := reader.Header.Version == asciicast.VersionLegacy
+	reader.Close()
+	if legacy {
+		return append(problems, asciicast.Problem{Message: "legacy recordings must be converted to asciicast v2 before uploading"})
+	}
+
+	found, err := asciicast.Validate(filename)
+	if err != nil {
+		return append(problems, asciicast.Problem{Message: err.Error()})
+	}
+	return append(problems, found...)
+}
+
+// decompressedCopy decompresses a recording into dir, keeping its name
+// without the compression extension, and returns the copy's path
+func decompressedCopy(filename, dir string) (string, error) {
+	path := filepath.Join(dir, strings.TrimSuffix(filepath.Base(filename), asciicast.ZstdExt))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	if err := asciicast.Decompress(filename, file); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:     "validate <filename>...",
+	Aliases: []string{"lint"},
+	Short:   "Check recordings for problems",
+	Long: `Check asciicast recordings for problems that would confuse players,
+such as a missing or unsupported version, an invalid header, an empty
+recording, unparseable events, invalid UTF-8, and timestamps that go
+backwards. Each problem is reported with its line number. Unknown event
+types are reported as warnings and don't make a recording invalid.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	var invalid int
+	for _, filename := range args {
+		problems, err := asciicast.Validate(filename)
+		if err != nil {
+			fmt.Printf("%s: %v\n", filename, err)
+			invalid++
+			continue
+		}
+		if len(problems) == 0 {
+			fmt.Printf("%s: OK\n", filename)
+			continue
+		}
+		for _, p := range problems {
+			fmt.Printf("%s: %s\n", filename, p)
+		}
+		if len(asciicast.Errors(problems)) > 0 {
+			invalid++
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d file(s) have problems", invalid, len(args))
+	}
+	return nil
+}
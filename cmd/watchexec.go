@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ober/goasciinema/internal/config"
+	"github.com/ober/goasciinema/internal/recorder"
+	"github.com/ober/goasciinema/internal/tty"
+	"github.com/spf13/cobra"
+)
+
+var watchExecDir string
+
+var watchExecCmd = &cobra.Command{
+	Use:   "watch-exec <command> [args...]",
+	Short: "Run a command, recording it into a date-stamped cast",
+	Long: `Run a command in a recorded terminal and exit with its exit status.
+
+Meant to be used through an alias, so that only invocations of specific
+high-risk commands are recorded rather than entire shells:
+
+  alias terraform='goasciinema watch-exec terraform'
+
+Each invocation is saved as <command>-<date>-<time>-<pid>.cast in --dir
+(watch_dir in the [record] config section, default the database's
+directory) and indexed into the database right away.
+
+When stdin is not a terminal, or the session is already being recorded,
+the command is run directly without recording.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runWatchExec,
+}
+
+func init() {
+	rootCmd.AddCommand(watchExecCmd)
+	// Everything after the command name belongs to the wrapped command
+	watchExecCmd.Flags().SetInterspersed(false)
+	watchExecCmd.Flags().StringVar(&watchExecDir, "dir", "", "Directory for recordings (default: watch_dir from config, or the database's directory)")
+}
+
+func runWatchExec(cmd *cobra.Command, args []string) error {
+	if os.Getenv("GOASCIINEMA_REC") != "" || !tty.IsTerminal(tty.GetStdinFd()) {
+		os.Exit(execDirect(args))
+	}
+
+	// Don't leave an empty recording behind for a mistyped command
+	if _, err := exec.LookPath(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "goasciinema: %v\n", err)
+		os.Exit(127)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	dir := watchExecDir
+	if dir == "" {
+		dir = cfg.Record.WatchDir
+	}
+	if dir == "" {
+		dir = filepath.Dir(GetDefaultDatabasePath())
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s-%d.cast", filepath.Base(args[0]), time.Now().Format("20060102-150405"), os.Getpid())
+	filename := filepath.Join(dir, name)
+
+	rec := recorder.New(recorder.Options{
+		Args:          args,
+		Title:         strings.Join(args, " "),
+		IdleTimeLimit: cfg.Record.IdleTimeLimit,
+		SecretScan:    cfg.Record.SecretScan,
+		CaptureTheme:  cfg.Record.CaptureTheme,
+		Transports:    cfg.Record.Transports,
+		FlushInterval: cfg.Record.FlushInterval,
+		Fsync:         cfg.Record.Fsync,
+	})
+	if err := rec.Record(filename); err != nil {
+		return fmt.Errorf("recording failed: %w", err)
+	}
+
+	indexRecording(filename)
+	os.Exit(rec.ExitCode())
+	return nil
+}
+
+// execDirect runs a command attached to the current terminal and returns
+// its exit status
+func execDirect(args []string) int {
+	command := exec.Command(args[0], args[1:]...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "goasciinema: %v\n", err)
+		return 127
+	}
+	return 0
+}
+
+// indexRecording adds a new recording to the database, warning rather
+// than failing since the recording itself is already safe on disk
+func indexRecording(filename string) {
+	db, err := openDatabase()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	defer db.Close()
+
+	if _, err := processFile(db, filename); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to index %s: %v\n", filename, err)
+	}
+}
@@ -5,13 +5,14 @@ go 1.21
 require (
 	github.com/creack/pty v1.1.21
 	github.com/google/uuid v1.6.0
+	github.com/klauspost/compress v1.17.11
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/spf13/cobra v1.8.0
+	golang.org/x/sys v0.16.0
 	golang.org/x/term v0.16.0
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/mattn/go-sqlite3 v1.14.33 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sys v0.16.0 // indirect
 )
@@ -7,9 +7,11 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 const (
@@ -92,7 +94,7 @@ func (c *Client) Upload(filename string) (*UploadResponse, error) {
 	}
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, errorBody(body))
 	}
 
 	// Parse response
@@ -105,6 +107,115 @@ func (c *Client) Upload(filename string) (*UploadResponse, error) {
 	return &uploadResp, nil
 }
 
+// RemoteRecording describes a recording stored on the server
+type RemoteRecording struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	Title     string `json:"title"`
+	CreatedAt string `json:"created_at"`
+}
+
+// UnmarshalJSON accepts both numeric and string recording IDs
+func (r *RemoteRecording) UnmarshalJSON(data []byte) error {
+	type alias RemoteRecording
+	aux := struct {
+		ID json.RawMessage `json:"id"`
+		*alias
+	}{alias: (*alias)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.ID = strings.Trim(string(aux.ID), `"`)
+	return nil
+}
+
+// ListRecordings returns the recordings associated with this install ID
+func (c *Client) ListRecordings() ([]RemoteRecording, error) {
+	url := fmt.Sprintf("%s/api/asciicasts", c.baseURL)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", c.userAgentString())
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth("user", c.installID)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("listing failed with status %d: %s", resp.StatusCode, errorBody(body))
+	}
+
+	var recordings []RemoteRecording
+	if err := json.Unmarshal(body, &recordings); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return recordings, nil
+}
+
+// isServerURL reports whether u is on the configured server's host
+func (c *Client) isServerURL(u *url.URL) bool {
+	base, err := url.Parse(c.baseURL)
+	return err == nil && strings.EqualFold(base.Host, u.Host)
+}
+
+// Download fetches the cast file for a recording and writes it to dest
+func (c *Client) Download(rec RemoteRecording, dest string) error {
+	url := rec.URL
+	if url == "" {
+		url = fmt.Sprintf("%s/a/%s", c.baseURL, rec.ID)
+	}
+	if !strings.HasSuffix(url, ".cast") {
+		url += ".cast"
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", c.userAgentString())
+	// The URL comes from the server's listing; never send the install ID
+	// to another host
+	if c.isServerURL(req.URL) {
+		req.SetBasicAuth("user", c.installID)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(dest)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return file.Close()
+}
+
 // AuthURL returns the URL for authentication
 func (c *Client) AuthURL() string {
 	return fmt.Sprintf("%s/connect/%s", c.baseURL, c.installID)
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"regexp"
+	"strings"
+)
+
+var htmlTitlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// maxErrorBody is the longest response body included verbatim in an error
+const maxErrorBody = 200
+
+// errorBody summarizes a server response body for an error message. HTML
+// error pages are reduced to their title instead of being dumped raw.
+func errorBody(body []byte) string {
+	text := strings.TrimSpace(string(body))
+	lower := strings.ToLower(text)
+	if strings.HasPrefix(lower, "<!doctype html") || strings.HasPrefix(lower, "<html") {
+		if m := htmlTitlePattern.FindStringSubmatch(text); m != nil {
+			return strings.TrimSpace(m[1])
+		}
+		return "server returned an HTML error page"
+	}
+	if len(text) > maxErrorBody {
+		text = text[:maxErrorBody] + "..."
+	}
+	return text
+}
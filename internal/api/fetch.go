@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// castPagePattern matches the path of a recording page on asciinema-server,
+// whose cast file is the same path with .cast appended
+var castPagePattern = regexp.MustCompile(`^/a/[A-Za-z0-9_-]+/?$`)
+
+// castLinkPattern finds the link to the cast file in the head of a
+// recording page
+var castLinkPattern = regexp.MustCompile(`(?is)<link[^>]+type=["']application/x-asciicast["'][^>]*>`)
+
+var hrefPattern = regexp.MustCompile(`(?i)href=["']([^"']+)["']`)
+
+// maxFetchSize bounds a downloaded recording
+const maxFetchSize = 512 << 20
+
+// IsURL reports whether name is an HTTP(S) URL rather than a file path
+func IsURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// FetchCast downloads the recording at rawURL into a cache file in dir
+// and returns its path. Recording page URLs like
+// https://asciinema.org/a/123 are resolved to their cast file, either by
+// path or through the page's application/x-asciicast link. Redirects are
+// followed, and a URL already in the cache is not downloaded again.
+func (c *Client) FetchCast(rawURL, dir string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	sum := sha256.Sum256([]byte(u.String()))
+	dest := filepath.Join(dir, hex.EncodeToString(sum[:8])+".cast")
+	if info, err := os.Stat(dest); err == nil && info.Size() > 0 {
+		return dest, nil
+	}
+
+	if castPagePattern.MatchString(u.Path) {
+		u.Path = strings.TrimSuffix(u.Path, "/") + ".cast"
+	}
+	body, final, err := c.get(u.String())
+	if err != nil {
+		return "", err
+	}
+	if isHTML(body) {
+		link, ok := castLink(body, final)
+		if !ok {
+			return "", fmt.Errorf("no recording found at %s", rawURL)
+		}
+		if body, _, err = c.get(link); err != nil {
+			return "", err
+		}
+		if isHTML(body) {
+			return "", fmt.Errorf("no recording found at %s", link)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp := dest + ".tmp"
+	if err := os.WriteFile(tmp, body, 0600); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	return dest, nil
+}
+
+// get downloads a URL, returning the body and the URL it was finally
+// served from after redirects
+func (c *Client) get(rawURL string) ([]byte, *url.URL, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", c.userAgentString())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, nil, fmt.Errorf("download failed with status %d: %s", resp.StatusCode, errorBody(body))
+	}
+	if len(body) > maxFetchSize {
+		return nil, nil, fmt.Errorf("recording is larger than %d MB", maxFetchSize>>20)
+	}
+	return body, resp.Request.URL, nil
+}
+
+// isHTML reports whether a response body is an HTML page
+func isHTML(body []byte) bool {
+	start := bytes.ToLower(bytes.TrimSpace(body[:min(len(body), 512)]))
+	return bytes.HasPrefix(start, []byte("<!doctype html")) || bytes.HasPrefix(start, []byte("<html"))
+}
+
+// castLink returns the cast file linked from a recording page, resolved
+// against the page's URL
+func castLink(page []byte, base *url.URL) (string, bool) {
+	link := castLinkPattern.Find(page)
+	if link == nil {
+		return "", false
+	}
+	m := hrefPattern.FindSubmatch(link)
+	if m == nil {
+		return "", false
+	}
+	ref, err := url.Parse(html.UnescapeString(string(m[1])))
+	if err != nil {
+		return "", false
+	}
+	return base.ResolveReference(ref).String(), true
+}
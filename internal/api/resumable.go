@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Resumable uploads use the tus protocol (https://tus.io) against
+// <baseURL>/api/uploads. Progress is remembered in a small state file so an
+// interrupted upload continues from the last acknowledged offset.
+
+const tusVersion = "1.0.0"
+
+// DefaultChunkSize is the number of bytes sent per PATCH request
+const DefaultChunkSize = 8 * 1024 * 1024
+
+// uploadState is persisted between runs of an interrupted upload
+type uploadState struct {
+	Location string `json:"location"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mod_time"`
+}
+
+// SupportsResumable reports whether the server accepts tus uploads
+func (c *Client) SupportsResumable() bool {
+	req, err := http.NewRequest("OPTIONS", c.baseURL+"/api/uploads", nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("User-Agent", c.userAgentString())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode < 400 && resp.Header.Get("Tus-Version") != ""
+}
+
+// UploadResumable uploads a file in chunks, resuming a previous attempt
+// recorded in stateDir when the file is unchanged
+func (c *Client) UploadResumable(filename, stateDir string, chunkSize int64) (*UploadResponse, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	statePath, err := uploadStatePath(stateDir, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resume if we have state for this exact file
+	var offset int64
+	state := loadUploadState(statePath)
+	if state != nil && state.Size == info.Size() && state.ModTime == info.ModTime().Unix() {
+		offset, err = c.uploadOffset(state.Location)
+		if err != nil {
+			state = nil
+		}
+	} else {
+		state = nil
+	}
+
+	if state == nil {
+		location, err := c.createUpload(filename, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		state = &uploadState{Location: location, Size: info.Size(), ModTime: info.ModTime().Unix()}
+		if err := saveUploadState(statePath, state); err != nil {
+			return nil, err
+		}
+		offset = 0
+	}
+
+	var body []byte
+	buf := make([]byte, chunkSize)
+	for offset < info.Size() {
+		n, err := file.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+
+		offset, body, err = c.patchChunk(state.Location, offset, buf[:n])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	os.Remove(statePath)
+
+	var uploadResp UploadResponse
+	if err := json.Unmarshal(body, &uploadResp); err != nil || uploadResp.URL == "" {
+		uploadResp.URL = state.Location
+	}
+	return &uploadResp, nil
+}
+
+// createUpload starts a new tus upload and returns its location
+func (c *Client) createUpload(filename string, size int64) (string, error) {
+	req, err := http.NewRequest("POST", c.baseURL+"/api/uploads", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setTusHeaders(req)
+	req.Header.Set("Upload-Length", strconv.FormatInt(size, 10))
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte(filepath.Base(filename))))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("upload creation failed with status %d: %s", resp.StatusCode, errorBody(body))
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("server did not return an upload location")
+	}
+	return c.resolveURL(location)
+}
+
+// uploadOffset asks the server how many bytes of an upload it has received
+func (c *Client) uploadOffset(location string) (int64, error) {
+	req, err := http.NewRequest("HEAD", location, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setTusHeaders(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return 0, fmt.Errorf("upload lookup failed with status %d", resp.StatusCode)
+	}
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
+
+// patchChunk sends one chunk and returns the new offset and response body
+func (c *Client) patchChunk(location string, offset int64, chunk []byte) (int64, []byte, error) {
+	req, err := http.NewRequest("PATCH", location, bytes.NewReader(chunk))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setTusHeaders(req)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to send chunk: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return 0, nil, fmt.Errorf("chunk upload failed with status %d: %s", resp.StatusCode, errorBody(body))
+	}
+
+	newOffset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || newOffset <= offset {
+		return 0, nil, fmt.Errorf("server did not acknowledge chunk at offset %d", offset)
+	}
+	return newOffset, body, nil
+}
+
+func (c *Client) setTusHeaders(req *http.Request) {
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req.Header.Set("User-Agent", c.userAgentString())
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth("user", c.installID)
+}
+
+// resolveURL resolves a possibly relative Location header against the base URL
+func (c *Client) resolveURL(location string) (string, error) {
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid upload location: %w", err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+func uploadStatePath(stateDir, filename string) (string, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create state directory: %w", err)
+	}
+	sum := sha1.Sum([]byte(abs))
+	return filepath.Join(stateDir, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+func loadUploadState(path string) *uploadState {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var state uploadState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil
+	}
+	return &state
+}
+
+func saveUploadState(path string, state *uploadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload state: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save upload state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package asciicast
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression formats for recordings
+const (
+	CompressNone = ""
+	CompressZstd = "zstd"
+)
+
+// ZstdExt is appended to the names of zstd-compressed recordings
+const ZstdExt = ".zst"
+
+// zstdMagic starts every zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// IsCompressedName reports whether a recording's file name marks it as
+// compressed
+func IsCompressedName(filename string) bool {
+	return strings.HasSuffix(filename, ZstdExt)
+}
+
+// IsCompressed reports whether a recording's data is compressed, going by
+// its first bytes rather than its name
+func IsCompressed(filename string) bool {
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	magic := make([]byte, len(zstdMagic))
+	_, err = io.ReadFull(file, magic)
+	return err == nil && bytes.Equal(magic, zstdMagic)
+}
+
+// decompressReader returns a reader of r's content, decompressed if it is
+// zstd data, and a function that releases the decoder
+func decompressReader(r *bufio.Reader) (*bufio.Reader, func(), error) {
+	magic, err := r.Peek(len(zstdMagic))
+	if err != nil || !bytes.Equal(magic, zstdMagic) {
+		return r, func() {}, nil
+	}
+	dec, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start decompression: %w", err)
+	}
+	return bufio.NewReader(dec), dec.Close, nil
+}
+
+// Decompress writes the content of a recording file to w, decompressed if
+// it is zstd data
+func Decompress(filename string, w io.Writer) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	reader, release, err := decompressReader(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	defer release()
+	if _, err := io.Copy(w, reader); err != nil {
+		return fmt.Errorf("failed to decompress recording: %w", err)
+	}
+	return nil
+}
+
+// NewCompressor returns a writer that zstd-compresses what is written to
+// it into w. Closing it completes the compressed data but does not close
+// w.
+func NewCompressor(w io.Writer) (io.WriteCloser, error) {
+	enc, err := zstd.NewWriter(w)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start compression: %w", err)
+	}
+	return enc, nil
+}
+
+// zstdFileWriter streams zstd-compressed data into a file. Appending adds
+// a new frame, which decoders read as a continuation of the previous ones.
+type zstdFileWriter struct {
+	enc  *zstd.Encoder
+	file *os.File
+}
+
+// Create creates a private recording file for writing, compressed when
+// the file name ends in ZstdExt
+func Create(filename string) (io.WriteCloser, error) {
+	// Recordings contain whatever was on screen, so keep them private
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	out, err := outputFor(file, filename)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return out, nil
+}
+
+// outputFor wraps a recording file for writing, compressing it when the
+// file name ends in ZstdExt
+func outputFor(file *os.File, filename string) (io.WriteCloser, error) {
+	if !IsCompressedName(filename) {
+		return file, nil
+	}
+	return newZstdFileWriter(file)
+}
+
+func newZstdFileWriter(file *os.File) (*zstdFileWriter, error) {
+	enc, err := zstd.NewWriter(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start compression: %w", err)
+	}
+	return &zstdFileWriter{enc: enc, file: file}, nil
+}
+
+func (w *zstdFileWriter) Write(p []byte) (int, error) {
+	return w.enc.Write(p)
+}
+
+// Flush completes the current block, so everything written so far can be
+// decompressed from the file
+func (w *zstdFileWriter) Flush() error {
+	return w.enc.Flush()
+}
+
+// Sync commits the file to stable storage
+func (w *zstdFileWriter) Sync() error {
+	return w.file.Sync()
+}
+
+func (w *zstdFileWriter) Close() error {
+	if err := w.enc.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
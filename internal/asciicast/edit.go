@@ -0,0 +1,200 @@
+package asciicast
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// TimeRange is a span of a recording, in seconds
+type TimeRange struct {
+	From, To float64
+}
+
+// Editing operations rewrite a recording's event times in place. Each
+// leaves the events in time order and updates Header.Duration.
+
+// Trim keeps only the events between from and to seconds (to <= 0 means
+// the end of the recording) and moves them to start at zero. A resize
+// before the range becomes the recording's initial size.
+func (r *Recording) Trim(from, to float64) {
+	end := r.end()
+	var kept []Event
+	for _, event := range r.Events {
+		if event.Time < from {
+			if event.Type == EventTypeResize {
+				if cols, rows, ok := ParseResize(event.Data); ok {
+					r.Header.Width, r.Header.Height = cols, rows
+				}
+			}
+			continue
+		}
+		if to > 0 && event.Time > to {
+			break
+		}
+		event.Time = editTime(event.Time - from)
+		kept = append(kept, event)
+	}
+	r.Events = kept
+
+	// Idle time up to --to is part of the trimmed recording
+	if to > 0 && to < end {
+		end = to
+	}
+	r.Header.Duration = editTime(math.Max(end-from, 0))
+}
+
+// Cut removes the given time ranges and moves later events back to close
+// the gaps, so playback continues seamlessly. Resize events inside a cut
+// are kept, at the point of the cut, so the terminal size stays right.
+func (r *Recording) Cut(ranges []TimeRange) {
+	ranges = mergeRanges(ranges)
+	end := r.end()
+
+	var kept []Event
+	for _, event := range r.Events {
+		shift, inside := cutShift(ranges, event.Time)
+		if inside && event.Type != EventTypeResize {
+			continue
+		}
+		event.Time = editTime(event.Time - shift)
+		kept = append(kept, event)
+	}
+	r.Events = kept
+
+	shift, _ := cutShift(ranges, end)
+	r.Header.Duration = editTime(end - shift)
+}
+
+// cutShift returns how far an event at t moves back once ranges are
+// removed, and whether t falls inside one of them
+func cutShift(ranges []TimeRange, t float64) (float64, bool) {
+	var shift float64
+	for _, cut := range ranges {
+		if t >= cut.To {
+			shift += cut.To - cut.From
+			continue
+		}
+		if t > cut.From {
+			return shift + t - cut.From, true
+		}
+		break
+	}
+	return shift, false
+}
+
+// mergeRanges sorts ranges and joins overlapping ones
+func mergeRanges(ranges []TimeRange) []TimeRange {
+	sorted := append([]TimeRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].From < sorted[j].From })
+
+	var merged []TimeRange
+	for _, rng := range sorted {
+		if n := len(merged); n > 0 && rng.From <= merged[n-1].To {
+			merged[n-1].To = math.Max(merged[n-1].To, rng.To)
+			continue
+		}
+		merged = append(merged, rng)
+	}
+	return merged
+}
+
+// Scale multiplies event times by factor, so a factor of 0.5 plays twice
+// as fast. With a non-nil span only the events inside it are rescaled,
+// and later events move by however much the span grew or shrank.
+func (r *Recording) Scale(factor float64, span *TimeRange) {
+	end := r.end()
+	rng := TimeRange{From: 0, To: end}
+	if span != nil {
+		rng = *span
+	}
+	scale := func(t float64) float64 {
+		switch {
+		case t <= rng.From:
+			return t
+		case t <= rng.To:
+			return rng.From + (t-rng.From)*factor
+		default:
+			return t + (rng.To-rng.From)*(factor-1)
+		}
+	}
+
+	for i := range r.Events {
+		r.Events[i].Time = editTime(scale(r.Events[i].Time))
+	}
+	r.Header.Duration = editTime(scale(end))
+	if r.Header.IdleTimeLimit > 0 && span == nil {
+		r.Header.IdleTimeLimit = editTime(r.Header.IdleTimeLimit * factor)
+	}
+}
+
+// CompressIdle shortens every gap between events longer than limit to
+// limit, as the player's idle time limit does, and returns the total time
+// removed
+func (r *Recording) CompressIdle(limit float64) float64 {
+	end := r.end()
+	var prev, removed float64
+	for i := range r.Events {
+		t := r.Events[i].Time
+		if gap := t - prev; gap > limit {
+			removed += gap - limit
+		}
+		prev = t
+		r.Events[i].Time = editTime(t - removed)
+	}
+	if gap := end - prev; gap > limit {
+		removed += gap - limit
+	}
+	r.Header.Duration = editTime(end - removed)
+	return removed
+}
+
+// Append adds the events of next after the end of the recording. A
+// non-empty marker is recorded at the join, and if next starts at a
+// different size than the recording ends at, a resize event switches to it.
+func (r *Recording) Append(next *Recording, marker string) {
+	offset := r.end()
+	if marker != "" {
+		r.Events = append(r.Events, Event{Time: offset, Type: EventTypeMarker, Data: marker})
+	}
+	if cols, rows := r.FinalSize(); next.Header.Width != cols || next.Header.Height != rows {
+		r.Events = append(r.Events, Event{Time: offset, Type: EventTypeResize,
+			Data: fmt.Sprintf("%dx%d", next.Header.Width, next.Header.Height)})
+	}
+	for _, event := range next.Events {
+		event.Time = editTime(event.Time + offset)
+		r.Events = append(r.Events, event)
+	}
+	r.Header.Duration = editTime(offset + next.end())
+}
+
+// FinalSize returns the terminal size at the end of the recording: the
+// last resize, or the header size if there was none
+func (r *Recording) FinalSize() (cols, rows int) {
+	cols, rows = r.Header.Width, r.Header.Height
+	for _, event := range r.Events {
+		if event.Type != EventTypeResize {
+			continue
+		}
+		if c, r, ok := ParseResize(event.Data); ok {
+			cols, rows = c, r
+		}
+	}
+	return cols, rows
+}
+
+// end returns the length of the recording: its header duration, or the
+// time of the last event if that is later
+func (r *Recording) end() float64 {
+	end := r.Header.Duration
+	if n := len(r.Events); n > 0 && r.Events[n-1].Time > end {
+		end = r.Events[n-1].Time
+	}
+	return end
+}
+
+// editTime rounds an edited time to the microsecond precision of the
+// format, removing floating-point noise from the arithmetic
+func editTime(t float64) float64 {
+	return math.Round(t*1e6) / 1e6
+}
@@ -0,0 +1,30 @@
+package asciicast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// jsonDocument is a whole recording as one JSON object, for tools that
+// can't read newline-delimited JSON
+type jsonDocument struct {
+	Header Header          `json:"header"`
+	Events [][]interface{} `json:"events"`
+}
+
+// EncodeJSON writes a recording as a single JSON document holding the v2
+// header and an array of [time, type, data] events
+func EncodeJSON(w io.Writer, header Header, events []Event) error {
+	header.Version = Version2
+	doc := jsonDocument{Header: header, Events: make([][]interface{}, 0, len(events))}
+	for _, event := range events {
+		doc.Events = append(doc.Events, []interface{}{roundTimestamp(event.Time), event.Type, event.Value()})
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
+}
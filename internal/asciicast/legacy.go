@@ -0,0 +1,188 @@
+package asciicast
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Legacy (pre-v1) recordings were produced by the original asciinema
+// client as a raw "stdout" dump, an optional "stdout.time" timing file
+// in scriptreplay layout ("<delay> <bytes>" per line), and an optional
+// "meta.json". Both data files may be bzip2-compressed.
+
+// isLegacy reports whether a file without a JSON header looks like a
+// legacy recording: an .asc file, a dump named stdout, or a file with a
+// timing or meta file alongside
+func isLegacy(filename string) bool {
+	switch base := filepath.Base(filename); {
+	case strings.EqualFold(filepath.Ext(base), ".asc"), base == "stdout", base == "stdout.bz2":
+		return true
+	}
+	return legacySibling(filename, "time", "stdout.time") != "" ||
+		legacySibling(filename, "json", "meta.json") != ""
+}
+
+// legacyMeta is the subset of the legacy meta.json we understand
+type legacyMeta struct {
+	Duration float64 `json:"duration"`
+	Title    string  `json:"title"`
+	Command  string  `json:"command"`
+	Shell    string  `json:"shell"`
+	Term     struct {
+		Type    string `json:"type"`
+		Lines   int    `json:"lines"`
+		Columns int    `json:"columns"`
+	} `json:"term"`
+}
+
+// openLegacy parses a legacy recording into a header and its events
+func openLegacy(filename string) (Header, []Event, error) {
+	header := Header{
+		Version: VersionLegacy,
+		Width:   80,
+		Height:  24,
+		Env:     make(map[string]string),
+	}
+
+	data, err := readMaybeBzip2(filename)
+	if err != nil {
+		return header, nil, err
+	}
+
+	if meta, err := loadLegacyMeta(filename); err == nil {
+		if meta.Term.Columns > 0 && meta.Term.Lines > 0 {
+			header.Width = meta.Term.Columns
+			header.Height = meta.Term.Lines
+		}
+		header.Duration = meta.Duration
+		header.Title = meta.Title
+		header.Command = meta.Command
+		if meta.Shell != "" {
+			header.Env["SHELL"] = meta.Shell
+		}
+		if meta.Term.Type != "" {
+			header.Env["TERM"] = meta.Term.Type
+		}
+	}
+
+	timing, err := loadLegacyTiming(filename)
+	if err != nil {
+		// Without timing the whole output is a single event
+		if len(data) == 0 {
+			return header, nil, nil
+		}
+		return header, []Event{{Time: 0, Type: EventTypeOutput, Data: string(data)}}, nil
+	}
+
+	var events []Event
+	var elapsed float64
+	pos := 0
+	for _, t := range timing {
+		elapsed += t.delay
+		end := pos + t.size
+		if end > len(data) {
+			end = len(data)
+		}
+		if end > pos {
+			events = append(events, Event{Time: roundTimestamp(elapsed), Type: EventTypeOutput, Data: string(data[pos:end])})
+		}
+		pos = end
+	}
+	if pos < len(data) {
+		events = append(events, Event{Time: roundTimestamp(elapsed), Type: EventTypeOutput, Data: string(data[pos:])})
+	}
+
+	return header, events, nil
+}
+
+type legacyTiming struct {
+	delay float64
+	size  int
+}
+
+// legacySibling returns the path of a companion file for a legacy
+// recording: "<file>.<suffix>" or, for a file named "stdout", the
+// conventional name in the same directory.
+func legacySibling(filename, suffix, conventional string) string {
+	candidate := filename + "." + suffix
+	if _, err := os.Stat(candidate); err == nil {
+		return candidate
+	}
+	candidate = filepath.Join(filepath.Dir(filename), conventional)
+	if _, err := os.Stat(candidate); err == nil && candidate != filename {
+		return candidate
+	}
+	return ""
+}
+
+func loadLegacyTiming(filename string) ([]legacyTiming, error) {
+	path := legacySibling(filename, "time", "stdout.time")
+	if path == "" {
+		return nil, fmt.Errorf("no timing file")
+	}
+
+	data, err := readMaybeBzip2(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var timing []legacyTiming
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		delay, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timing delay: %w", err)
+		}
+		size, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid timing size: %w", err)
+		}
+		timing = append(timing, legacyTiming{delay: delay, size: size})
+	}
+	return timing, scanner.Err()
+}
+
+func loadLegacyMeta(filename string) (*legacyMeta, error) {
+	path := legacySibling(filename, "json", "meta.json")
+	if path == "" {
+		return nil, fmt.Errorf("no meta file")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta legacyMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse meta: %w", err)
+	}
+	return &meta, nil
+}
+
+// readMaybeBzip2 reads a file, transparently decompressing bzip2 data
+func readMaybeBzip2(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if bytes.HasPrefix(data, []byte("BZh")) {
+		decompressed, err := io.ReadAll(bzip2.NewReader(bytes.NewReader(data)))
+		if err == nil {
+			return decompressed, nil
+		}
+	}
+	return data, nil
+}
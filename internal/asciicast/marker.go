@@ -0,0 +1,51 @@
+package asciicast
+
+import "sort"
+
+// Marker is a labelled point in a recording, such as a chapter start
+type Marker struct {
+	Index int // position among the recording's markers, from 0
+	Time  float64
+	Label string
+}
+
+// Markers returns the recording's markers in time order
+func (r *Recording) Markers() []Marker {
+	var markers []Marker
+	for _, event := range r.Events {
+		if event.Type == EventTypeMarker {
+			markers = append(markers, Marker{Index: len(markers), Time: event.Time, Label: event.Data})
+		}
+	}
+	return markers
+}
+
+// AddMarker inserts a marker at t seconds, after any events already at
+// that time. The duration grows if t is past the end.
+func (r *Recording) AddMarker(t float64, label string) {
+	t = editTime(t)
+	i := sort.Search(len(r.Events), func(i int) bool { return r.Events[i].Time > t })
+	r.Events = append(r.Events, Event{})
+	copy(r.Events[i+1:], r.Events[i:])
+	r.Events[i] = Event{Time: t, Type: EventTypeMarker, Data: label}
+	if r.Header.Duration > 0 && t > r.Header.Duration {
+		r.Header.Duration = t
+	}
+}
+
+// RemoveMarker removes the marker with the given index, as returned by
+// Markers, and reports whether it existed
+func (r *Recording) RemoveMarker(index int) (Marker, bool) {
+	n := 0
+	for i, event := range r.Events {
+		if event.Type != EventTypeMarker {
+			continue
+		}
+		if n == index {
+			r.Events = append(r.Events[:i], r.Events[i+1:]...)
+			return Marker{Index: index, Time: event.Time, Label: event.Data}, true
+		}
+		n++
+	}
+	return Marker{}, false
+}
@@ -0,0 +1,97 @@
+package asciicast
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Load reads a whole recording, in any format Open reads, into memory
+func Load(filename string) (*Recording, error) {
+	reader, err := Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	rec := &Recording{}
+	for reader.Next() {
+		rec.Events = append(rec.Events, reader.Event())
+	}
+	if err := reader.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event: %w", err)
+	}
+	rec.Header = reader.Header
+	return rec, nil
+}
+
+// Save writes the recording to filename in asciicast v2 format,
+// compressed if the name ends in ZstdExt. It is written to a temporary
+// file that then replaces filename, so an existing recording is never left
+// half-written.
+func (r *Recording) Save(filename string) error {
+	header := r.Header
+	header.Version = Version2
+
+	// The temporary name keeps the extension, which selects compression
+	tmpName := filepath.Join(filepath.Dir(filename), ".tmp-"+filepath.Base(filename))
+	writer, err := NewWriter(tmpName, header, false)
+	if err != nil {
+		return err
+	}
+	for _, event := range r.Events {
+		if err := writer.WriteEvent(event); err != nil {
+			writer.Close()
+			os.Remove(tmpName)
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to replace %s: %w", filename, err)
+	}
+	return nil
+}
+
+// AppendEvent adds an event at the end of the recording. An event earlier
+// than the last one is moved up to its time, keeping the events in order.
+func (r *Recording) AppendEvent(event Event) {
+	if n := len(r.Events); n > 0 && event.Time < r.Events[n-1].Time {
+		event.Time = r.Events[n-1].Time
+	}
+	r.Events = append(r.Events, event)
+	if event.Time > r.Header.Duration {
+		r.Header.Duration = event.Time
+	}
+}
+
+// ShiftTime moves every event by offset seconds, later if it is positive
+// and earlier if negative; times that would go below zero become zero
+func (r *Recording) ShiftTime(offset float64) {
+	for i := range r.Events {
+		r.Events[i].Time = editTime(math.Max(r.Events[i].Time+offset, 0))
+	}
+	if r.Header.Duration > 0 {
+		r.Header.Duration = editTime(math.Max(r.Header.Duration+offset, 0))
+	}
+}
+
+// Slice returns a copy of the part of the recording between from and to
+// seconds (to <= 0 means the end), starting at zero, as Trim would leave
+// it. The recording itself is unchanged.
+func (r *Recording) Slice(from, to float64) *Recording {
+	slice := &Recording{Header: r.Header, Events: append([]Event(nil), r.Events...)}
+	if r.Header.Env != nil {
+		slice.Header.Env = make(map[string]string, len(r.Header.Env))
+		for key, value := range r.Header.Env {
+			slice.Header.Env[key] = value
+		}
+	}
+	slice.Trim(from, to)
+	return slice
+}
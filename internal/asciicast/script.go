@@ -0,0 +1,234 @@
+package asciicast
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// util-linux script writes the session to a typescript file, optionally
+// framed by "Script started on ..." and "Script done on ..." lines, and with
+// --timing a log of when each chunk was written. The classic log has
+// "<delay> <bytes>" lines; the advanced log (-m advanced) prefixes each
+// with O (output), I (input), S (signal) or H (header information).
+
+// scriptStartPrefix begins the line script writes before the session
+const scriptStartPrefix = "Script started on "
+
+// scriptAttr matches a KEY="value" attribute of the start line
+var scriptAttr = regexp.MustCompile(`([A-Z_]+)="([^"]*)"`)
+
+// scriptTimeLayouts are the date formats of script's start line, newest
+// first
+var scriptTimeLayouts = []string{
+	"2006-01-02 15:04:05-07:00",
+	"2006-01-02 15:04:05Z07:00",
+	"Mon 02 Jan 2006 03:04:05 PM MST",
+	"Mon Jan _2 15:04:05 2006",
+}
+
+// ReadScript parses a typescript and the timing log recorded with it.
+// Input is included when the log interleaves it into the same typescript
+// (script -B); a separate input log is not read.
+func ReadScript(typescript, timing io.Reader) (Header, []Event, error) {
+	header := Header{Version: Version2, Width: 80, Height: 24, Env: make(map[string]string)}
+
+	data, err := io.ReadAll(typescript)
+	if err != nil {
+		return header, nil, fmt.Errorf("failed to read typescript: %w", err)
+	}
+	if bytes.HasPrefix(data, []byte(scriptStartPrefix)) {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		parseScriptStart(string(line), &header)
+	}
+
+	var events []Event
+	var elapsed float64
+	var logs struct{ input, output string }
+	pos := 0
+	pending := map[string][]byte{} // incomplete UTF-8 carried over, per event type
+	emit := func(eventType string, size int) {
+		end := min(pos+size, len(data))
+		var text string
+		text, pending[eventType] = SplitUTF8(append(pending[eventType], data[pos:end]...))
+		pos = end
+		if text != "" {
+			events = append(events, Event{Time: roundTimestamp(elapsed), Type: eventType, Data: text})
+		}
+	}
+
+	scanner := bufio.NewScanner(timing)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		kind := "O"
+		if _, err := strconv.ParseFloat(fields[0], 64); err != nil {
+			kind, fields = fields[0], fields[1:]
+		}
+		if len(fields) < 2 {
+			return header, nil, fmt.Errorf("timing line %d: invalid entry", n)
+		}
+		delay, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return header, nil, fmt.Errorf("timing line %d: invalid delay: %w", n, err)
+		}
+		elapsed += delay
+
+		switch kind {
+		case "O", "I":
+			size, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return header, nil, fmt.Errorf("timing line %d: invalid size: %w", n, err)
+			}
+			if kind == "I" {
+				// Input is only in this typescript if both went to one file
+				if logs.input == "" || logs.input != logs.output {
+					continue
+				}
+				emit(EventTypeInput, size)
+				continue
+			}
+			emit(EventTypeOutput, size)
+		case "S":
+			if fields[1] == "SIGWINCH" {
+				if cols, rows, ok := parseScriptSize(fields[2:]); ok {
+					events = append(events, Event{Time: roundTimestamp(elapsed), Type: EventTypeResize, Data: fmt.Sprintf("%dx%d", cols, rows)})
+				}
+			}
+		case "H":
+			value := strings.Join(fields[2:], " ")
+			switch fields[1] {
+			case "INPUT_LOG":
+				logs.input = value
+			case "OUTPUT_LOG":
+				logs.output = value
+			default:
+				applyScriptAttr(fields[1], value, &header)
+			}
+		default:
+			return header, nil, fmt.Errorf("timing line %d: unknown entry type %q", n, kind)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return header, nil, fmt.Errorf("failed to read timing log: %w", err)
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if rest := pending[events[i].Type]; len(rest) > 0 {
+			events[i].Data += string(rest)
+			pending[events[i].Type] = nil
+		}
+	}
+
+	return header, events, nil
+}
+
+// parseScriptStart reads the time and attributes of script's start line
+func parseScriptStart(line string, header *Header) {
+	rest := strings.TrimPrefix(line, scriptStartPrefix)
+	date := rest
+	if i := strings.Index(rest, " ["); i >= 0 {
+		date = rest[:i]
+	}
+	for _, layout := range scriptTimeLayouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(date)); err == nil {
+			header.Timestamp = t.Unix()
+			break
+		}
+	}
+	for _, m := range scriptAttr.FindAllStringSubmatch(rest, -1) {
+		applyScriptAttr(m[1], m[2], header)
+	}
+}
+
+// applyScriptAttr applies a session attribute from the start line or an
+// advanced timing log header entry
+func applyScriptAttr(key, value string, header *Header) {
+	switch key {
+	case "COLUMNS":
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			header.Width = n
+		}
+	case "LINES":
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			header.Height = n
+		}
+	case "TERM", "SHELL":
+		header.Env[key] = value
+	case "COMMAND":
+		header.Command = value
+	case "START_TIME":
+		parseScriptStart(scriptStartPrefix+value, header)
+	}
+}
+
+// parseScriptSize reads the ROWS=n COLS=n fields of a SIGWINCH entry
+func parseScriptSize(fields []string) (cols, rows int, ok bool) {
+	for _, field := range fields {
+		key, value, _ := strings.Cut(field, "=")
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "ROWS":
+			rows = n
+		case "COLS":
+			cols = n
+		}
+	}
+	return cols, rows, cols > 0 && rows > 0
+}
+
+// EncodeScript writes a recording as a util-linux typescript and classic
+// timing log, as replayed by scriptreplay. Only output is included.
+func EncodeScript(typescript, timing io.Writer, header Header, events []Event) error {
+	start := time.Now()
+	if header.Timestamp > 0 {
+		start = time.Unix(header.Timestamp, 0)
+	}
+	var attrs []string
+	if header.Command != "" {
+		attrs = append(attrs, fmt.Sprintf("COMMAND=\"%s\"", header.Command))
+	}
+	if term := header.Env["TERM"]; term != "" {
+		attrs = append(attrs, fmt.Sprintf("TERM=\"%s\"", term))
+	}
+	attrs = append(attrs, fmt.Sprintf("COLUMNS=\"%d\"", header.Width), fmt.Sprintf("LINES=\"%d\"", header.Height))
+
+	ts := bufio.NewWriter(typescript)
+	tm := bufio.NewWriter(timing)
+	fmt.Fprintf(ts, "%s%s [%s]\n", scriptStartPrefix, start.Format(scriptTimeLayouts[0]), strings.Join(attrs, " "))
+
+	var prev, end float64
+	for _, event := range events {
+		end = max(end, event.Time)
+		if event.Type != EventTypeOutput || event.Data == "" {
+			continue
+		}
+		fmt.Fprintf(tm, "%.6f %d\n", max(event.Time-prev, 0), len(event.Data))
+		ts.WriteString(event.Data)
+		prev = event.Time
+	}
+
+	done := start.Add(time.Duration(max(end, header.Duration) * float64(time.Second)))
+	fmt.Fprintf(ts, "\nScript done on %s\n", done.Format(scriptTimeLayouts[0]))
+	if err := ts.Flush(); err != nil {
+		return fmt.Errorf("failed to write typescript: %w", err)
+	}
+	if err := tm.Flush(); err != nil {
+		return fmt.Errorf("failed to write timing log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package asciicast
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ttyrec recordings are a sequence of binary records, each a header of
+// three little-endian uint32 values (seconds, microseconds, data length)
+// followed by that much terminal output. The terminal size is not stored.
+
+// ttyrecMaxRecord bounds the length of one record, to reject files that
+// are not ttyrec data instead of allocating whatever the header claims
+const ttyrecMaxRecord = 16 << 20
+
+// ReadTtyrec parses a ttyrec recording. The header has the time of the
+// first record and a default 80x24 size, which callers may override.
+func ReadTtyrec(r io.Reader) (Header, []Event, error) {
+	header := Header{Version: Version2, Width: 80, Height: 24, Env: make(map[string]string)}
+	br := bufio.NewReader(r)
+
+	var events []Event
+	var start float64
+	var pending []byte // an incomplete UTF-8 sequence carried to the next record
+	var record [12]byte
+	for n := 0; ; n++ {
+		if _, err := io.ReadFull(br, record[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				// A recording cut off mid-header; keep what came before
+				break
+			}
+			return header, nil, fmt.Errorf("failed to read record %d: %w", n+1, err)
+		}
+		sec := binary.LittleEndian.Uint32(record[0:4])
+		usec := binary.LittleEndian.Uint32(record[4:8])
+		length := binary.LittleEndian.Uint32(record[8:12])
+		if usec >= 1000000 || length > ttyrecMaxRecord {
+			return header, nil, fmt.Errorf("record %d is not valid ttyrec data", n+1)
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(br, data); err != nil {
+			if !errors.Is(err, io.ErrUnexpectedEOF) && err != io.EOF {
+				return header, nil, fmt.Errorf("failed to read record %d: %w", n+1, err)
+			}
+			data = data[:0]
+		}
+
+		t := float64(sec) + float64(usec)/1e6
+		if n == 0 {
+			start = t
+			header.Timestamp = int64(sec)
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		var text string
+		text, pending = SplitUTF8(append(pending, data...))
+		if text != "" {
+			events = append(events, Event{Time: roundTimestamp(max(t-start, 0)), Type: EventTypeOutput, Data: text})
+		}
+	}
+	if len(pending) > 0 && len(events) > 0 {
+		events[len(events)-1].Data += string(pending)
+	}
+
+	return header, events, nil
+}
@@ -1,12 +1,19 @@
 package asciicast
 
 import (
+	"encoding/json"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Version constants
 const (
-	Version2 = 2
+	VersionLegacy = 0 // pre-v1 raw stdout + timing file
+	Version1      = 1 // single JSON document with a "stdout" array
+	Version2      = 2
+	Version3      = 3 // v2 layout with relative event times and a "term" object
 )
 
 // Event types
@@ -15,6 +22,7 @@ const (
 	EventTypeInput  = "i" // stdin input
 	EventTypeMarker = "m" // marker
 	EventTypeResize = "r" // resize
+	EventTypeExit   = "x" // exit status of the recorded command
 )
 
 // Header represents the asciicast v2 header
@@ -29,6 +37,9 @@ type Header struct {
 	Title         string            `json:"title,omitempty"`
 	Env           map[string]string `json:"env,omitempty"`
 	Theme         *Theme            `json:"theme,omitempty"`
+	Series        string            `json:"series,omitempty"`   // goasciinema extension
+	Audio         string            `json:"audio,omitempty"`    // goasciinema extension: narration sidecar
+	Timezone      string            `json:"timezone,omitempty"` // goasciinema extension: zone of Timestamp, see LocalTimezone
 }
 
 // Theme represents terminal color theme
@@ -38,11 +49,23 @@ type Theme struct {
 	Palette    string `json:"palette,omitempty"`
 }
 
-// Event represents a single asciicast event
+// Event represents a single asciicast event. Events of other types than
+// the ones above are kept as they are, so extensions survive a rewrite.
 type Event struct {
 	Time float64
 	Type string
 	Data string
+	// Raw is the event's data as JSON when it is not a string, which only
+	// extension event types use; Data is empty then
+	Raw json.RawMessage
+}
+
+// Value returns the event's data as it is encoded: Raw if set, else Data
+func (e Event) Value() interface{} {
+	if e.Raw != nil {
+		return e.Raw
+	}
+	return e.Data
 }
 
 // Recording represents a complete asciicast recording
@@ -61,3 +84,63 @@ func NewHeader(width, height int) Header {
 		Env:       make(map[string]string),
 	}
 }
+
+// ParseResize parses resize event data in "COLSxROWS" form
+func ParseResize(data string) (cols, rows int, ok bool) {
+	parts := strings.SplitN(data, "x", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	cols, err1 := strconv.Atoi(parts[0])
+	rows, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || cols <= 0 || rows <= 0 {
+		return 0, 0, false
+	}
+	return cols, rows, true
+}
+
+// SplitUTF8 splits data into text and the bytes of a multi-byte character
+// cut off at its end, so a character split between two reads is kept
+// whole by carrying the bytes over to the next one. Invalid bytes in the
+// text are replaced with U+FFFD, as event data must be valid UTF-8.
+func SplitUTF8(data []byte) (string, []byte) {
+	// A UTF-8 sequence is at most 4 bytes, so only the last 3 can start
+	// an incomplete one
+	for i := len(data) - 1; i >= 0 && i >= len(data)-3; i-- {
+		if !utf8.RuneStart(data[i]) {
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			return strings.ToValidUTF8(string(data[:i]), string(utf8.RuneError)), append([]byte(nil), data[i:]...)
+		}
+		break
+	}
+	return strings.ToValidUTF8(string(data), string(utf8.RuneError)), nil
+}
+
+// ParseExit parses exit event data, the command's exit status
+func ParseExit(data string) (code int, ok bool) {
+	code, err := strconv.Atoi(data)
+	return code, err == nil
+}
+
+// CollapseResizes drops resize events that are superseded by another
+// resize before any output, keeping the last size of each run
+func CollapseResizes(events []Event) []Event {
+	var out []Event
+	pending := -1 // index in out of a resize not yet followed by output
+	for _, e := range events {
+		switch e.Type {
+		case EventTypeResize:
+			if pending >= 0 {
+				// Drop the superseded resize, keeping events after it
+				out = append(out[:pending], out[pending+1:]...)
+			}
+			pending = len(out)
+		case EventTypeOutput:
+			pending = -1
+		}
+		out = append(out, e)
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+package asciicast
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Version 1 recordings, written by asciinema clients before 2.0, are a
+// single JSON document whose "stdout" array holds [delay, data] frames,
+// each delay relative to the previous frame.
+
+// v1Recording is the asciicast v1 document
+type v1Recording struct {
+	Version  int               `json:"version"`
+	Width    int               `json:"width"`
+	Height   int               `json:"height"`
+	Duration float64           `json:"duration"`
+	Command  string            `json:"command"`
+	Title    string            `json:"title"`
+	Env      map[string]string `json:"env"`
+	Stdout   []v1Frame         `json:"stdout"`
+}
+
+// v1Frame is one [delay, data] entry of a v1 stdout array
+type v1Frame struct {
+	Delay float64
+	Data  string
+}
+
+func (f *v1Frame) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 2 {
+		return fmt.Errorf("invalid stdout frame: expected [delay, data]")
+	}
+	if err := json.Unmarshal(raw[0], &f.Delay); err != nil {
+		return fmt.Errorf("invalid stdout frame delay: %w", err)
+	}
+	if err := json.Unmarshal(raw[1], &f.Data); err != nil {
+		return fmt.Errorf("invalid stdout frame data: %w", err)
+	}
+	return nil
+}
+
+// openV1 parses a v1 recording into a header and its events
+func openV1(r io.Reader) (Header, []Event, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Header{}, nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var rec v1Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return Header{}, nil, fmt.Errorf("failed to parse v1 recording: %w", err)
+	}
+
+	header := Header{
+		Version:  Version1,
+		Width:    rec.Width,
+		Height:   rec.Height,
+		Duration: rec.Duration,
+		Command:  rec.Command,
+		Title:    rec.Title,
+		Env:      rec.Env,
+	}
+
+	events := make([]Event, 0, len(rec.Stdout))
+	var elapsed float64
+	for _, frame := range rec.Stdout {
+		elapsed += frame.Delay
+		events = append(events, Event{Time: roundTimestamp(elapsed), Type: EventTypeOutput, Data: frame.Data})
+	}
+	return header, events, nil
+}
+
+// isV1Header reports whether the first line of a recording starts a v1
+// document rather than being a complete v2 header. A v1 file is either
+// pretty-printed, so its first line is a lone "{", or a single line
+// declaring version 1.
+func isV1Header(line []byte) bool {
+	if string(bytes.TrimSpace(line)) == "{" {
+		return true
+	}
+	var probe struct {
+		Version int `json:"version"`
+	}
+	return json.Unmarshal(line, &probe) == nil && probe.Version == Version1
+}
+
+// DetectVersion reports the asciicast version of a recording from its first
+// line, without parsing its events
+func DetectVersion(filename string) (int, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	reader, release, err := decompressReader(bufio.NewReader(file))
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	line, err := reader.ReadBytes('\n')
+	if err != nil && !(err == io.EOF && len(line) > 0) {
+		return 0, fmt.Errorf("failed to read header: %w", err)
+	}
+	if line[0] != '{' {
+		return VersionLegacy, nil
+	}
+	if isV1Header(line) {
+		return Version1, nil
+	}
+
+	var header Header
+	if err := json.Unmarshal(line, &header); err != nil {
+		return 0, fmt.Errorf("failed to parse header: %w", err)
+	}
+	return header.Version, nil
+}
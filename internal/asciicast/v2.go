@@ -2,19 +2,57 @@ package asciicast
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 )
 
-// Writer writes asciicast v2 format
+// TimePolicy controls how a Writer handles events whose timestamps go
+// backwards relative to the previously written event
+type TimePolicy int
+
+const (
+	TimeAllow  TimePolicy = iota // write timestamps as given
+	TimeClamp                    // raise the timestamp to the previous one
+	TimeReject                   // refuse the event with ErrNonMonotonic
+)
+
+// ErrNonMonotonic is returned by WriteEvent under TimeReject when an event's
+// timestamp is earlier than the previous event's
+var ErrNonMonotonic = errors.New("event timestamp goes backwards")
+
+// ErrAppendV3 is returned by NewWriter for an append to an asciicast v3
+// recording, whose relative event times v2 events can't continue
+var ErrAppendV3 = errors.New("can't append to an asciicast v3 recording (convert it with 'convert --to v2' first)")
+
+// writeBufferSize is how much output the Writer buffers between writes to
+// the file, so bursts of small events don't cost a syscall each
+const writeBufferSize = 64 * 1024
+
+// Writer writes asciicast v2 format. Events are buffered; see
+// SetFlushPolicy for getting them to disk promptly.
 type Writer struct {
-	file       *os.File
+	out        io.Writer // the file, a compressor writing to it, or a stream
+	closer     io.Closer // closes out, unless the caller owns it
 	writer     *bufio.Writer
 	mu         sync.Mutex
 	timeOffset float64
+	lastTime   float64
+	timePolicy TimePolicy
+	fsync      bool
+	filename   string
+	duration   bool          // rewrite the header with the duration on Close
+	dirty      bool          // events written since the last flush
+	size       int64         // bytes written, before compression
+	stopFlush  chan struct{} // stops the periodic flush, if running
+	flushDone  chan struct{}
 }
 
 // NewWriter creates a new asciicast v2 writer
@@ -26,6 +64,13 @@ func NewWriter(filename string, header Header, append bool) (*Writer, error) {
 	if append {
 		// Check if file exists and read last timestamp
 		if info, statErr := os.Stat(filename); statErr == nil && info.Size() > 0 {
+			if reader, err := Open(filename); err == nil {
+				version := reader.Header.Version
+				reader.Close()
+				if version == Version3 {
+					return nil, ErrAppendV3
+				}
+			}
 			timeOffset, err = getLastTimestamp(filename)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get last timestamp: %w", err)
@@ -34,34 +79,137 @@ func NewWriter(filename string, header Header, append bool) (*Writer, error) {
 			if err != nil {
 				return nil, fmt.Errorf("failed to open file for append: %w", err)
 			}
-			return &Writer{file: file, writer: bufio.NewWriter(file), timeOffset: timeOffset}, nil
+			out, err := outputFor(file, filename)
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+			return &Writer{
+				out:        out,
+				closer:     out,
+				writer:     bufio.NewWriterSize(out, writeBufferSize),
+				filename:   filename,
+				timeOffset: timeOffset,
+				lastTime:   timeOffset,
+				size:       info.Size(),
+			}, nil
 		}
 	}
 
-	file, err = os.Create(filename)
+	out, err := Create(filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create file: %w", err)
+		return nil, err
 	}
+	w, err := newWriter(out, header)
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+	w.closer = out
+	w.filename = filename
+	return w, nil
+}
 
-	writer := bufio.NewWriter(file)
+// NewWriterTo creates an asciicast v2 writer streaming to out, such as a
+// socket, pipe or buffer. Closing the Writer flushes it but does not close
+// out.
+func NewWriterTo(out io.Writer, header Header) (*Writer, error) {
+	return newWriter(out, header)
+}
+
+// newWriter writes the header to out and returns a writer for the events
+func newWriter(out io.Writer, header Header) (*Writer, error) {
+	writer := bufio.NewWriterSize(out, writeBufferSize)
 
 	// Write header
 	headerBytes, err := json.Marshal(header)
 	if err != nil {
-		file.Close()
 		return nil, fmt.Errorf("failed to marshal header: %w", err)
 	}
 
 	if _, err := writer.Write(headerBytes); err != nil {
-		file.Close()
 		return nil, fmt.Errorf("failed to write header: %w", err)
 	}
 	if err := writer.WriteByte('\n'); err != nil {
-		file.Close()
 		return nil, fmt.Errorf("failed to write newline: %w", err)
 	}
 
-	return &Writer{file: file, writer: writer, timeOffset: timeOffset}, nil
+	return &Writer{out: out, writer: writer, size: int64(len(headerBytes)) + 1}, nil
+}
+
+// SetTimePolicy sets how out-of-order timestamps are handled
+func (w *Writer) SetTimePolicy(policy TimePolicy) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timePolicy = policy
+}
+
+// SetWriteDuration has Close rewrite the header with the recording's
+// duration, the time of its last event. This copies the whole file, so it
+// is optional, and it has no effect on writers from NewWriterTo.
+func (w *Writer) SetWriteDuration(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.duration = enabled
+}
+
+// SetFlushPolicy has buffered events written to the file every interval
+// (never, if interval is zero), and with fsync also committed to stable
+// storage, bounding how much of a recording a crash can lose. It may be
+// called once, before writing events.
+func (w *Writer) SetFlushPolicy(interval time.Duration, fsync bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fsync = fsync
+	if interval <= 0 || w.stopFlush != nil {
+		return
+	}
+
+	w.stopFlush = make(chan struct{})
+	w.flushDone = make(chan struct{})
+	go func() {
+		defer close(w.flushDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.mu.Lock()
+				if w.dirty {
+					w.flush()
+				}
+				w.mu.Unlock()
+			case <-w.stopFlush:
+				return
+			}
+		}
+	}()
+}
+
+// Flush writes buffered events to the file, and commits them to stable
+// storage if the flush policy asks for fsync
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush()
+}
+
+func (w *Writer) flush() error {
+	w.dirty = false
+	if err := w.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush buffer: %w", err)
+	}
+	if f, ok := w.out.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return fmt.Errorf("failed to flush compressor: %w", err)
+		}
+	}
+	if s, ok := w.out.(interface{ Sync() error }); ok && w.fsync {
+		if err := s.Sync(); err != nil {
+			return fmt.Errorf("failed to sync file: %w", err)
+		}
+	}
+	return nil
 }
 
 // WriteEvent writes a single event
@@ -72,11 +220,21 @@ func (w *Writer) WriteEvent(event Event) error {
 	// Adjust timestamp with offset
 	adjustedTime := event.Time + w.timeOffset
 
+	if adjustedTime < w.lastTime {
+		switch w.timePolicy {
+		case TimeClamp:
+			adjustedTime = w.lastTime
+		case TimeReject:
+			return fmt.Errorf("%w: %.6f after %.6f", ErrNonMonotonic, adjustedTime, w.lastTime)
+		}
+	}
+	w.lastTime = adjustedTime
+
 	// Format: [timestamp, "type", "data"]
 	eventData := []interface{}{
 		roundTimestamp(adjustedTime),
 		event.Type,
-		event.Data,
+		event.Value(),
 	}
 
 	eventBytes, err := json.Marshal(eventData)
@@ -90,10 +248,20 @@ func (w *Writer) WriteEvent(event Event) error {
 	if err := w.writer.WriteByte('\n'); err != nil {
 		return fmt.Errorf("failed to write newline: %w", err)
 	}
+	w.dirty = true
+	w.size += int64(len(eventBytes)) + 1
 
 	return nil
 }
 
+// Size returns how many bytes of asciicast have been written, including
+// the header and events not yet flushed. Compressed files are smaller.
+func (w *Writer) Size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
 // WriteOutput writes an output event
 func (w *Writer) WriteOutput(timestamp float64, data string) error {
 	return w.WriteEvent(Event{Time: timestamp, Type: EventTypeOutput, Data: data})
@@ -114,22 +282,87 @@ func (w *Writer) WriteResize(timestamp float64, cols, rows int) error {
 	return w.WriteEvent(Event{Time: timestamp, Type: EventTypeResize, Data: fmt.Sprintf("%dx%d", cols, rows)})
 }
 
+// WriteExit writes an exit event with the recorded command's exit status
+func (w *Writer) WriteExit(timestamp float64, code int) error {
+	return w.WriteEvent(Event{Time: timestamp, Type: EventTypeExit, Data: strconv.Itoa(code)})
+}
+
 // Close flushes the buffer and closes the writer
 func (w *Writer) Close() error {
-	if err := w.writer.Flush(); err != nil {
-		w.file.Close()
-		return fmt.Errorf("failed to flush buffer: %w", err)
+	if w.stopFlush != nil {
+		close(w.stopFlush)
+		<-w.flushDone
+	}
+	if err := w.flush(); err != nil {
+		if w.closer != nil {
+			w.closer.Close()
+		}
+		return err
+	}
+	if w.closer == nil {
+		return nil
+	}
+	if err := w.closer.Close(); err != nil {
+		return err
+	}
+	if w.duration {
+		return writeDuration(w.filename, roundTimestamp(w.lastTime), w.fsync)
+	}
+	return nil
+}
+
+// maxParseErrorData bounds how much of a bad line a ParseError quotes
+const maxParseErrorData = 80
+
+// ParseError is returned by ReadEvent for a line that is not a valid
+// event, locating it in the (decompressed) file
+type ParseError struct {
+	Line   int
+	Offset int64  // byte offset of the start of the line
+	Data   string // the line, without its newline
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	data := e.Data
+	if len(data) > maxParseErrorData {
+		data = data[:maxParseErrorData] + "..."
 	}
-	return w.file.Close()
+	return fmt.Sprintf("line %d (byte %d): %v: %s", e.Line, e.Offset, e.Err, data)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
 }
 
-// Reader reads asciicast v2 format
+// Reader reads asciicast v2 and v3 formats, and v1 and legacy recordings
+// converted on open
 type Reader struct {
-	Header Header
-	file   *os.File
-	reader *bufio.Reader
+	Header  Header
+	file    *os.File // the file opened by Open, if any
+	release func()   // frees the decompressor, if any
+	reader  *bufio.Reader
+	elapsed float64 // running time of v3 recordings, whose times are relative
+	events  []Event // pre-parsed events for non-streaming formats
+	line    int     // line number of the last line read
+	raw     []byte  // the last line read, as stored in the file
+	// lastTime is the time of the last event read, and truncated is set
+	// when the file ends mid-event
+	lastTime  float64
+	truncated bool
+	event     Event  // the event read by Next
+	err       error  // the error that stopped Next
+	offset    int64  // byte offset of the next line
+	lenient   bool   // skip bad lines rather than fail
+	skipped   int    // bad lines skipped
+	follow    bool   // treat the end of the file as the end so far
+	partial   []byte // an incomplete last line, when following
 }
 
+// errNoHeader is returned by newReader for data that doesn't start with a
+// JSON header, which Open reads as a legacy recording
+var errNoHeader = errors.New("recording does not start with a JSON header")
+
 // Open opens an asciicast file for reading
 func Open(filename string) (*Reader, error) {
 	file, err := os.Open(filename)
@@ -137,44 +370,195 @@ func Open(filename string) (*Reader, error) {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 
-	reader := bufio.NewReader(file)
+	r, err := newReader(bufio.NewReader(file))
+	if errors.Is(err, errNoHeader) {
+		// Without a JSON header only files that look like a legacy raw dump
+		// are read as one, so garbage isn't taken for a recording
+		file.Close()
+		if !isLegacy(filename) {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+		header, events, err := openLegacy(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read legacy recording: %w", err)
+		}
+		return &Reader{Header: header, events: events}, nil
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	r.file = file
+	return r, nil
+}
+
+// NewReader reads a recording from r, such as an HTTP response body or
+// stdin, decompressing it if it is zstd data. Legacy recordings need their
+// timing files alongside, so only Open reads them. Closing the Reader does
+// not close r.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader, err := newReader(bufio.NewReader(r))
+	if errors.Is(err, errNoHeader) {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	return reader, err
+}
+
+// newReader reads the header of a recording from r
+func newReader(r *bufio.Reader) (*Reader, error) {
+	reader, release, err := decompressReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if first, err := reader.Peek(1); err == nil && first[0] != '{' {
+		release()
+		return nil, errNoHeader
+	}
 
 	// Read header line
 	headerLine, err := reader.ReadBytes('\n')
-	if err != nil {
-		file.Close()
+	if err != nil && !(err == io.EOF && len(headerLine) > 0) {
+		release()
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
 
+	// A v1 recording is one JSON document, which is parsed in full
+	if isV1Header(headerLine) {
+		defer release()
+		header, events, err := openV1(io.MultiReader(bytes.NewReader(headerLine), reader))
+		if err != nil {
+			return nil, err
+		}
+		return &Reader{Header: header, events: events}, nil
+	}
+
 	var header Header
 	if err := json.Unmarshal(headerLine, &header); err != nil {
-		file.Close()
+		release()
 		return nil, fmt.Errorf("failed to parse header: %w", err)
 	}
+	if header.Version == Version3 {
+		if header, err = parseV3Header(headerLine); err != nil {
+			release()
+			return nil, err
+		}
+	}
 
 	return &Reader{
-		Header: header,
-		file:   file,
-		reader: reader,
+		Header:  header,
+		release: release,
+		reader:  reader,
+		line:    1,
+		raw:     headerLine,
+		offset:  int64(len(headerLine)),
 	}, nil
 }
 
 // ReadEvent reads the next event
 func (r *Reader) ReadEvent() (*Event, error) {
-	line, err := r.reader.ReadBytes('\n')
-	if err != nil {
-		if err == io.EOF {
+	if r.reader == nil {
+		if len(r.events) == 0 {
 			return nil, io.EOF
 		}
+		event := r.events[0]
+		r.events = r.events[1:]
+		return &event, nil
+	}
+
+	for {
+		offset := r.offset - int64(len(r.partial))
+		line, err := r.reader.ReadBytes('\n')
+		r.offset += int64(len(line))
+		if len(r.partial) > 0 {
+			line = append(r.partial, line...)
+		}
+		r.partial = nil
+		if err != nil {
+			if r.follow && err == io.EOF {
+				r.partial = line
+				return nil, io.EOF
+			}
+			return r.readLastLine(line, err)
+		}
+		r.line++
+		r.raw = line
+
+		// Skip empty lines, and v3 comments
+		if len(line) <= 1 || (r.Header.Version == Version3 && line[0] == '#') {
+			continue
+		}
+
+		event, err := r.parseEvent(line)
+		if err != nil {
+			if r.lenient {
+				r.skipped++
+				continue
+			}
+			return nil, &ParseError{Line: r.line, Offset: offset, Data: string(bytes.TrimRight(line, "\r\n")), Err: err}
+		}
+		return event, nil
+	}
+}
+
+// SetLenient has ReadEvent skip lines that aren't valid events, counting
+// them in Skipped, instead of failing with a ParseError
+func (r *Reader) SetLenient(lenient bool) {
+	r.lenient = lenient
+}
+
+// SetFollow has ReadEvent treat the end of the file as the end of what
+// has been written so far, for reading a recording while it is being
+// recorded: a last line without a newline is kept until the rest of it
+// is written, and io.EOF is returned so the caller can try again later.
+// Compressed recordings can't be followed.
+func (r *Reader) SetFollow(follow bool) {
+	r.follow = follow
+}
+
+// Skipped returns how many bad lines a lenient reader has skipped
+func (r *Reader) Skipped() int {
+	return r.skipped
+}
+
+// readLastLine handles the end of the file. A final line without a
+// newline is still an event if it parses; otherwise it is what was being
+// written when the recorder was interrupted, and is dropped. The duration
+// of an interrupted recording is set from its last event.
+func (r *Reader) readLastLine(line []byte, err error) (*Event, error) {
+	switch err {
+	case io.EOF:
+	case io.ErrUnexpectedEOF:
+		// A compressed stream cut off mid-frame
+		r.truncated = true
+	default:
 		return nil, fmt.Errorf("failed to read event: %w", err)
 	}
 
-	// Skip empty lines
-	if len(line) <= 1 {
-		return r.ReadEvent()
+	if len(bytes.TrimSpace(line)) > 0 {
+		if event, err := r.parseEvent(line); err == nil {
+			r.line++
+			r.raw = line
+			return event, nil
+		}
+		r.truncated = true
+	}
+	if r.truncated && r.lastTime > r.Header.Duration {
+		r.Header.Duration = r.lastTime
 	}
+	return nil, io.EOF
+}
 
-	var eventData []interface{}
+// Truncated reports whether the recording was found to end mid-event, as
+// happens when the recorder is killed. It is known once ReadEvent has
+// returned io.EOF.
+func (r *Reader) Truncated() bool {
+	return r.truncated
+}
+
+// parseEvent parses one event line
+func (r *Reader) parseEvent(line []byte) (*Event, error) {
+	var eventData []json.RawMessage
 	if err := json.Unmarshal(line, &eventData); err != nil {
 		return nil, fmt.Errorf("failed to parse event: %w", err)
 	}
@@ -183,46 +567,97 @@ func (r *Reader) ReadEvent() (*Event, error) {
 		return nil, fmt.Errorf("invalid event format")
 	}
 
-	timestamp, ok := eventData[0].(float64)
-	if !ok {
+	var timestamp float64
+	if err := json.Unmarshal(eventData[0], &timestamp); err != nil {
 		return nil, fmt.Errorf("invalid timestamp type")
 	}
 
-	eventType, ok := eventData[1].(string)
-	if !ok {
+	var eventType string
+	if err := json.Unmarshal(eventData[1], &eventType); err != nil {
 		return nil, fmt.Errorf("invalid event type")
 	}
 
-	data, ok := eventData[2].(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid event data type")
+	// The known types carry strings; extensions may carry any JSON, which
+	// is kept as it is
+	var data string
+	var raw json.RawMessage
+	if err := json.Unmarshal(eventData[2], &data); err != nil {
+		switch eventType {
+		case EventTypeOutput, EventTypeInput, EventTypeMarker, EventTypeResize, EventTypeExit:
+			return nil, fmt.Errorf("invalid event data type")
+		}
+		raw = eventData[2]
 	}
 
+	if r.Header.Version == Version3 {
+		r.elapsed += timestamp
+		timestamp = roundTimestamp(r.elapsed)
+	}
+
+	r.lastTime = timestamp
+
 	return &Event{
 		Time: timestamp,
 		Type: eventType,
 		Data: data,
+		Raw:  raw,
 	}, nil
 }
 
-// Events returns a channel of events
-func (r *Reader) Events() <-chan Event {
-	ch := make(chan Event)
-	go func() {
-		defer close(ch)
-		for {
-			event, err := r.ReadEvent()
-			if err != nil {
-				return
-			}
-			ch <- *event
+// Line returns the line number of the most recently read event, or 0 for
+// formats that are not line-based
+func (r *Reader) Line() int {
+	if r.reader == nil {
+		return 0
+	}
+	return r.line
+}
+
+// Next reads the next event, which Event then returns. It returns false
+// at the end of the recording or at the first error, which Err reports, so
+// a corrupt file can be told apart from a complete one:
+//
+//	for reader.Next() {
+//		event := reader.Event()
+//		...
+//	}
+//	if err := reader.Err(); err != nil {
+//		...
+//	}
+func (r *Reader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	event, err := r.ReadEvent()
+	if err != nil {
+		if err != io.EOF {
+			r.err = err
 		}
-	}()
-	return ch
+		return false
+	}
+	r.event = *event
+	return true
+}
+
+// Event returns the event read by the last call to Next
+func (r *Reader) Event() Event {
+	return r.event
 }
 
-// Close closes the reader
+// Err returns the error that stopped Next, or nil if it reached the end
+// of the recording
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Close closes the reader, and the file if it was opened by Open
 func (r *Reader) Close() error {
+	if r.release != nil {
+		r.release()
+	}
+	if r.file == nil {
+		return nil
+	}
 	return r.file.Close()
 }
 
@@ -232,6 +667,67 @@ func roundTimestamp(t float64) float64 {
 	return float64(int64(t*1000000)) / 1000000
 }
 
+// writeDuration rewrites a recording's header with the given duration,
+// through a temporary file that replaces it
+func writeDuration(filename string, duration float64, fsync bool) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to reopen recording: %w", err)
+	}
+	defer file.Close()
+	reader, release, err := decompressReader(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	headerLine, err := reader.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	var header Header
+	if err := json.Unmarshal(headerLine, &header); err != nil {
+		return fmt.Errorf("failed to parse header: %w", err)
+	}
+	header.Duration = duration
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("failed to marshal header: %w", err)
+	}
+
+	// The temporary name keeps the extension, which selects compression
+	tmpName := filepath.Join(filepath.Dir(filename), ".tmp-"+filepath.Base(filename))
+	out, err := Create(tmpName)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(out, writeBufferSize)
+	bw.Write(headerBytes)
+	bw.WriteByte('\n')
+	if _, err := io.Copy(bw, reader); err != nil {
+		out.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to copy events: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		out.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	if s, ok := out.(interface{ Sync() error }); ok && fsync {
+		s.Sync()
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to replace recording: %w", err)
+	}
+	return nil
+}
+
 func getLastTimestamp(filename string) (float64, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -239,7 +735,11 @@ func getLastTimestamp(filename string) (float64, error) {
 	}
 	defer file.Close()
 
-	reader := bufio.NewReader(file)
+	reader, release, err := decompressReader(bufio.NewReader(file))
+	if err != nil {
+		return 0, err
+	}
+	defer release()
 	var lastTimestamp float64
 
 	// Skip header
@@ -0,0 +1,148 @@
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Version 3 recordings keep v2's newline-delimited layout, but describe the
+// terminal in a nested "term" object and time each event relative to the
+// previous one. Lines starting with "#" are comments.
+
+// v3Header is the asciicast v3 header
+type v3Header struct {
+	Version       int               `json:"version"`
+	Term          v3Term            `json:"term"`
+	Timestamp     int64             `json:"timestamp,omitempty"`
+	Duration      float64           `json:"duration,omitempty"`
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Command       string            `json:"command,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+	Series        string            `json:"series,omitempty"`   // goasciinema extension
+	Audio         string            `json:"audio,omitempty"`    // goasciinema extension
+	Timezone      string            `json:"timezone,omitempty"` // goasciinema extension
+}
+
+// v3Term describes the recorded terminal
+type v3Term struct {
+	Cols  int    `json:"cols"`
+	Rows  int    `json:"rows"`
+	Type  string `json:"type,omitempty"`
+	Theme *Theme `json:"theme,omitempty"`
+}
+
+// parseV3Header converts a v3 header line to a Header. The terminal type
+// is kept in the env as TERM, where v2 stores it.
+func parseV3Header(line []byte) (Header, error) {
+	var h v3Header
+	if err := json.Unmarshal(line, &h); err != nil {
+		return Header{}, fmt.Errorf("failed to parse header: %w", err)
+	}
+	header := Header{
+		Version:       Version3,
+		Width:         h.Term.Cols,
+		Height:        h.Term.Rows,
+		Timestamp:     h.Timestamp,
+		Duration:      h.Duration,
+		IdleTimeLimit: h.IdleTimeLimit,
+		Command:       h.Command,
+		Title:         h.Title,
+		Env:           h.Env,
+		Theme:         h.Term.Theme,
+		Series:        h.Series,
+		Audio:         h.Audio,
+		Timezone:      h.Timezone,
+	}
+	if h.Term.Type != "" {
+		if header.Env == nil {
+			header.Env = make(map[string]string)
+		}
+		if _, ok := header.Env["TERM"]; !ok {
+			header.Env["TERM"] = h.Term.Type
+		}
+	}
+	return header, nil
+}
+
+// EncodeV3 writes a recording in asciicast v3 format
+func EncodeV3(w io.Writer, header Header, events []Event) error {
+	h := v3Header{
+		Version: Version3,
+		Term: v3Term{
+			Cols:  header.Width,
+			Rows:  header.Height,
+			Type:  header.Env["TERM"],
+			Theme: header.Theme,
+		},
+		Timestamp:     header.Timestamp,
+		Duration:      header.Duration,
+		IdleTimeLimit: header.IdleTimeLimit,
+		Command:       header.Command,
+		Title:         header.Title,
+		Env:           header.Env,
+		Series:        header.Series,
+		Audio:         header.Audio,
+		Timezone:      header.Timezone,
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(h); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	var prev float64
+	for _, event := range events {
+		interval := math.Max(event.Time-prev, 0)
+		prev = event.Time
+		if err := enc.Encode([]interface{}{roundTimestamp(interval), event.Type, event.Value()}); err != nil {
+			return fmt.Errorf("failed to write event: %w", err)
+		}
+	}
+	return bw.Flush()
+}
+
+// EncodeV1 writes a recording in asciicast v1 format. v1 has only output
+// frames, so other events are left out.
+func EncodeV1(w io.Writer, header Header, events []Event) error {
+	rec := struct {
+		Version  int               `json:"version"`
+		Width    int               `json:"width"`
+		Height   int               `json:"height"`
+		Duration float64           `json:"duration"`
+		Command  string            `json:"command"`
+		Title    string            `json:"title"`
+		Env      map[string]string `json:"env"`
+		Stdout   [][]interface{}   `json:"stdout"`
+	}{
+		Version: Version1,
+		Width:   header.Width,
+		Height:  header.Height,
+		Command: header.Command,
+		Title:   header.Title,
+		Env:     header.Env,
+		Stdout:  [][]interface{}{},
+	}
+
+	var prev float64
+	for _, event := range events {
+		if event.Type != EventTypeOutput {
+			continue
+		}
+		delay := math.Max(event.Time-prev, 0)
+		prev = event.Time
+		rec.Stdout = append(rec.Stdout, []interface{}{roundTimestamp(delay), event.Data})
+	}
+	rec.Duration = roundTimestamp(prev)
+	if rec.Env == nil {
+		rec.Env = map[string]string{}
+	}
+
+	if err := json.NewEncoder(w).Encode(rec); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package asciicast
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// Problem describes an issue found while validating a recording
+type Problem struct {
+	Line    int // 0 when the format is not line-based
+	Message string
+	Warning bool // players can still handle the recording
+}
+
+func (p Problem) String() string {
+	message := p.Message
+	if p.Warning {
+		message = "warning: " + message
+	}
+	if p.Line > 0 {
+		return fmt.Sprintf("line %d: %s", p.Line, message)
+	}
+	return message
+}
+
+// Errors returns the problems that are not warnings
+func Errors(problems []Problem) []Problem {
+	var errs []Problem
+	for _, p := range problems {
+		if !p.Warning {
+			errs = append(errs, p)
+		}
+	}
+	return errs
+}
+
+// Validate reads a recording and reports problems that would confuse
+// players, such as an unsupported version, a recording with no events,
+// invalid UTF-8, or timestamps that go backwards. Unknown event types are
+// reported as warnings, since players skip events they don't know.
+// Reading continues past unparseable events, so every bad line is
+// reported. An error is returned only if the file or its header cannot be
+// read.
+func Validate(filename string) ([]Problem, error) {
+	reader, err := Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var problems []Problem
+	lineBased := reader.reader != nil
+	header := reader.Header
+	switch {
+	case lineBased && header.Version == 0:
+		problems = append(problems, Problem{Line: 1, Message: "header has no version"})
+	case lineBased && header.Version != Version2 && header.Version != Version3,
+		header.Version < VersionLegacy || header.Version > Version3:
+		problems = append(problems, Problem{Line: 1, Message: fmt.Sprintf("unsupported version %d", header.Version)})
+	}
+	if header.Width <= 0 || header.Height <= 0 {
+		problems = append(problems, Problem{Line: 1, Message: fmt.Sprintf("invalid terminal size %dx%d", header.Width, header.Height)})
+	}
+	if header.Timestamp < 0 {
+		problems = append(problems, Problem{Line: 1, Message: fmt.Sprintf("negative timestamp %d", header.Timestamp)})
+	}
+	if header.Duration < 0 {
+		problems = append(problems, Problem{Line: 1, Message: fmt.Sprintf("negative duration %g", header.Duration)})
+	}
+	if header.IdleTimeLimit < 0 {
+		problems = append(problems, Problem{Line: 1, Message: fmt.Sprintf("negative idle_time_limit %g", header.IdleTimeLimit)})
+	}
+	if lineBased && !utf8.Valid(reader.raw) {
+		problems = append(problems, Problem{Line: 1, Message: "header is not valid UTF-8"})
+	}
+
+	var lastTime float64
+	var events int
+	lastLine := reader.Line()
+	for {
+		event, err := reader.ReadEvent()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			message := err.Error()
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) {
+				message = parseErr.Err.Error()
+			}
+			problems = append(problems, Problem{Line: reader.Line(), Message: message})
+			// Carry on with the next line, unless this one could not be read
+			if !lineBased || reader.Line() == lastLine {
+				break
+			}
+			lastLine = reader.Line()
+			continue
+		}
+		lastLine = reader.Line()
+		events++
+
+		if lineBased && !utf8.Valid(reader.raw) {
+			problems = append(problems, Problem{Line: reader.Line(), Message: "event is not valid UTF-8"})
+		}
+		switch event.Type {
+		case EventTypeOutput, EventTypeInput, EventTypeMarker:
+		case EventTypeResize:
+			if _, _, ok := ParseResize(event.Data); !ok {
+				problems = append(problems, Problem{Line: reader.Line(), Message: fmt.Sprintf("invalid resize %q", event.Data)})
+			}
+		case EventTypeExit:
+			if _, ok := ParseExit(event.Data); !ok {
+				problems = append(problems, Problem{Line: reader.Line(), Message: fmt.Sprintf("invalid exit status %q", event.Data)})
+			}
+		default:
+			problems = append(problems, Problem{Line: reader.Line(), Message: fmt.Sprintf("unknown event type %q", event.Type), Warning: true})
+		}
+
+		if event.Time < 0 {
+			problems = append(problems, Problem{Line: reader.Line(), Message: fmt.Sprintf("negative timestamp %.6f", event.Time)})
+		}
+		if event.Time < lastTime {
+			problems = append(problems, Problem{
+				Line:    reader.Line(),
+				Message: fmt.Sprintf("timestamp %.6f goes backwards (previous %.6f)", event.Time, lastTime),
+			})
+		} else {
+			lastTime = event.Time
+		}
+	}
+
+	if reader.Truncated() {
+		problems = append(problems, Problem{Line: reader.Line() + 1, Message: "recording ends mid-event, as if interrupted (see the repair command)"})
+	}
+	if events == 0 {
+		problems = append(problems, Problem{Message: "recording has no events"})
+	}
+
+	return problems, nil
+}
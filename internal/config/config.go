@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -16,12 +17,17 @@ type Config struct {
 	Record   RecordConfig
 	Play     PlayConfig
 	Database DatabaseConfig
+	Redact   RedactConfig
 	homeDir  string
 }
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
 	Path string
+	// SearchPaths are additional databases queried by search
+	SearchPaths []string
+	// StoreRecordings keeps a compressed copy of each processed recording
+	StoreRecordings bool
 }
 
 // APIConfig holds API-related configuration
@@ -35,7 +41,46 @@ type RecordConfig struct {
 	Stdin         bool
 	Env           []string
 	IdleTimeLimit float64
+	// Cols and Rows set the recording size when --cols/--rows aren't
+	// given; headless recordings without either are 80x24
+	Cols          int
+	Rows          int
 	Quiet         bool
+	NotifyMarkers string
+	SecretScan    string
+	// CaptureEnvExtended records terminal capabilities in the header env
+	CaptureEnvExtended bool
+	CaptureTheme       bool
+	PauseOnLock        bool
+	Indicator          string
+	// AudioCommand captures narration for rec --audio
+	AudioCommand string
+	// TmpDir is where recordings without a filename are created
+	TmpDir string
+	// TmpMaxAge removes old temporary recordings when set
+	TmpMaxAge time.Duration
+	// Transports are URLs that every recording's events are shipped to
+	Transports []string
+	// WatchDir is where watch-exec stores its recordings
+	WatchDir string
+	// Compress is the compression for new recordings ("" or "zstd")
+	Compress string
+	// FlushInterval is how often recorded events are written to disk, and
+	// Fsync commits them to stable storage each time
+	FlushInterval time.Duration
+	Fsync         bool
+	// MaxDuration and MaxSize (like "10M") end or, with Rotate, split
+	// recordings that grow past them
+	MaxDuration time.Duration
+	MaxSize     string
+	Rotate      bool
+}
+
+// RedactConfig holds configuration for the redact command
+type RedactConfig struct {
+	// Patterns are regular expressions redacted in addition to the
+	// built-in credential formats
+	Patterns []string
 }
 
 // PlayConfig holds playback configuration
@@ -54,7 +99,8 @@ func Load() (*Config, error) {
 			URL: "https://asciinema.org",
 		},
 		Record: RecordConfig{
-			Env: []string{"SHELL", "TERM"},
+			Env:           []string{"SHELL", "TERM"},
+			FlushInterval: time.Second,
 		},
 		Play: PlayConfig{
 			Speed: 1.0,
@@ -99,6 +145,17 @@ func (c *Config) GetDatabasePath() string {
 	return c.Database.Path
 }
 
+// GetSearchDatabasePaths returns every database search should query: the
+// primary database followed by any additional search databases
+func (c *Config) GetSearchDatabasePaths() []string {
+	return append([]string{c.Database.Path}, c.Database.SearchPaths...)
+}
+
+// SetDatabasePath overrides the database path (e.g. from a command-line flag)
+func (c *Config) SetDatabasePath(path string) {
+	c.Database.Path = expandPath(path)
+}
+
 // parseGoasciinemaConfig parses the simple ~/.goasciinema config file
 func parseGoasciinemaConfig(content string, cfg *Config) {
 	scanner := bufio.NewScanner(strings.NewReader(content))
@@ -123,6 +180,14 @@ func parseGoasciinemaConfig(content string, cfg *Config) {
 		switch key {
 		case "database":
 			cfg.Database.Path = expandPath(value)
+		case "search_databases":
+			for _, path := range strings.Split(value, ",") {
+				if path = strings.TrimSpace(path); path != "" {
+					cfg.Database.SearchPaths = append(cfg.Database.SearchPaths, expandPath(path))
+				}
+			}
+		case "store_recordings":
+			cfg.Database.StoreRecordings = value == "yes" || value == "true" || value == "1"
 		}
 	}
 }
@@ -159,6 +224,24 @@ func (c *Config) GetInstallID() (string, error) {
 	return id, nil
 }
 
+// UploadStateDir returns the directory where resumable upload progress is kept
+func (c *Config) UploadStateDir() string {
+	return filepath.Join(c.homeDir, "uploads")
+}
+
+// RecordTempDir returns the directory for recordings made without a
+// filename: the configured tmp_dir, else $XDG_RUNTIME_DIR, else the system
+// temporary directory
+func (c *Config) RecordTempDir() string {
+	if c.Record.TmpDir != "" {
+		return c.Record.TmpDir
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
+
 func getConfigDir() string {
 	// Check ASCIINEMA_CONFIG_HOME first
 	if dir := os.Getenv("ASCIINEMA_CONFIG_HOME"); dir != "" {
@@ -216,8 +299,61 @@ func parseConfig(content string, cfg *Config) {
 				cfg.Record.Stdin = value == "yes" || value == "true" || value == "1"
 			case "idle_time_limit":
 				cfg.Record.IdleTimeLimit, _ = strconv.ParseFloat(value, 64)
+			case "cols":
+				cfg.Record.Cols, _ = strconv.Atoi(value)
+			case "rows":
+				cfg.Record.Rows, _ = strconv.Atoi(value)
 			case "quiet":
 				cfg.Record.Quiet = value == "yes" || value == "true" || value == "1"
+			case "notify_markers":
+				cfg.Record.NotifyMarkers = value
+			case "secret_scan":
+				cfg.Record.SecretScan = value
+			case "capture_env_extended":
+				cfg.Record.CaptureEnvExtended = value == "yes" || value == "true" || value == "1"
+			case "capture_theme":
+				cfg.Record.CaptureTheme = value == "yes" || value == "true" || value == "1"
+			case "pause_on_lock":
+				cfg.Record.PauseOnLock = value == "yes" || value == "true" || value == "1"
+			case "indicator":
+				cfg.Record.Indicator = value
+			case "audio_command":
+				cfg.Record.AudioCommand = value
+			case "tmp_dir":
+				cfg.Record.TmpDir = expandPath(value)
+			case "tmp_max_age":
+				cfg.Record.TmpMaxAge, _ = time.ParseDuration(value)
+			case "compress":
+				cfg.Record.Compress = value
+			case "flush_interval":
+				if d, err := time.ParseDuration(value); err == nil && d > 0 {
+					cfg.Record.FlushInterval = d
+				}
+			case "fsync":
+				cfg.Record.Fsync = value == "yes" || value == "true" || value == "1"
+			case "max_duration":
+				if d, err := time.ParseDuration(value); err == nil && d > 0 {
+					cfg.Record.MaxDuration = d
+				}
+			case "max_size":
+				cfg.Record.MaxSize = value
+			case "rotate":
+				cfg.Record.Rotate = value == "yes" || value == "true" || value == "1"
+			case "watch_dir":
+				cfg.Record.WatchDir = expandPath(value)
+			case "env":
+				cfg.Record.Env = nil
+				for _, rule := range strings.Split(value, ",") {
+					if rule = strings.TrimSpace(rule); rule != "" {
+						cfg.Record.Env = append(cfg.Record.Env, rule)
+					}
+				}
+			case "transports":
+				for _, transport := range strings.Split(value, ",") {
+					if transport = strings.TrimSpace(transport); transport != "" {
+						cfg.Record.Transports = append(cfg.Record.Transports, transport)
+					}
+				}
 			}
 		case "play":
 			switch key {
@@ -228,6 +364,11 @@ func parseConfig(content string, cfg *Config) {
 			case "maxwait":
 				cfg.Play.MaxWait, _ = strconv.ParseFloat(value, 64)
 			}
+		case "redact":
+			switch key {
+			case "pattern":
+				cfg.Redact.Patterns = append(cfg.Redact.Patterns, value)
+			}
 		}
 	}
 }
@@ -6,10 +6,13 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -30,34 +33,62 @@ type ProcessedFile struct {
 
 // Session represents a session record in the database
 type Session struct {
-	ID        int64
-	FileID    int64
-	Version   int
-	Width     int
-	Height    int
-	Timestamp int64
-	Shell     string
-	Term      string
-	Content   string
+	ID           int64
+	FileID       int64
+	Version      int
+	Width        int
+	Height       int
+	Timestamp    int64
+	Shell        string
+	Term         string
+	Content      string
+	Duration     float64
+	IdleDuration float64
+	Markers      int
+	Series       string
 }
 
-// SessionInfo combines session and file info for listing
+// SessionInfo combines session and file info for listing. Values that
+// were not recorded are left at their zero value.
 type SessionInfo struct {
-	Filename    string
-	SessionDate string
-	Dimensions  string
-	Shell       string
-	ContentSize int
-	ProcessedAt string
+	ID           string // stable session ID, see SessionID
+	Filename     string
+	Filepath     string
+	Time         time.Time // when the session was recorded
+	Timezone     string    // zone the session was recorded in, if known
+	Width        int
+	Height       int
+	Shell        string
+	ContentSize  int
+	ProcessedAt  time.Time
+	Duration     float64
+	IdleDuration float64
+	Markers      int
+	Series       string
+	Preview      string // first command or line of output
 }
 
 // SearchResult represents a search match with context
 type SearchResult struct {
+	ID          string // stable session ID, see SessionID
 	Filename    string
-	SessionDate string
+	Filepath    string
+	Time        time.Time // when the session was recorded, zero if unknown
+	Timezone    string    // zone the session was recorded in, if known
 	LineNumber  int
+	ByteOffset  int // offset of the matched line in the stored content
 	MatchedText string
 	Context     string
+	Score       int // number of matching lines in the session
+}
+
+// Annotation represents a note attached to a moment in a recording
+type Annotation struct {
+	ID        int64
+	Filename  string
+	At        float64
+	Note      string
+	CreatedAt time.Time
 }
 
 // Stats represents database statistics
@@ -65,14 +96,22 @@ type Stats struct {
 	ProcessedFiles int
 	Sessions       int
 	TotalChars     int64
+	// FirstSession and LastSession bound when the sessions were recorded,
+	// and are zero if no session has a timestamp
+	FirstSession time.Time
+	LastSession  time.Time
 }
 
 // Open opens or creates a SQLite database
 func Open(dbPath string) (*DB, error) {
-	conn, err := sql.Open("sqlite3", dbPath)
+	dsn, err := fileURI(dbPath, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	conn, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", check(err))
+	}
 
 	db := &DB{conn: conn}
 	if err := db.init(); err != nil {
@@ -83,11 +122,40 @@ func Open(dbPath string) (*DB, error) {
 	return db, nil
 }
 
+// OpenReadOnly opens an existing database without creating it or
+// migrating its schema, for commands that must not modify it
+func OpenReadOnly(dbPath string) (*DB, error) {
+	dsn, err := fileURI(dbPath, "mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	conn, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", check(err))
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open database: %w", check(err))
+	}
+	return &DB{conn: conn}, nil
+}
+
+// fileURI returns a SQLite URI for the database at path with the given
+// query parameters. The path is made absolute and escaped, since ?, # and
+// % mean something in a URI.
+func fileURI(path, query string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs), RawQuery: query}).String(), nil
+}
+
 // init creates the database schema
 func (db *DB) init() error {
 	// Enable foreign keys
 	if _, err := db.conn.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		return fmt.Errorf("failed to enable foreign keys: %w", err)
+		return fmt.Errorf("failed to enable foreign keys: %w", check(err))
 	}
 
 	// Create processed_files table
@@ -101,7 +169,7 @@ func (db *DB) init() error {
 		)
 	`)
 	if err != nil {
-		return fmt.Errorf("failed to create processed_files table: %w", err)
+		return fmt.Errorf("failed to create processed_files table: %w", check(err))
 	}
 
 	// Create sessions table
@@ -120,28 +188,99 @@ func (db *DB) init() error {
 		)
 	`)
 	if err != nil {
-		return fmt.Errorf("failed to create sessions table: %w", err)
+		return fmt.Errorf("failed to create sessions table: %w", check(err))
+	}
+
+	// Add columns introduced after the initial schema
+	for _, col := range []struct{ name, def string }{
+		{"duration", "REAL"},
+		{"idle_duration", "REAL"},
+		{"markers", "INTEGER"},
+		{"series", "TEXT"},
+		{"preview", "TEXT"},
+		{"timezone", "TEXT"},
+		{"recording", "BLOB"},
+	} {
+		if err := db.addColumnIfMissing("sessions", col.name, col.def); err != nil {
+			return err
+		}
+	}
+
+	// Create annotations table (keyed by filename so notes survive reprocessing)
+	_, err = db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS annotations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			filename TEXT NOT NULL,
+			at REAL NOT NULL,
+			note TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create annotations table: %w", check(err))
 	}
 
 	// Create indexes
 	_, err = db.conn.Exec(`
 		CREATE INDEX IF NOT EXISTS idx_processed_files_filename ON processed_files(filename);
 		CREATE INDEX IF NOT EXISTS idx_sessions_file_id ON sessions(file_id);
+		CREATE INDEX IF NOT EXISTS idx_annotations_filename ON annotations(filename);
 	`)
 	if err != nil {
-		return fmt.Errorf("failed to create indexes: %w", err)
+		return fmt.Errorf("failed to create indexes: %w", check(err))
 	}
 
 	return nil
 }
 
+// addColumnIfMissing adds a column to an existing table created by an older schema
+func (db *DB) addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s table: %w", table, check(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("failed to scan row: %w", check(err))
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect %s table: %w", table, check(err))
+	}
+	rows.Close()
+
+	_, err = db.conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add %s column: %w", column, check(err))
+	}
+	return nil
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
-// IsFileProcessed checks if a file has already been processed (and unchanged)
-func (db *DB) IsFileProcessed(filepath string) (bool, error) {
+// FileState describes a file relative to what the database has stored
+type FileState int
+
+const (
+	FileNew       FileState = iota // never processed
+	FileUnchanged                  // processed, and the hash still matches
+	FileChanged                    // processed, but the file has changed since
+)
+
+// FileStatus reports whether a file is new, unchanged, or changed since it
+// was last processed
+func (db *DB) FileStatus(filepath string) (FileState, error) {
 	filename := getFilename(filepath)
 
 	var storedHash string
@@ -151,23 +290,34 @@ func (db *DB) IsFileProcessed(filepath string) (bool, error) {
 	).Scan(&storedHash)
 
 	if err == sql.ErrNoRows {
-		return false, nil
+		return FileNew, nil
 	}
 	if err != nil {
-		return false, fmt.Errorf("failed to query processed files: %w", err)
+		return FileNew, fmt.Errorf("failed to query processed files: %w", check(err))
 	}
 
 	// Check if file has changed
 	currentHash, err := fileHash(filepath)
 	if err != nil {
-		return false, err
+		return FileNew, err
 	}
 
-	return storedHash == currentHash, nil
+	if storedHash != currentHash {
+		return FileChanged, nil
+	}
+	return FileUnchanged, nil
+}
+
+// IsFileProcessed checks if a file has already been processed (and unchanged)
+func (db *DB) IsFileProcessed(filepath string) (bool, error) {
+	state, err := db.FileStatus(filepath)
+	return state == FileUnchanged, err
 }
 
-// InsertFile inserts or updates a processed file and its session
-func (db *DB) InsertFile(filepath string, header Header, content string) error {
+// InsertFile inserts or updates a processed file and its session.
+// recording is the recording itself, stored so it can be played without
+// the file; it may be nil.
+func (db *DB) InsertFile(filepath string, header Header, content string, recording []byte) error {
 	filename := getFilename(filepath)
 	hash, err := fileHash(filepath)
 	if err != nil {
@@ -176,7 +326,7 @@ func (db *DB) InsertFile(filepath string, header Header, content string) error {
 
 	tx, err := db.conn.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", check(err))
 	}
 	defer tx.Rollback()
 
@@ -186,7 +336,7 @@ func (db *DB) InsertFile(filepath string, header Header, content string) error {
 	if err == nil {
 		_, err = tx.Exec("DELETE FROM processed_files WHERE id = ?", existingID)
 		if err != nil {
-			return fmt.Errorf("failed to delete existing record: %w", err)
+			return fmt.Errorf("failed to delete existing record: %w", check(err))
 		}
 	}
 
@@ -196,57 +346,162 @@ func (db *DB) InsertFile(filepath string, header Header, content string) error {
 		filename, filepath, hash,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to insert processed file: %w", err)
+		return fmt.Errorf("failed to insert processed file: %w", check(err))
 	}
 
 	fileID, err := result.LastInsertId()
 	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
+		return fmt.Errorf("failed to get last insert id: %w", check(err))
 	}
 
 	// Insert session
 	_, err = tx.Exec(`
-		INSERT INTO sessions (file_id, version, width, height, timestamp, shell, term, content)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, fileID, header.Version, header.Width, header.Height, header.Timestamp, header.Shell, header.Term, content)
+		INSERT INTO sessions (file_id, version, width, height, timestamp, shell, term, content,
+			duration, idle_duration, markers, series, preview, timezone, recording)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, fileID, header.Version, header.Width, header.Height, header.Timestamp, header.Shell, header.Term, content,
+		header.Duration, header.IdleDuration, header.Markers, header.Series, header.Preview, header.Timezone, recording)
 	if err != nil {
-		return fmt.Errorf("failed to insert session: %w", err)
+		return fmt.Errorf("failed to insert session: %w", check(err))
 	}
 
 	return tx.Commit()
 }
 
-// Search searches for a term in the database and returns matches with context
-func (db *DB) Search(term string, contextLines, limit int) ([]SearchResult, error) {
+// SearchOrder selects how search results are ordered
+type SearchOrder string
+
+const (
+	OrderFilename  SearchOrder = "filename"  // by file name, then line
+	OrderNewest    SearchOrder = "newest"    // most recent sessions first
+	OrderRelevance SearchOrder = "relevance" // sessions with the most matches first
+)
+
+// SearchOptions configures Search
+type SearchOptions struct {
+	ContextLines int
+	Limit        int
+	Order        SearchOrder
+	Match        MatchMode // MatchSubstring if empty
+}
+
+// matchingSession is a session whose content matched a search
+type matchingSession struct {
+	timestamp sql.NullInt64
+	timezone  sql.NullString
+	content   string
+	filename  string
+	path      string
+	matches   int
+}
+
+// querySessions returns the sessions whose content matches term, with a
+// case-insensitive count of matching lines
+func (db *DB) querySessions(term string, mode MatchMode) ([]matchingSession, error) {
+	where, args := contentFilter(term, mode)
 	rows, err := db.conn.Query(`
-		SELECT s.id, s.timestamp, s.content, p.filename
+		SELECT s.timestamp, s.timezone, s.content, p.filename, p.filepath
 		FROM sessions s
 		JOIN processed_files p ON s.file_id = p.id
-		WHERE s.content LIKE ?
+		WHERE `+where+`
 		ORDER BY p.filename
-	`, "%"+term+"%")
+	`, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query sessions: %w", err)
+		return nil, fmt.Errorf("failed to query sessions: %w", check(err))
 	}
 	defer rows.Close()
 
-	var results []SearchResult
-	termLower := strings.ToLower(term)
-
+	matches := lineMatcher(term, mode)
+	var sessions []matchingSession
 	for rows.Next() {
-		var sessionID int64
-		var timestamp sql.NullInt64
-		var content, filename string
+		var m matchingSession
+		if err := rows.Scan(&m.timestamp, &m.timezone, &m.content, &m.filename, &m.path); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", check(err))
+		}
+		for _, line := range strings.Split(m.content, "\n") {
+			if matches(line) {
+				m.matches++
+			}
+		}
+		if m.matches > 0 {
+			sessions = append(sessions, m)
+		}
+	}
 
-		if err := rows.Scan(&sessionID, &timestamp, &content, &filename); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+	return sessions, rows.Err()
+}
+
+// contentFilter returns a WHERE condition, and its arguments, that
+// narrows sessions down to those that may match term, before each line is
+// checked. LIKE only ignores case for ASCII, so other text isn't filtered.
+func contentFilter(term string, mode MatchMode) (string, []interface{}) {
+	parts := []string{term}
+	if mode == MatchToken {
+		// Every token of the term appears somewhere in a matching session
+		parts = Tokenize(term)
+	}
+	conditions := []string{"1"}
+	var args []interface{}
+	for _, part := range parts {
+		if !isASCII(part) {
+			continue
 		}
+		conditions = append(conditions, `s.content LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(part)+"%")
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// escapeLike escapes the LIKE wildcards in s, with backslash as the escape
+// character
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// isASCII reports whether s is entirely ASCII
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Search searches for a term in the database and returns matches with context
+func (db *DB) Search(term string, opts SearchOptions) ([]SearchResult, error) {
+	sessions, err := db.querySessions(term, opts.Match)
+	if err != nil {
+		return nil, err
+	}
 
-		lines := strings.Split(content, "\n")
+	switch opts.Order {
+	case OrderNewest:
+		sort.SliceStable(sessions, func(i, j int) bool {
+			return sessions[i].timestamp.Int64 > sessions[j].timestamp.Int64
+		})
+	case OrderRelevance:
+		sort.SliceStable(sessions, func(i, j int) bool {
+			if sessions[i].matches != sessions[j].matches {
+				return sessions[i].matches > sessions[j].matches
+			}
+			return sessions[i].timestamp.Int64 > sessions[j].timestamp.Int64
+		})
+	}
 
+	var results []SearchResult
+	matches := lineMatcher(term, opts.Match)
+	contextLines := opts.ContextLines
+
+	for _, session := range sessions {
+		lines := strings.Split(session.content, "\n")
+
+		offset := 0
 		for lineNum, line := range lines {
-			if strings.Contains(strings.ToLower(line), termLower) {
-				if len(results) >= limit {
+			lineStart := offset
+			offset += len(line) + 1
+			if matches(line) {
+				if len(results) >= opts.Limit {
 					break
 				}
 
@@ -271,22 +526,22 @@ func (db *DB) Search(term string, contextLines, limit int) ([]SearchResult, erro
 					}
 				}
 
-				sessionDate := "Unknown"
-				if timestamp.Valid {
-					sessionDate = time.Unix(timestamp.Int64, 0).Format("2006-01-02 15:04:05")
-				}
-
 				results = append(results, SearchResult{
-					Filename:    filename,
-					SessionDate: sessionDate,
+					ID:          SessionID(session.filename),
+					Filename:    session.filename,
+					Filepath:    session.path,
+					Time:        unixTime(session.timestamp),
+					Timezone:    session.timezone.String,
 					LineNumber:  lineNum + 1,
+					ByteOffset:  lineStart,
 					MatchedText: strings.TrimSpace(line),
 					Context:     strings.Join(snippetLines, "\n"),
+					Score:       session.matches,
 				})
 			}
 		}
 
-		if len(results) >= limit {
+		if len(results) >= opts.Limit {
 			break
 		}
 	}
@@ -294,59 +549,156 @@ func (db *DB) Search(term string, contextLines, limit int) ([]SearchResult, erro
 	return results, nil
 }
 
+// CountMatches returns the number of matching lines and sessions for term
+func (db *DB) CountMatches(term string, mode MatchMode) (matches int, sessions int, err error) {
+	found, err := db.querySessions(term, mode)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, s := range found {
+		matches += s.matches
+	}
+	return matches, len(found), nil
+}
+
+// MatchingFiles returns the file paths of sessions containing term
+func (db *DB) MatchingFiles(term string) ([]string, error) {
+	found, err := db.querySessions(term, MatchSubstring)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, s := range found {
+		paths = append(paths, s.path)
+	}
+	return paths, nil
+}
+
 // ListSessions returns all processed sessions
 func (db *DB) ListSessions() ([]SessionInfo, error) {
+	return db.listSessions("", "ORDER BY p.filename")
+}
+
+// ListSeries returns the sessions in a series in recording order
+func (db *DB) ListSeries(series string) ([]SessionInfo, error) {
+	return db.listSessions("WHERE s.series = ?", "ORDER BY s.timestamp, p.filename", series)
+}
+
+// SeriesFiles returns the file paths of a series in recording order
+func (db *DB) SeriesFiles(series string) ([]string, error) {
+	return db.filePaths("WHERE s.series = ?", "ORDER BY s.timestamp, p.filename", series)
+}
+
+// Files returns the file paths of all processed sessions
+func (db *DB) Files() ([]string, error) {
+	return db.filePaths("", "ORDER BY p.filename")
+}
+
+func (db *DB) filePaths(where, orderBy string, args ...interface{}) ([]string, error) {
 	rows, err := db.conn.Query(`
-		SELECT p.filename, p.processed_at, s.timestamp, s.width, s.height, s.shell,
-			   LENGTH(s.content) as content_size
+		SELECT p.filepath
 		FROM processed_files p
 		JOIN sessions s ON s.file_id = p.id
-		ORDER BY p.filename
-	`)
+		`+where+`
+		`+orderBy, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files: %w", check(err))
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", check(err))
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func (db *DB) listSessions(where, orderBy string, args ...interface{}) ([]SessionInfo, error) {
+	rows, err := db.conn.Query(`
+		SELECT p.filename, p.filepath, p.processed_at, s.timestamp, s.width, s.height, s.shell,
+			   LENGTH(s.content) as content_size, s.duration, s.idle_duration, s.markers, s.series, s.preview, s.timezone
+		FROM processed_files p
+		JOIN sessions s ON s.file_id = p.id
+		`+where+`
+		`+orderBy, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query sessions: %w", err)
+		return nil, fmt.Errorf("failed to query sessions: %w", check(err))
 	}
 	defer rows.Close()
 
 	var results []SessionInfo
 
 	for rows.Next() {
-		var filename, processedAt string
+		var info SessionInfo
 		var timestamp sql.NullInt64
 		var width, height sql.NullInt64
 		var shell sql.NullString
-		var contentSize int
+		var duration, idleDuration sql.NullFloat64
+		var markers sql.NullInt64
+		var series, preview, timezone sql.NullString
 
-		if err := rows.Scan(&filename, &processedAt, &timestamp, &width, &height, &shell, &contentSize); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+		if err := rows.Scan(&info.Filename, &info.Filepath, &info.ProcessedAt, &timestamp, &width, &height, &shell,
+			&info.ContentSize, &duration, &idleDuration, &markers, &series, &preview, &timezone); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", check(err))
 		}
 
-		sessionDate := "Unknown"
-		if timestamp.Valid {
-			sessionDate = time.Unix(timestamp.Int64, 0).Format("2006-01-02 15:04:05")
-		}
+		info.ID = SessionID(info.Filename)
+		info.Time = unixTime(timestamp)
+		info.Width = int(width.Int64)
+		info.Height = int(height.Int64)
+		info.Shell = shell.String
+		info.Duration = duration.Float64
+		info.IdleDuration = idleDuration.Float64
+		info.Markers = int(markers.Int64)
+		info.Series = series.String
+		info.Preview = preview.String
+		info.Timezone = timezone.String
+		results = append(results, info)
+	}
 
-		dimensions := "Unknown"
-		if width.Valid && height.Valid {
-			dimensions = fmt.Sprintf("%dx%d", width.Int64, height.Int64)
-		}
+	return results, rows.Err()
+}
 
-		shellStr := "Unknown"
-		if shell.Valid && shell.String != "" {
-			shellStr = shell.String
+// LookupSession finds a session by its stable ID or file name. It returns
+// ErrNotFound if there is no such session.
+func (db *DB) LookupSession(ref string) (*SessionInfo, error) {
+	sessions, err := db.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		if s.ID == ref || s.Filename == ref || s.Filename == getFilename(ref) {
+			return &s, nil
 		}
-
-		results = append(results, SessionInfo{
-			Filename:    filename,
-			SessionDate: sessionDate,
-			Dimensions:  dimensions,
-			Shell:       shellStr,
-			ContentSize: contentSize,
-			ProcessedAt: processedAt,
-		})
 	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+}
 
-	return results, nil
+// SessionRecording returns the recording stored for a processed file. It
+// returns ErrNotFound if the file isn't in the database, and an error if
+// it was processed before recordings were stored.
+func (db *DB) SessionRecording(filename string) ([]byte, error) {
+	var recording []byte
+	err := db.conn.QueryRow(`
+		SELECT s.recording
+		FROM processed_files p
+		JOIN sessions s ON s.file_id = p.id
+		WHERE p.filename = ?
+	`, getFilename(filename)).Scan(&recording)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session: %w", check(err))
+	}
+	if len(recording) == 0 {
+		return nil, fmt.Errorf("no recording stored for %s (run 'process --force --store-recordings' to store it)", filename)
+	}
+	return recording, nil
 }
 
 // GetStats returns database statistics
@@ -355,38 +707,106 @@ func (db *DB) GetStats() (*Stats, error) {
 
 	err := db.conn.QueryRow("SELECT COUNT(*) FROM processed_files").Scan(&stats.ProcessedFiles)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count processed files: %w", err)
+		return nil, fmt.Errorf("failed to count processed files: %w", check(err))
 	}
 
 	err = db.conn.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&stats.Sessions)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count sessions: %w", err)
+		return nil, fmt.Errorf("failed to count sessions: %w", check(err))
 	}
 
 	var totalChars sql.NullInt64
 	err = db.conn.QueryRow("SELECT SUM(LENGTH(content)) FROM sessions").Scan(&totalChars)
 	if err != nil {
-		return nil, fmt.Errorf("failed to sum content length: %w", err)
+		return nil, fmt.Errorf("failed to sum content length: %w", check(err))
 	}
 	if totalChars.Valid {
 		stats.TotalChars = totalChars.Int64
 	}
 
+	var first, last sql.NullInt64
+	err = db.conn.QueryRow("SELECT MIN(timestamp), MAX(timestamp) FROM sessions WHERE timestamp > 0").Scan(&first, &last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session times: %w", check(err))
+	}
+	stats.FirstSession = unixTime(first)
+	stats.LastSession = unixTime(last)
+
 	return &stats, nil
 }
 
+// AddAnnotation attaches a note to the recording at the given time offset
+func (db *DB) AddAnnotation(filepath string, at float64, note string) error {
+	_, err := db.conn.Exec(
+		"INSERT INTO annotations (filename, at, note) VALUES (?, ?, ?)",
+		getFilename(filepath), at, note,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert annotation: %w", check(err))
+	}
+	return nil
+}
+
+// GetAnnotations returns the notes for a recording ordered by time
+func (db *DB) GetAnnotations(filepath string) ([]Annotation, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, filename, at, note, created_at
+		FROM annotations
+		WHERE filename = ?
+		ORDER BY at, id
+	`, getFilename(filepath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query annotations: %w", check(err))
+	}
+	defer rows.Close()
+
+	var results []Annotation
+	for rows.Next() {
+		var a Annotation
+		if err := rows.Scan(&a.ID, &a.Filename, &a.At, &a.Note, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", check(err))
+		}
+		results = append(results, a)
+	}
+
+	return results, nil
+}
+
 // Header contains asciinema header metadata for database storage
 type Header struct {
-	Version   int
-	Width     int
-	Height    int
-	Timestamp int64
-	Shell     string
-	Term      string
+	Version      int
+	Width        int
+	Height       int
+	Timestamp    int64
+	Shell        string
+	Term         string
+	Duration     float64 // time of the last event
+	IdleDuration float64 // duration with idle gaps capped at idle_time_limit
+	Markers      int
+	Series       string
+	Preview      string // first command or line of output, for listings
+	Timezone     string // zone of Timestamp, an IANA name or UTC offset
 }
 
 // Helper functions
 
+// SessionID returns a stable identifier for a session. It is derived from
+// the file name, which is unique in the database, so it survives
+// reprocessing and is the same in every database holding the file.
+func SessionID(filename string) string {
+	sum := md5.Sum([]byte(filename))
+	return hex.EncodeToString(sum[:6])
+}
+
+// unixTime converts a stored Unix timestamp, returning the zero time when
+// it is missing
+func unixTime(ts sql.NullInt64) time.Time {
+	if !ts.Valid || ts.Int64 == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts.Int64, 0)
+}
+
 func getFilename(path string) string {
 	return filepath.Base(path)
 }
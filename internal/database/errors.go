@@ -0,0 +1,26 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrNotFound is returned when a requested session does not exist
+	ErrNotFound = errors.New("session not found")
+	// ErrLocked is returned when another process holds a lock on the
+	// database; the operation can be retried
+	ErrLocked = errors.New("database is locked")
+)
+
+// check converts SQLite busy and locked errors into ErrLocked so callers
+// can test for them with errors.Is. Other errors are returned unchanged.
+func check(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked) {
+		return fmt.Errorf("%w: %v", ErrLocked, err)
+	}
+	return err
+}
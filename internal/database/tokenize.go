@@ -0,0 +1,85 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MatchMode selects how a search term is matched against session lines
+type MatchMode string
+
+const (
+	// MatchSubstring matches the term anywhere in a line, including inside
+	// longer words
+	MatchSubstring MatchMode = "substring"
+	// MatchToken matches whole tokens as split by Tokenize, so "--force"
+	// does not match "--force-with-lease" and "10.0.0.1" does not match
+	// "10.0.0.12"
+	MatchToken MatchMode = "token"
+)
+
+// tokenTrailing is punctuation dropped from the end of a token, since in
+// terminal output it usually ends a sentence or label rather than a path
+const tokenTrailing = ".:"
+
+// Tokenize splits terminal text into code-aware tokens. Whitespace,
+// quotes, brackets and shell operators separate tokens, but the
+// punctuation found inside paths, flags, addresses and identifiers
+// (- . / : _ = @ ~ + %) does not, so "/usr/bin/env", "--force",
+// "192.168.0.1:22" and UUIDs each stay a single token. Tokens are
+// lowercased.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), isTokenSeparator)
+	tokens := fields[:0]
+	for _, field := range fields {
+		if trimmed := strings.TrimRight(field, tokenTrailing); trimmed != "" {
+			field = trimmed
+		}
+		tokens = append(tokens, field)
+	}
+	return tokens
+}
+
+// isTokenSeparator reports whether r separates tokens
+func isTokenSeparator(r rune) bool {
+	if unicode.IsSpace(r) || unicode.IsControl(r) {
+		return true
+	}
+	return strings.ContainsRune("\"'`()[]{}<>,;|&!?*$#^\\", r)
+}
+
+// lineMatcher returns a function reporting whether a line matches term
+// under mode
+func lineMatcher(term string, mode MatchMode) func(line string) bool {
+	if mode == MatchToken {
+		want := Tokenize(term)
+		return func(line string) bool {
+			return containsTokens(Tokenize(line), want)
+		}
+	}
+	termLower := strings.ToLower(term)
+	return func(line string) bool {
+		return strings.Contains(strings.ToLower(line), termLower)
+	}
+}
+
+// containsTokens reports whether want appears as a consecutive run in
+// tokens
+func containsTokens(tokens, want []string) bool {
+	if len(want) == 0 {
+		return false
+	}
+	for i := 0; i+len(want) <= len(tokens); i++ {
+		match := true
+		for j, w := range want {
+			if tokens[i+j] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
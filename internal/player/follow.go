@@ -0,0 +1,69 @@
+package player
+
+import (
+	"os"
+	"time"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+)
+
+// followPoll is how often a followed recording is checked for new events
+const followPoll = 100 * time.Millisecond
+
+// follow catches up with the events already read from a recording being
+// written, then polls the reader for new events and writes them as they
+// land. It stops at an exit event, or once the recording has been
+// finished: the recorder replaces the file when it rewrites the header,
+// so the path no longer names the file being read.
+func (p *Player) follow(filename string, info os.FileInfo, reader *asciicast.Reader, events []asciicast.Event) error {
+	if err := reader.Err(); err != nil {
+		return err
+	}
+	var end float64
+	if len(events) > 0 {
+		end = events[len(events)-1].Time
+	}
+	p.snapshots = nil
+	_, p.position = p.seek(reader.Header, events, end)
+
+	finished := false
+	for {
+		dirty := false
+		for reader.Next() {
+			event := reader.Event()
+			p.position = event.Time
+			if p.screen != nil {
+				dirty = feed(p.screen, event) || dirty
+			} else if event.Type == asciicast.EventTypeOutput {
+				p.writeOutput(event.Data)
+			}
+			if event.Type == asciicast.EventTypeExit {
+				finished = true
+				break
+			}
+		}
+		if err := reader.Err(); err != nil {
+			return err
+		}
+		if dirty {
+			p.drawScreen(p.screen)
+		}
+		if finished {
+			return nil
+		}
+		p.duration = p.position
+		p.drawStatus(false)
+
+		switch p.waitUntil(time.Now().Add(followPoll)) {
+		case actionQuit:
+			return ErrStopped
+		case actionStatus:
+			p.toggleStatus()
+		}
+
+		// Read what was written before the file was replaced, then stop
+		if current, err := os.Stat(filename); err != nil || !os.SameFile(info, current) {
+			finished = true
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package player
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/ober/goasciinema/internal/vt"
+)
+
+// Stats describes a recording played headless
+type Stats struct {
+	Events   int     // number of events read
+	Duration float64 // time of the last event
+	// ScreenHash is the hex SHA-256 of the final screen's text and
+	// attributes, to compare against a known good playback
+	ScreenHash string
+}
+
+// Headless plays a recording into a terminal emulator as fast as
+// possible, without waiting or writing anything, and returns statistics
+// about it. Any event that can't be read fails the playback, which makes
+// it a check that a recording isn't corrupted.
+func Headless(filename string) (Stats, error) {
+	reader, err := asciicast.Open(filename)
+	if err != nil {
+		return Stats{}, &Error{Op: "open", File: filename, Err: err}
+	}
+	defer reader.Close()
+
+	var stats Stats
+	term := vt.New(reader.Header.Width, reader.Header.Height)
+	for reader.Next() {
+		event := reader.Event()
+		feed(term, event)
+		stats.Events++
+		stats.Duration = event.Time
+	}
+	if err := reader.Err(); err != nil {
+		return stats, &Error{Op: "read", File: filename, Err: err}
+	}
+
+	stats.ScreenHash = screenHash(term)
+	return stats, nil
+}
+
+// screenHash hashes the visible screen with its colors and attributes,
+// and the cursor position
+func screenHash(term *vt.Terminal) string {
+	width, _ := term.Size()
+	hash := sha256.New()
+	for _, line := range term.Lines() {
+		fmt.Fprintf(hash, "%s\n", line.ANSI(width))
+	}
+	x, y, visible := term.Cursor()
+	fmt.Fprintf(hash, "%d,%d,%t\n", x, y, visible)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,203 @@
+package player
+
+import (
+	"os"
+	"time"
+
+	ttypkg "github.com/ober/goasciinema/internal/tty"
+)
+
+// action is what a key press asks the player to do
+type action int
+
+const (
+	actionNone action = iota
+	actionQuit
+	actionSeekForward
+	actionSeekBackward
+	actionPause
+	actionNextMarker
+	actionStatus
+	actionStepBack
+	actionFaster
+	actionSlower
+)
+
+// seekStep is how far the seek keys jump, in recording time
+const seekStep = 5.0
+
+// keyAction maps a chunk of keyboard input to a player action. Arrow keys
+// are accepted in both normal and application cursor mode.
+func keyAction(key string) action {
+	switch key {
+	case "\x03": // Ctrl+C, which raw mode delivers as input
+		return actionQuit
+	case "\x1b[C", "\x1bOC", ";":
+		return actionSeekForward
+	case "\x1b[D", "\x1bOD", ",":
+		return actionSeekBackward
+	case " ":
+		return actionPause
+	case "m":
+		return actionNextMarker
+	case "s":
+		return actionStatus
+	case "b":
+		return actionStepBack
+	case "+", "=":
+		return actionFaster
+	case "-", "_":
+		return actionSlower
+	}
+	return actionNone
+}
+
+// startKeys puts stdin in raw mode and delivers key presses on p.keys. It
+// does nothing when stdin is not a terminal. The returned function
+// restores the terminal.
+func (p *Player) startKeys() func() {
+	fd := ttypkg.GetStdinFd()
+	if !ttypkg.IsTerminal(fd) {
+		return func() {}
+	}
+	restore, err := ttypkg.RawMode(fd)
+	if err != nil {
+		return func() {}
+	}
+
+	keys := make(chan string, 16)
+	go func() {
+		buf := make([]byte, 32)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			keys <- string(buf[:n])
+		}
+	}()
+	p.keys = keys
+
+	return func() {
+		p.keys = nil
+		restore()
+	}
+}
+
+// wait waits for the deadline and returns the first action for the
+// playback loop. Pausing, speed changes and the status line are handled
+// here; while paused the deadline is held back, and other actions are
+// returned without resuming.
+func (p *Player) wait(deadline time.Time) action {
+	for {
+		var a action
+		if p.paused && p.keys != nil {
+			remaining := time.Until(deadline)
+			if p.notice != "" {
+				a = p.waitUntil(p.noticeUntil)
+			} else {
+				a = p.waitKey()
+			}
+			deadline = time.Now().Add(remaining)
+		} else {
+			p.paused = false
+			until := deadline
+			if p.notice != "" && p.noticeUntil.Before(until) {
+				until = p.noticeUntil
+			}
+			a = p.waitUntil(until)
+		}
+
+		switch a {
+		case actionPause:
+			p.paused = !p.paused
+			p.drawStatus(true)
+		case actionStatus:
+			p.toggleStatus()
+		case actionFaster, actionSlower:
+			// The rest of the wait is in playback time, so it scales too
+			speed := p.options.Speed
+			p.changeSpeed(a == actionFaster)
+			remaining := time.Duration(float64(time.Until(deadline)) * speed / p.options.Speed)
+			deadline = time.Now().Add(remaining)
+		case actionNone:
+			if p.notice != "" && !time.Now().Before(p.noticeUntil) {
+				p.clearNotice()
+				if p.paused || time.Now().Before(deadline) {
+					continue
+				}
+			}
+			return a
+		default:
+			return a
+		}
+	}
+}
+
+// waitStep waits for the key press that writes the next piece of output
+// with manual pacing. Any key without another action advances, including
+// space.
+func (p *Player) waitStep() action {
+	for {
+		select {
+		case key := <-p.keys:
+			switch a := keyAction(key); a {
+			case actionNone, actionPause:
+				return actionNone
+			case actionStatus:
+				p.toggleStatus()
+			default:
+				return a
+			}
+		case <-p.done:
+			return actionQuit
+		}
+	}
+}
+
+// waitKey waits for a key press with an action
+func (p *Player) waitKey() action {
+	for {
+		select {
+		case key := <-p.keys:
+			if a := keyAction(key); a != actionNone {
+				return a
+			}
+		case <-p.done:
+			return actionQuit
+		}
+	}
+}
+
+// waitUntil waits for the deadline or the next key press, whichever comes
+// first, and returns the key's action. Keys already pressed are returned
+// even when the deadline has passed. Playback is stopped when Play's
+// context is done.
+func (p *Player) waitUntil(deadline time.Time) action {
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		select {
+		case key := <-p.keys:
+			return keyAction(key)
+		case <-p.done:
+			return actionQuit
+		default:
+			return actionNone
+		}
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case key := <-p.keys:
+			if a := keyAction(key); a != actionNone {
+				return a
+			}
+		case <-p.done:
+			return actionQuit
+		case <-timer.C:
+			return actionNone
+		}
+	}
+}
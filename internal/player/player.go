@@ -1,15 +1,21 @@
 package player
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ober/goasciinema/internal/asciicast"
 	"github.com/ober/goasciinema/internal/sanitize"
 	ttypkg "github.com/ober/goasciinema/internal/tty"
+	"github.com/ober/goasciinema/internal/vt"
 )
 
 // Options configures the player
@@ -18,13 +24,126 @@ type Options struct {
 	IdleTimeLimit float64
 	Loop          bool
 	MaxWait       float64
+	ThrottleBytes int // max bytes written per frame (0 = unlimited)
+	// Render feeds output through a virtual terminal and redraws its screen
+	// instead of writing the recorded escape sequences directly
+	Render bool
+	// PauseOnMarkers pauses playback at each marker, when stdin is a
+	// terminal to resume from
+	PauseOnMarkers bool
+	// From and To limit playback to part of each recording, in seconds.
+	// Playback skips straight to From; a To of 0 plays to the end.
+	From float64
+	To   float64
+	// AltScreen plays on the terminal's alternate screen, leaving the
+	// user's scrollback, cursor and window size as they were afterwards.
+	// It has no effect when stdout is not a terminal.
+	AltScreen bool
+	// Pace selects what drives playback, one of the Pace constants. With
+	// manual and line pacing, output is only written when a key is
+	// pressed; they fall back to the recorded timing when stdin is not a
+	// terminal.
+	Pace string
+	// Follow plays a recording that is still being written: what is
+	// already there is drawn at once, then new events are written as they
+	// land, until the recording finishes
+	Follow bool
+	// Separator starts each recording with a marker named after its file,
+	// so --pause-on-markers and the next-marker key step from one
+	// recording to the next
+	Separator bool
+	// Status shows a status line with the playback position, speed and
+	// pause state on the terminal's bottom row
+	Status bool
+	// Subtitles shows the label of the last marker passed on the top or
+	// bottom row, one of the Subtitles constants; none if empty
+	Subtitles string
+	// Fit plays recordings larger than the terminal through the terminal
+	// emulator, showing the part of the screen around the cursor. Without
+	// it, such recordings are played as they are, with a warning.
+	Fit bool
+	// Output receives the played output; stdout if nil
+	Output io.Writer
 }
 
+// Pacing modes for Options.Pace
+const (
+	PaceTime   = "time"   // the recorded timing
+	PaceManual = "manual" // one output event per key press
+	PaceLine   = "line"   // one line of output per key press
+)
+
+// Rows for Options.Subtitles
+const (
+	SubtitlesTop    = "top"
+	SubtitlesBottom = "bottom"
+)
+
+// frameInterval is the pacing used when output is throttled
+const frameInterval = time.Second / 60
+
 // Player handles asciicast playback
 type Player struct {
 	options Options
 	paused  bool
 	step    bool
+	screen  *vt.Terminal  // emulated screen in Render mode
+	keys    <-chan string // key presses, nil when stdin is not a terminal
+
+	out      io.Writer
+	writeErr error           // the first error writing to out
+	done     <-chan struct{} // closed when Play's context is done
+
+	frameStart time.Time // start of the current ThrottleBytes frame
+	frameBytes int       // output written in it
+
+	snapshots []snapshot // screens taken while seeking, in event order
+
+	status      bool    // whether the status line is shown
+	position    float64 // playback position shown in the status line
+	duration    float64 // length of the recording being played
+	lastStatus  string
+	statusDrawn time.Time
+	notice      string    // transient text shown on the bottom row
+	noticeUntil time.Time // when the notice is erased
+
+	subtitle      string // label of the last marker passed
+	lastSubtitle  string
+	subtitleDrawn time.Time
+
+	warnings []string // printed once playback has left the screen
+}
+
+// snapshotInterval is how many events apart the screen snapshots used for
+// seeking are taken
+const snapshotInterval = 500
+
+// snapshot is the emulated screen after an event, kept so seeking doesn't
+// replay the recording from the start each time
+type snapshot struct {
+	last int // index of the last event applied
+	term *vt.Terminal
+}
+
+// ErrStopped is returned when the viewer stops playback with Ctrl+C
+var ErrStopped = errors.New("playback stopped")
+
+// Error is a failure to play a recording
+type Error struct {
+	Op   string // what failed: "open", "read" or "write"
+	File string // the recording's file, empty when playing from a reader
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.File == "" {
+		return e.Op + ": " + e.Err.Error()
+	}
+	return e.Op + " " + e.File + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
 }
 
 // New creates a new player
@@ -32,60 +151,209 @@ func New(options Options) *Player {
 	if options.Speed <= 0 {
 		options.Speed = 1.0
 	}
+	out := options.Output
+	if out == nil {
+		out = os.Stdout
+	}
 	return &Player{
 		options: options,
+		out:     out,
+		status:  options.Status,
 	}
 }
 
-// Play plays the asciicast file
-func (p *Player) Play(filename string) error {
-	reader, err := asciicast.Open(filename)
+// Play plays a recording read from src, such as a file or an HTTP
+// response body, until it ends or ctx is done. It returns ctx's error when
+// cancelled, and an *Error when the recording can't be read or the output
+// can't be written. Unlike PlayFiles it leaves the keyboard and screen
+// alone, and Follow has no effect.
+func (p *Player) Play(ctx context.Context, src io.Reader) error {
+	p.done = ctx.Done()
+	defer func() { p.done = nil }()
+
+	reader, err := asciicast.NewReader(src)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return &Error{Op: "open", Err: err}
 	}
 	defer reader.Close()
 
-	// Set terminal size if possible
-	if ttypkg.IsTerminal(ttypkg.GetStdoutFd()) {
-		fmt.Printf("\x1b[8;%d;%dt", reader.Header.Height, reader.Header.Width)
+	err = p.playReader(reader, "", nil)
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
+	return err
+}
+
+// PlayFiles plays several recordings back to back as one logical
+// recording. With Loop set, the whole sequence repeats.
+//
+// When stdin is a terminal, space pauses and resumes, the left and right
+// arrow keys (or "," and ";") seek back and forward by five seconds, "m"
+// jumps to the next marker, "s" shows or hides the status line, and
+// Ctrl+C stops playback.
+func (p *Player) PlayFiles(filenames []string) error {
+	defer func() {
+		for _, warning := range p.warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+		p.warnings = nil
+	}()
+	if p.options.AltScreen {
+		defer p.enterAltScreen()()
+	}
+	restore := p.startKeys()
+	defer restore()
+	defer func() {
+		if p.status {
+			p.clearStatus()
+		}
+	}()
 
 	for {
-		err := p.playOnce(reader)
-		if err != nil {
-			return err
+		for _, filename := range filenames {
+			if err := p.playFile(filename); err != nil {
+				if err == ErrStopped {
+					return nil
+				}
+				return err
+			}
 		}
 
 		if !p.options.Loop {
-			break
+			return nil
 		}
+	}
+}
 
-		// Reset reader for loop
-		reader.Close()
-		reader, err = asciicast.Open(filename)
-		if err != nil {
+func (p *Player) playFile(filename string) error {
+	if p.options.Follow && asciicast.IsCompressed(filename) {
+		return &Error{Op: "open", File: filename, Err: errors.New("compressed recordings can't be followed")}
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return &Error{Op: "open", File: filename, Err: err}
+	}
+	reader, err := asciicast.Open(filename)
+	if err != nil {
+		return &Error{Op: "open", File: filename, Err: err}
+	}
+	defer reader.Close()
+	reader.SetFollow(p.options.Follow)
+
+	return p.playReader(reader, filename, info)
+}
+
+// playReader plays a recording from an open reader. filename and info
+// describe the file it reads, and are empty when playing from a stream.
+func (p *Player) playReader(reader *asciicast.Reader, filename string, info os.FileInfo) error {
+	// Keep the events in memory, so playback can seek. A read error ends
+	// the recording once the events before it have been played.
+	var events []asciicast.Event
+	for reader.Next() {
+		events = append(events, reader.Event())
+	}
+	if p.options.Pace == PaceLine {
+		events = splitLines(events)
+	}
+	if p.options.Separator && filename != "" {
+		separator := asciicast.Event{Type: asciicast.EventTypeMarker, Data: filepath.Base(filename)}
+		events = append([]asciicast.Event{separator}, events...)
+	}
+	play := func() error {
+		if p.options.Follow && info != nil {
+			return p.follow(filename, info, reader, events)
+		}
+		if err := p.playOnce(reader.Header, events); err != nil {
 			return err
 		}
+		return reader.Err()
 	}
 
-	return nil
+	render := p.options.Render
+	if width, height := recordingSize(reader.Header, events); !render {
+		if cols, rows, ok := p.terminalSize(); ok && (width > cols || height > rows) {
+			if p.options.Fit {
+				render = true
+			} else {
+				p.warnings = append(p.warnings, fmt.Sprintf("%s needs a %dx%d terminal but this one is %dx%d; resize it or use --fit", displayName(filename), width, height, cols, rows))
+			}
+		}
+	}
+
+	if render {
+		p.screen = vt.New(reader.Header.Width, reader.Header.Height)
+		p.write("\x1b[H\x1b[2J")
+		defer func() {
+			p.screen = nil
+			p.write("\x1b[?25h\r\n")
+		}()
+	} else if _, ok := p.terminalFd(); ok {
+		// Set terminal size if possible
+		p.write(fmt.Sprintf("\x1b[8;%d;%dt", reader.Header.Height, reader.Header.Width))
+	}
+
+	err := play()
+	switch {
+	case err == nil || err == ErrStopped:
+		return err
+	case p.writeErr != nil:
+		err, p.writeErr = p.writeErr, nil
+		return &Error{Op: "write", File: filename, Err: err}
+	default:
+		return &Error{Op: "read", File: filename, Err: err}
+	}
+}
+
+// recordingSize returns the largest terminal size a recording uses,
+// including resizes during it
+func recordingSize(header asciicast.Header, events []asciicast.Event) (width, height int) {
+	width, height = header.Width, header.Height
+	for _, event := range events {
+		if event.Type != asciicast.EventTypeResize {
+			continue
+		}
+		if cols, rows, ok := asciicast.ParseResize(event.Data); ok {
+			width, height = max(width, cols), max(height, rows)
+		}
+	}
+	return width, height
+}
+
+// displayName names a recording in messages
+func displayName(filename string) string {
+	if filename == "" {
+		return "the recording"
+	}
+	return filename
 }
 
-func (p *Player) playOnce(reader *asciicast.Reader) error {
-	var prevTime float64
+func (p *Player) playOnce(header asciicast.Header, events []asciicast.Event) error {
+	var pos float64 // recording time of the playback position
+	var dirty bool
+	manual := p.keys != nil && (p.options.Pace == PaceManual || p.options.Pace == PaceLine)
 
-	for {
-		event, err := reader.ReadEvent()
-		if err != nil {
-			if err == io.EOF {
-				return nil
-			}
-			return err
+	p.snapshots = nil
+	p.subtitle = ""
+	p.position, p.duration = 0, header.Duration
+	if len(events) > 0 {
+		p.duration = max(p.duration, events[len(events)-1].Time)
+	}
+
+	start := 0
+	if p.options.From > 0 {
+		var last int
+		last, pos = p.seek(header, events, p.options.From)
+		start = last + 1
+	}
+
+	for i := start; i < len(events); i++ {
+		event := events[i]
+		if p.options.To > 0 && event.Time > p.options.To {
+			break
 		}
 
 		// Calculate delay
-		delay := event.Time - prevTime
-		prevTime = event.Time
+		delay := event.Time - pos
 
 		// Apply idle time limit
 		if p.options.IdleTimeLimit > 0 && delay > p.options.IdleTimeLimit {
@@ -98,45 +366,432 @@ func (p *Player) playOnce(reader *asciicast.Reader) error {
 		// Apply speed
 		delay = delay / p.options.Speed
 
-		// Wait
-		if delay > 0 {
-			time.Sleep(time.Duration(delay * float64(time.Second)))
+		// With manual pacing, wait for a key before each piece of output
+		// instead of the recorded delay
+		step := manual && event.Type == asciicast.EventTypeOutput
+		if manual {
+			delay = 0
+		}
+
+		// Wait, redrawing first so the emulated screen is current while idle
+		if (delay > 0 || step || p.paused) && dirty {
+			p.drawScreen(p.screen)
+			dirty = false
+		}
+		if delay > 0 || step || p.paused {
+			p.position = pos
+			p.drawStatus(false)
+		}
+		var a action
+		if step {
+			a = p.waitStep()
+		} else {
+			a = p.wait(time.Now().Add(time.Duration(delay * float64(time.Second))))
+		}
+		switch a {
+		case actionQuit:
+			return ErrStopped
+		case actionSeekForward:
+			i, pos = p.seek(header, events, pos+seekStep)
+			dirty = false
+			continue
+		case actionSeekBackward:
+			i, pos = p.seek(header, events, pos-seekStep)
+			dirty = false
+			continue
+		case actionStepBack:
+			i, pos = p.stepBack(header, events, i)
+			dirty = false
+			continue
+		case actionNextMarker:
+			// Without another marker, skip to the end of the recording
+			target := events[len(events)-1].Time
+			if next := nextMarker(events, i); next >= 0 {
+				target = events[next].Time
+			}
+			i, pos = p.seek(header, events, target)
+			dirty = false
+			continue
+		}
+		pos = event.Time
+
+		if event.Type == asciicast.EventTypeMarker {
+			p.subtitle = event.Data
+			p.drawSubtitle(true)
+			if p.options.PauseOnMarkers {
+				p.paused = true
+			}
+		}
+
+		if p.screen != nil {
+			dirty = feed(p.screen, event) || dirty
+			continue
 		}
 
 		// Output only stdout events
 		if event.Type == asciicast.EventTypeOutput {
-			os.Stdout.WriteString(event.Data)
+			p.writeOutput(event.Data)
 		}
+		if p.writeErr != nil {
+			return p.writeErr
+		}
+	}
+
+	if dirty {
+		p.drawScreen(p.screen)
 	}
+	return nil
 }
 
-// Cat outputs the full recording without timing, stripping ANSI escape
-// codes and terminal control characters.
-func Cat(filename string) error {
-	reader, err := asciicast.Open(filename)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+// seek moves playback to the recording time target, rebuilding the screen
+// with a terminal emulator and redrawing it. It returns the index of the
+// last event played, for the playback loop to continue after, and the new
+// position.
+func (p *Player) seek(header asciicast.Header, events []asciicast.Event, target float64) (int, float64) {
+	if len(events) > 0 {
+		target = min(target, events[len(events)-1].Time)
 	}
-	defer reader.Close()
+	target = max(target, 0)
 
-	var buf strings.Builder
-	for {
-		event, err := reader.ReadEvent()
-		if err != nil {
-			if err == io.EOF {
-				break
+	last := -1
+	for last+1 < len(events) && events[last+1].Time <= target {
+		last++
+	}
+	p.position = target
+	p.show(header, events, last)
+	p.drawStatus(true)
+	return last, target
+}
+
+// stepBack pauses playback and undoes the last output written before
+// event next, redrawing the screen as it was before it. It returns the
+// index of the last event played and the new position, like seek.
+func (p *Player) stepBack(header asciicast.Header, events []asciicast.Event, next int) (int, float64) {
+	last := next - 1
+	for last >= 0 && events[last].Type != asciicast.EventTypeOutput {
+		last--
+	}
+	if last >= 0 {
+		last--
+	}
+
+	var pos float64
+	if last >= 0 {
+		pos = events[last].Time
+	}
+	p.paused = true
+	p.position = pos
+	p.show(header, events, last)
+	p.drawStatus(true)
+	return last, pos
+}
+
+// show rebuilds the screen after events up to and including last, and
+// draws it. It starts from the closest snapshot at or before last, and
+// takes new snapshots on the way.
+func (p *Player) show(header asciicast.Header, events []asciicast.Event, last int) {
+	term := vt.New(header.Width, header.Height)
+	i := 0
+	for j := len(p.snapshots) - 1; j >= 0; j-- {
+		if p.snapshots[j].last <= last {
+			term = p.snapshots[j].term.Clone()
+			i = p.snapshots[j].last + 1
+			break
+		}
+	}
+
+	p.subtitle = ""
+	for j := last; j >= 0; j-- {
+		if events[j].Type == asciicast.EventTypeMarker {
+			p.subtitle = events[j].Data
+			break
+		}
+	}
+
+	for ; i <= last; i++ {
+		feed(term, events[i])
+		if (i+1)%snapshotInterval == 0 && (len(p.snapshots) == 0 || p.snapshots[len(p.snapshots)-1].last < i) {
+			p.snapshots = append(p.snapshots, snapshot{last: i, term: term.Clone()})
+		}
+	}
+
+	if p.screen != nil {
+		p.screen = term
+	}
+	p.drawScreen(term)
+}
+
+// splitLines splits output events after each newline, so line pacing can
+// write them one line at a time
+func splitLines(events []asciicast.Event) []asciicast.Event {
+	var split []asciicast.Event
+	for _, event := range events {
+		if event.Type != asciicast.EventTypeOutput {
+			split = append(split, event)
+			continue
+		}
+		data := event.Data
+		for data != "" {
+			n := strings.IndexByte(data, '\n') + 1
+			if n == 0 {
+				n = len(data)
 			}
-			return err
+			line := event
+			line.Data = data[:n]
+			split = append(split, line)
+			data = data[n:]
 		}
+	}
+	return split
+}
 
-		if event.Type == asciicast.EventTypeOutput {
-			buf.WriteString(event.Data)
+// nextMarker returns the index of the first marker event at or after
+// from, or -1 if there is none
+func nextMarker(events []asciicast.Event, from int) int {
+	for i := from; i < len(events); i++ {
+		if events[i].Type == asciicast.EventTypeMarker {
+			return i
+		}
+	}
+	return -1
+}
+
+// feed applies an output or resize event to an emulated screen and reports
+// whether the screen changed
+func feed(term *vt.Terminal, event asciicast.Event) bool {
+	switch event.Type {
+	case asciicast.EventTypeOutput:
+		term.Write(event.Data)
+		return true
+	case asciicast.EventTypeResize:
+		if cols, rows, ok := asciicast.ParseResize(event.Data); ok {
+			term.Resize(cols, rows)
+			return true
+		}
+	}
+	return false
+}
+
+// enterAltScreen switches the output terminal to the alternate screen,
+// saving the cursor, and returns a function that switches back and
+// restores the window size. It runs deferred, so the terminal is cleaned
+// up after a panic too.
+func (p *Player) enterAltScreen() func() {
+	if _, ok := p.terminalFd(); !ok {
+		return func() {}
+	}
+	cols, rows, ok := p.terminalSize()
+
+	p.write("\x1b[?1049h\x1b[H\x1b[2J")
+	return func() {
+		p.write("\x1b[0m\x1b[?25h\x1b[?1049l")
+		if ok {
+			p.write(fmt.Sprintf("\x1b[8;%d;%dt", rows, cols))
+		}
+	}
+}
+
+// terminalFd returns the file descriptor of the output, if it is a
+// terminal
+func (p *Player) terminalFd() (int, bool) {
+	file, ok := p.out.(*os.File)
+	if !ok || !ttypkg.IsTerminal(int(file.Fd())) {
+		return 0, false
+	}
+	return int(file.Fd()), true
+}
+
+// terminalSize returns the size of the output terminal
+func (p *Player) terminalSize() (cols, rows int, ok bool) {
+	fd, ok := p.terminalFd()
+	if !ok {
+		return 0, 0, false
+	}
+	cols, rows, err := ttypkg.GetSize(fd)
+	if err != nil || cols <= 0 || rows <= 0 {
+		return 0, 0, false
+	}
+	return cols, rows, true
+}
+
+// write writes to the output, keeping the first error for the playback
+// loop to stop on
+func (p *Player) write(s string) {
+	if _, err := io.WriteString(p.out, s); err != nil && p.writeErr == nil {
+		p.writeErr = err
+	}
+}
+
+// drawScreen redraws an emulated screen onto the real terminal, clipped
+// to the terminal's size
+func (p *Player) drawScreen(screen *vt.Terminal) {
+	width, height := screen.Size()
+	if cols, rows, ok := p.terminalSize(); ok {
+		width = min(width, cols)
+		height = min(height, rows)
+		if p.status {
+			// Keep the bottom row for the status line
+			height = min(height, rows-1)
+		}
+	}
+
+	// With Fit, a screen larger than the terminal is cropped around the
+	// cursor instead of at the top left
+	var top, left int
+	x, y, visible := screen.Cursor()
+	if p.options.Fit {
+		top, left = max(y-height+1, 0), max(x-width+1, 0)
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[?25l")
+	for row, line := range screen.Lines()[top:] {
+		if row >= height {
+			break
+		}
+		line.Cells = line.Cells[min(left, len(line.Cells)):]
+		fmt.Fprintf(&b, "\x1b[%d;1H%s\x1b[K", row+1, line.ANSI(width))
+	}
+	b.WriteString("\x1b[J")
+
+	if x, y := x-left, y-top; visible && x < width && y < height {
+		fmt.Fprintf(&b, "\x1b[%d;%dH\x1b[?25h", y+1, x+1)
+	}
+	p.write(b.String())
+	p.drawStatus(true)
+}
+
+// writeOutput writes data to the output. With ThrottleBytes, at most that
+// many bytes are written per frame, counting every event written in it;
+// the rest waits for the next frame, so slow terminals are not flooded.
+func (p *Player) writeOutput(data string) {
+	limit := p.options.ThrottleBytes
+	if limit <= 0 {
+		p.write(data)
+		return
+	}
+
+	for len(data) > 0 && p.writeErr == nil {
+		now := time.Now()
+		if now.Sub(p.frameStart) >= frameInterval {
+			p.frameStart, p.frameBytes = now, 0
+		}
+		budget := limit - p.frameBytes
+		if budget <= 0 {
+			time.Sleep(frameInterval - now.Sub(p.frameStart))
+			continue
+		}
+
+		n := min(budget, len(data))
+		if n < len(data) {
+			// Don't split a UTF-8 sequence across frames
+			for n > 0 && !utf8.RuneStart(data[n]) {
+				n--
+			}
+			if n == 0 {
+				if budget < limit {
+					// Wait for a whole frame's budget
+					p.frameBytes = limit
+					continue
+				}
+				n = budget
+			}
+		}
+		p.write(data[:n])
+		p.frameBytes += n
+		data = data[n:]
+	}
+}
+
+// CatOptions selects what Cat prints
+type CatOptions struct {
+	// Separator prints a "[m 00:00] <file name>" line before each
+	// recording
+	Separator bool
+	// Types are the event types to print; only output if empty
+	Types []string
+	// Plain strips escape sequences and control characters from output
+	// and input, and drops blank lines
+	Plain bool
+}
+
+// Cat prints the events of one or more recordings without timing. Output
+// and input data is written as it was recorded; other selected events,
+// like markers, are printed on their own line as "[<type> <time>] <data>".
+func Cat(filenames []string, options CatOptions) error {
+	types := options.Types
+	if len(types) == 0 {
+		types = []string{asciicast.EventTypeOutput}
+	}
+	selected := make(map[string]bool)
+	for _, t := range types {
+		selected[t] = true
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	// Output and input are collected in stream, so escape sequences split
+	// across events are stripped as a whole in plain mode
+	var stream strings.Builder
+	atLineStart := true
+	flush := func() {
+		text := stream.String()
+		stream.Reset()
+		if options.Plain {
+			text = sanitize.CleanLines(text)
+			if text != "" {
+				text += "\n"
+			}
+		}
+		if text != "" {
+			out.WriteString(text)
+			atLineStart = strings.HasSuffix(text, "\n")
+		}
+	}
+
+	line := func(event asciicast.Event) {
+		flush()
+		if !atLineStart {
+			out.WriteByte('\n')
+		}
+		value := event.Data
+		if event.Raw != nil {
+			value = string(event.Raw)
 		}
+		fmt.Fprintf(out, "[%s %s] %s\n", event.Type, formatTime(event.Time), value)
+		atLineStart = true
 	}
 
-	cleaned := sanitize.CleanLines(buf.String())
-	if cleaned != "" {
-		os.Stdout.WriteString(cleaned + "\n")
+	for _, filename := range filenames {
+		reader, err := asciicast.Open(filename)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		if options.Separator {
+			line(asciicast.Event{Type: asciicast.EventTypeMarker, Data: filepath.Base(filename)})
+		}
+
+		for reader.Next() {
+			event := reader.Event()
+			if !selected[event.Type] {
+				continue
+			}
+			switch event.Type {
+			case asciicast.EventTypeOutput, asciicast.EventTypeInput:
+				stream.WriteString(event.Data)
+				if !options.Plain {
+					flush()
+				}
+			default:
+				line(event)
+			}
+		}
+		flush()
+		reader.Close()
+		if err := reader.Err(); err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
 	}
 	return nil
 }
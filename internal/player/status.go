@@ -0,0 +1,167 @@
+package player
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// statusRefresh is how often an unchanged status line is redrawn, in case
+// recorded output scrolled it away
+const statusRefresh = 250 * time.Millisecond
+
+// noticeDuration is how long a transient notice, like the new speed, stays
+// on screen
+const noticeDuration = time.Second
+
+// Playback speed limits for the speed keys
+const (
+	minSpeed = 1.0 / 16
+	maxSpeed = 64.0
+)
+
+// statusLine returns the text of the status line
+func (p *Player) statusLine() string {
+	state := "playing"
+	if p.paused {
+		state = "paused"
+	}
+	return fmt.Sprintf(" %s  %s / %s  %gx ", state, formatTime(p.position), formatTime(p.duration), p.options.Speed)
+}
+
+// drawStatus draws the status line on the bottom row of the terminal,
+// saving and restoring the cursor around it. Unless force is set, it is
+// skipped when the line is unchanged and was drawn recently.
+func (p *Player) drawStatus(force bool) {
+	p.drawSubtitle(force)
+	if !p.status {
+		return
+	}
+	cols, rows, ok := p.terminalSize()
+	if !ok {
+		return
+	}
+
+	line := p.statusLine()
+	if !force && line == p.lastStatus && time.Since(p.statusDrawn) < statusRefresh {
+		return
+	}
+	p.lastStatus = line
+	p.statusDrawn = time.Now()
+
+	if len(line) > cols {
+		line = line[:cols]
+	}
+	p.write(fmt.Sprintf("\x1b7\x1b[%d;1H\x1b[0m\x1b[2K\x1b[7m%s%s\x1b[0m\x1b8", rows, line, strings.Repeat(" ", cols-len(line))))
+}
+
+// drawSubtitle draws the current marker's label centered on the top or
+// bottom row, above the status line if it is shown. Like the status line,
+// it is redrawn every so often in case output overwrote it.
+func (p *Player) drawSubtitle(force bool) {
+	if p.options.Subtitles == "" {
+		return
+	}
+	cols, rows, ok := p.terminalSize()
+	if !ok {
+		return
+	}
+	if !force && p.subtitle == p.lastSubtitle && time.Since(p.subtitleDrawn) < statusRefresh {
+		return
+	}
+	if p.subtitle == "" && p.lastSubtitle == "" {
+		return
+	}
+	p.lastSubtitle = p.subtitle
+	p.subtitleDrawn = time.Now()
+
+	row := 1
+	if p.options.Subtitles == SubtitlesBottom {
+		row = rows
+		if p.status {
+			row--
+		}
+	}
+	if p.subtitle == "" {
+		p.write(fmt.Sprintf("\x1b7\x1b[%d;1H\x1b[0m\x1b[2K\x1b8", row))
+		return
+	}
+	text := " " + p.subtitle + " "
+	if n := utf8.RuneCountInString(text); n > cols {
+		text = string([]rune(text)[:cols])
+	}
+	pad := (cols - utf8.RuneCountInString(text)) / 2
+	p.write(fmt.Sprintf("\x1b7\x1b[%d;1H\x1b[0m\x1b[2K\x1b[%dG\x1b[1;7m%s\x1b[0m\x1b8", row, pad+1, text))
+}
+
+// clearStatus erases the status line
+func (p *Player) clearStatus() {
+	_, rows, ok := p.terminalSize()
+	if !ok {
+		return
+	}
+	p.write(fmt.Sprintf("\x1b7\x1b[%d;1H\x1b[0m\x1b[2K\x1b8", rows))
+	p.lastStatus = ""
+}
+
+// changeSpeed doubles or halves the playback speed, which then applies to
+// the rest of the session, and shows the new speed
+func (p *Player) changeSpeed(faster bool) {
+	if faster {
+		p.options.Speed = min(p.options.Speed*2, maxSpeed)
+	} else {
+		p.options.Speed = max(p.options.Speed/2, minSpeed)
+	}
+	p.showNotice(fmt.Sprintf(" speed %gx ", p.options.Speed))
+}
+
+// showNotice shows text in inverse at the right of the bottom row for
+// noticeDuration. The status line already shows the speed, so with it on
+// only the status line is redrawn.
+func (p *Player) showNotice(text string) {
+	if p.status {
+		p.drawStatus(true)
+		return
+	}
+	cols, rows, ok := p.terminalSize()
+	if !ok {
+		return
+	}
+	if len(text) > cols {
+		text = text[:cols]
+	}
+	if p.notice != "" {
+		p.write(fmt.Sprintf("\x1b7\x1b[%d;1H\x1b[0m\x1b[2K\x1b8", rows))
+	}
+	p.notice = text
+	p.noticeUntil = time.Now().Add(noticeDuration)
+	p.write(fmt.Sprintf("\x1b7\x1b[%d;%dH\x1b[0m\x1b[7m%s\x1b[0m\x1b8", rows, cols-len(text)+1, text))
+}
+
+// clearNotice erases the notice, unless the status line has replaced it
+func (p *Player) clearNotice() {
+	if p.notice != "" && !p.status {
+		p.clearStatus()
+	}
+	p.notice = ""
+}
+
+// toggleStatus shows or hides the status line
+func (p *Player) toggleStatus() {
+	p.status = !p.status
+	if p.status {
+		p.drawStatus(true)
+	} else {
+		p.clearStatus()
+	}
+}
+
+// formatTime formats seconds as MM:SS, or H:MM:SS from an hour
+func formatTime(seconds float64) string {
+	s := int(seconds)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%02d:%02d", s/60, s%60)
+}
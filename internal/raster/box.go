@@ -0,0 +1,122 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Line weights of box drawing arms
+const (
+	armNone = iota
+	armLight
+	armHeavy
+	armDouble
+)
+
+// boxArms are the lines a box drawing character draws from the middle of
+// its cell to each edge
+type boxArms struct {
+	up, down, left, right int
+}
+
+// boxChars are the box drawing characters drawn as lines, so they join up
+// across cells
+var boxChars = map[rune]boxArms{
+	'─': {0, 0, 1, 1}, '│': {1, 1, 0, 0}, '┌': {0, 1, 0, 1}, '┐': {0, 1, 1, 0},
+	'└': {1, 0, 0, 1}, '┘': {1, 0, 1, 0}, '├': {1, 1, 0, 1}, '┤': {1, 1, 1, 0},
+	'┬': {0, 1, 1, 1}, '┴': {1, 0, 1, 1}, '┼': {1, 1, 1, 1},
+	'╭': {0, 1, 0, 1}, '╮': {0, 1, 1, 0}, '╯': {1, 0, 1, 0}, '╰': {1, 0, 0, 1},
+	'╴': {0, 0, 1, 0}, '╵': {1, 0, 0, 0}, '╶': {0, 0, 0, 1}, '╷': {0, 1, 0, 0},
+	'━': {0, 0, 2, 2}, '┃': {2, 2, 0, 0}, '┏': {0, 2, 0, 2}, '┓': {0, 2, 2, 0},
+	'┗': {2, 0, 0, 2}, '┛': {2, 0, 2, 0}, '┣': {2, 2, 0, 2}, '┫': {2, 2, 2, 0},
+	'┳': {0, 2, 2, 2}, '┻': {2, 0, 2, 2}, '╋': {2, 2, 2, 2},
+	'═': {0, 0, 3, 3}, '║': {3, 3, 0, 0}, '╔': {0, 3, 0, 3}, '╗': {0, 3, 3, 0},
+	'╚': {3, 0, 0, 3}, '╝': {3, 0, 3, 0}, '╠': {3, 3, 0, 3}, '╣': {3, 3, 3, 0},
+	'╦': {0, 3, 3, 3}, '╩': {3, 0, 3, 3}, '╬': {3, 3, 3, 3},
+}
+
+// drawShape draws box drawing and block element characters, which must
+// fill their cells exactly to join up, into cell. It reports false for
+// other characters.
+func drawShape(img *image.RGBA, cell image.Rectangle, r rune, fg, bg color.RGBA, scale int) bool {
+	w, h := cell.Dx(), cell.Dy()
+	fill := func(x0, y0, x1, y1 int, c color.RGBA) {
+		rect := image.Rect(cell.Min.X+x0, cell.Min.Y+y0, cell.Min.X+x1, cell.Min.Y+y1).Intersect(cell)
+		draw.Draw(img, rect, image.NewUniform(c), image.Point{}, draw.Src)
+	}
+
+	switch {
+	case r == '█':
+		fill(0, 0, w, h, fg)
+	case r == '▀':
+		fill(0, 0, w, h/2, fg)
+	case r == '▐':
+		fill(w/2, 0, w, h, fg)
+	case r >= '▁' && r <= '▇': // lower eighths
+		n := int(r-'▁') + 1
+		fill(0, h-h*n/8, w, h, fg)
+	case r >= '▉' && r <= '▏': // left eighths
+		n := 8 - int(r-'▉') - 1
+		fill(0, 0, w*n/8, h, fg)
+	case r == '░' || r == '▒' || r == '▓':
+		fill(0, 0, w, h, blend(bg, fg, float64(r-'░'+1)/4))
+	default:
+		arms, ok := boxChars[r]
+		if !ok {
+			return false
+		}
+		drawArms(fill, w, h, arms, fg, scale)
+	}
+	return true
+}
+
+// drawArms draws the lines of a box drawing character from the middle of
+// a w by h cell
+func drawArms(fill func(x0, y0, x1, y1 int, c color.RGBA), w, h int, arms boxArms, c color.RGBA, scale int) {
+	cx, cy := w/2, h/2
+	// lines returns the offsets from the middle and the thickness of the
+	// strokes of an arm
+	lines := func(weight int) ([]int, int) {
+		switch weight {
+		case armHeavy:
+			return []int{-scale}, 2 * scale
+		case armDouble:
+			t := max(scale/2, 1)
+			return []int{-scale, scale - t}, t
+		}
+		return []int{-scale / 2}, scale
+	}
+	// extent returns the span the strokes of the heavier of two opposite
+	// arms cover, which the crossing arms reach to, closing corners
+	extent := func(a, b int) (int, int) {
+		if a == armNone && b == armNone {
+			return 0, 0
+		}
+		offsets, t := lines(max(a, b))
+		return offsets[0], offsets[len(offsets)-1] + t
+	}
+	hmin, hmax := extent(arms.left, arms.right)
+	vmin, vmax := extent(arms.up, arms.down)
+
+	if offsets, t := lines(arms.up); arms.up != armNone {
+		for _, o := range offsets {
+			fill(cx+o, 0, cx+o+t, cy+hmax, c)
+		}
+	}
+	if offsets, t := lines(arms.down); arms.down != armNone {
+		for _, o := range offsets {
+			fill(cx+o, cy+hmin, cx+o+t, h, c)
+		}
+	}
+	if offsets, t := lines(arms.left); arms.left != armNone {
+		for _, o := range offsets {
+			fill(0, cy+o, cx+vmax, cy+o+t, c)
+		}
+	}
+	if offsets, t := lines(arms.right); arms.right != armNone {
+		for _, o := range offsets {
+			fill(cx+vmin, cy+o, w, cy+o+t, c)
+		}
+	}
+}
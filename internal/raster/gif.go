@@ -0,0 +1,200 @@
+package raster
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"math"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/ober/goasciinema/internal/vt"
+)
+
+// MaxFPS is the highest frame rate GIF delays, in hundredths of a second,
+// can be relied on for: browsers slow down shorter delays
+const MaxFPS = 50
+
+// GIFOptions control the timing of an animation
+type GIFOptions struct {
+	FPS           int     // frames per second at most
+	Speed         float64 // playback speed factor
+	IdleTimeLimit float64 // cap on pauses in seconds, or 0 for none
+	LastFrame     float64 // seconds the final frame is shown before looping
+}
+
+// frame is a captured screen and when it is shown, in seconds
+type frame struct {
+	img  *image.RGBA
+	time float64
+}
+
+// WriteGIF replays a recording through a terminal emulator and writes it
+// as an animated GIF. Screen changes are grouped to at most opts.FPS
+// frames per second, and each frame holds only the area that changed.
+func (r *Renderer) WriteGIF(w io.Writer, header asciicast.Header, events []asciicast.Event, opts GIFOptions) error {
+	fps := min(max(opts.FPS, 1), MaxFPS)
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	cols, rows := screenSize(header, events)
+	term := vt.New(header.Width, header.Height)
+
+	frames := []frame{{img: r.Render(term, cols, rows)}}
+	var elapsed, prev, changed float64
+	dirty := false
+	capture := func(t float64) {
+		dirty = false
+		// A frame due in the same hundredth of a second replaces the last
+		if last := &frames[len(frames)-1]; centis(t) == centis(last.time) {
+			last.img = r.Render(term, cols, rows)
+			return
+		}
+		frames = append(frames, frame{img: r.Render(term, cols, rows), time: t})
+	}
+	for _, event := range events {
+		gap := math.Max(event.Time-prev, 0)
+		prev = event.Time
+		if opts.IdleTimeLimit > 0 && gap > opts.IdleTimeLimit {
+			gap = opts.IdleTimeLimit
+		}
+		elapsed += gap / speed
+
+		// Changes in one frame interval are shown together, from the
+		// time of the first
+		if dirty && math.Floor(elapsed*float64(fps)) > math.Floor(changed*float64(fps)) {
+			capture(changed)
+		}
+		switch event.Type {
+		case asciicast.EventTypeOutput:
+			term.Write(event.Data)
+		case asciicast.EventTypeResize:
+			if width, height, ok := asciicast.ParseResize(event.Data); ok {
+				term.Resize(width, height)
+			}
+		default:
+			continue
+		}
+		if !dirty {
+			dirty, changed = true, elapsed
+		}
+	}
+	if dirty {
+		capture(changed)
+	}
+
+	return encodeGIF(w, frames, math.Max(opts.LastFrame, 0))
+}
+
+// screenSize returns the largest size the terminal is resized to in a
+// recording, which images of it are made to fit
+func screenSize(header asciicast.Header, events []asciicast.Event) (cols, rows int) {
+	cols, rows = header.Width, header.Height
+	for _, event := range events {
+		if event.Type == asciicast.EventTypeResize {
+			if width, height, ok := asciicast.ParseResize(event.Data); ok {
+				cols, rows = max(cols, width), max(rows, height)
+			}
+		}
+	}
+	return max(cols, 1), max(rows, 1)
+}
+
+// encodeGIF writes frames as a looping GIF. Each frame after the first is
+// cropped to the area that differs from the one before; frames that
+// change nothing just lengthen the previous one.
+func encodeGIF(w io.Writer, frames []frame, lastFrame float64) error {
+	bounds := frames[0].img.Bounds()
+	anim := &gif.GIF{Config: image.Config{Width: bounds.Dx(), Height: bounds.Dy()}}
+	var times []float64
+	var prev *image.RGBA
+	for _, f := range frames {
+		rect := bounds
+		if prev != nil {
+			rect = changedArea(prev, f.img)
+			if rect.Empty() {
+				continue
+			}
+		}
+		anim.Image = append(anim.Image, paletted(f.img.SubImage(rect).(*image.RGBA)))
+		times = append(times, f.time)
+		prev = f.img
+	}
+
+	end := times[len(times)-1] + lastFrame
+	for i := range anim.Image {
+		next := end
+		if i+1 < len(times) {
+			next = times[i+1]
+		}
+		// Rounding the times rather than the gaps keeps the total right
+		anim.Delay = append(anim.Delay, max(centis(next)-centis(times[i]), 1))
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+	}
+
+	if err := gif.EncodeAll(w, anim); err != nil {
+		return fmt.Errorf("failed to encode GIF: %w", err)
+	}
+	return nil
+}
+
+// centis converts seconds to hundredths of a second
+func centis(t float64) int {
+	return int(math.Round(t * 100))
+}
+
+// changedArea returns the smallest rectangle holding every pixel that
+// differs between two images of the same size
+func changedArea(a, b *image.RGBA) image.Rectangle {
+	bounds := a.Bounds()
+	minX, minY, maxX, maxY := bounds.Max.X, bounds.Max.Y, bounds.Min.X, bounds.Min.Y
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		i := a.PixOffset(bounds.Min.X, y)
+		rowA := a.Pix[i : i+4*bounds.Dx()]
+		rowB := b.Pix[i : i+4*bounds.Dx()]
+		if string(rowA) == string(rowB) {
+			continue
+		}
+		minY, maxY = min(minY, y), max(maxY, y+1)
+		for x := 0; x < bounds.Dx(); x++ {
+			if string(rowA[4*x:4*x+4]) != string(rowB[4*x:4*x+4]) {
+				minX, maxX = min(minX, bounds.Min.X+x), max(maxX, bounds.Min.X+x+1)
+			}
+		}
+	}
+	return image.Rect(minX, minY, maxX, maxY)
+}
+
+// paletted converts an image to the paletted form GIF frames take. The
+// palette holds the image's own colors, which are few for a terminal;
+// past 256, the rest are mapped to the nearest of those.
+func paletted(img *image.RGBA) *image.Paletted {
+	index := make(map[color.RGBA]uint8)
+	var palette color.Palette
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y && len(palette) < 256; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X && len(palette) < 256; x++ {
+			c := img.RGBAAt(x, y)
+			if _, ok := index[c]; !ok {
+				index[c] = uint8(len(palette))
+				palette = append(palette, c)
+			}
+		}
+	}
+
+	out := image.NewPaletted(bounds, palette)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			i, ok := index[c]
+			if !ok {
+				i = uint8(palette.Index(c))
+			}
+			out.SetColorIndex(x, y, i)
+		}
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/ober/goasciinema/internal/vt"
+)
+
+// Renderer draws terminal screens as images, in a theme and with the
+// built-in bitmap font scaled up by Scale
+type Renderer struct {
+	Theme Theme
+	Scale int
+}
+
+// NewRenderer creates a renderer; scales below 1 are raised to 1
+func NewRenderer(theme Theme, scale int) *Renderer {
+	return &Renderer{Theme: theme, Scale: max(scale, 1)}
+}
+
+// padding is the margin around the screen, in cells
+const padding = 1
+
+// CellSize returns the size of one character cell in pixels
+func (r *Renderer) CellSize() (width, height int) {
+	return cellWidth * r.Scale, cellHeight * r.Scale
+}
+
+// Size returns the size in pixels of an image of a cols by rows screen
+func (r *Renderer) Size(cols, rows int) (width, height int) {
+	cw, ch := r.CellSize()
+	return (cols + 2*padding) * cw, (rows + 2*padding) * ch
+}
+
+// Render draws the terminal's screen into a new image the size of a cols
+// by rows screen. A smaller screen is drawn at the top left of it and a
+// larger one is cut off.
+func (r *Renderer) Render(term *vt.Terminal, cols, rows int) *image.RGBA {
+	width, height := r.Size(cols, rows)
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(r.Theme.Background), image.Point{}, draw.Src)
+
+	cw, ch := r.CellSize()
+	cursorX, cursorY, cursorVisible := term.Cursor()
+	for y, line := range term.Lines() {
+		if y >= rows {
+			break
+		}
+		for x, cell := range line.Cells {
+			if x >= cols {
+				break
+			}
+			cursor := cursorVisible && x == cursorX && y == cursorY
+			origin := image.Pt((x+padding)*cw, (y+padding)*ch)
+			r.drawCell(img, image.Rectangle{Min: origin, Max: origin.Add(image.Pt(cw, ch))}, cell, cursor)
+		}
+	}
+	return img
+}
+
+// drawCell draws one character cell; the cursor is shown in inverse
+func (r *Renderer) drawCell(img *image.RGBA, rect image.Rectangle, cell vt.Cell, cursor bool) {
+	attr := cell.Attr
+	fgColor := attr.FG
+	// Bold text in one of the 8 normal colors is shown bright
+	if attr.Bold && fgColor < 8 {
+		fgColor += 8
+	}
+	fg := r.Theme.Color(fgColor, r.Theme.Foreground)
+	bg := r.Theme.Color(attr.BG, r.Theme.Background)
+	if attr.Inverse != cursor {
+		fg, bg = bg, fg
+	}
+	if attr.Faint {
+		fg = blend(fg, bg, 0.5)
+	}
+
+	if bg != r.Theme.Background {
+		draw.Draw(img, rect, image.NewUniform(bg), image.Point{}, draw.Src)
+	}
+	if cell.Char != 0 && cell.Char != ' ' && !drawShape(img, rect, cell.Char, fg, bg, r.Scale) {
+		r.drawGlyph(img, rect.Min, glyph(cell.Char), fg)
+	}
+	if attr.Underline {
+		y := rect.Max.Y - r.Scale
+		draw.Draw(img, image.Rect(rect.Min.X, y, rect.Max.X, y+r.Scale), image.NewUniform(fg), image.Point{}, draw.Src)
+	}
+}
+
+// drawGlyph draws a font glyph with its top left cell corner at origin
+func (r *Renderer) drawGlyph(img *image.RGBA, origin image.Point, bitmap [glyphHeight]byte, c color.RGBA) {
+	s := r.Scale
+	for row, bits := range bitmap {
+		for col := 0; col < glyphWidth; col++ {
+			if bits&(1<<(glyphWidth-1-col)) == 0 {
+				continue
+			}
+			x := origin.X + col*s
+			y := origin.Y + (glyphTop+row)*s
+			draw.Draw(img, image.Rect(x, y, x+s, y+s), image.NewUniform(c), image.Point{}, draw.Src)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package raster
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/ober/goasciinema/internal/vt"
+)
+
+// Theme is the colors of a rendered terminal
+type Theme struct {
+	Foreground color.RGBA
+	Background color.RGBA
+	Palette    [16]color.RGBA // the 8 normal and 8 bright ANSI colors
+}
+
+// DefaultTheme is used when neither the user nor the recording picks one
+const DefaultTheme = "asciinema"
+
+// themes are the built-in themes, as the background, foreground and 16
+// palette colors
+var themes = map[string]string{
+	"asciinema":       "121314,cccccc,000000,dd3c69,4ebf22,ddaf3c,26b0d7,b954e1,54e1b9,d9d9d9,4d4d4d,dd3c69,4ebf22,ddaf3c,26b0d7,b954e1,54e1b9,ffffff",
+	"dracula":         "282a36,f8f8f2,21222c,ff5555,50fa7b,f1fa8c,bd93f9,ff79c6,8be9fd,f8f8f2,6272a4,ff6e6e,69ff94,ffffa5,d6acff,ff92df,a4ffff,ffffff",
+	"monokai":         "272822,f8f8f2,272822,f92672,a6e22e,f4bf75,66d9ef,ae81ff,a1efe4,f8f8f2,75715e,f92672,a6e22e,f4bf75,66d9ef,ae81ff,a1efe4,f9f8f5",
+	"solarized-dark":  "002b36,839496,073642,dc322f,859900,b58900,268bd2,d33682,2aa198,eee8d5,002b36,cb4b16,586e75,657b83,839496,6c71c4,93a1a1,fdf6e3",
+	"solarized-light": "fdf6e3,657b83,073642,dc322f,859900,b58900,268bd2,d33682,2aa198,eee8d5,002b36,cb4b16,586e75,657b83,839496,6c71c4,93a1a1,fdf6e3",
+}
+
+// ThemeNames returns the built-in theme names in sorted order
+func ThemeNames() []string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseTheme returns a built-in theme by name, or parses a custom one
+// given as comma-separated hex colors: background, foreground, then 8 or
+// 16 palette colors
+func ParseTheme(spec string) (Theme, error) {
+	if colors, ok := themes[spec]; ok {
+		spec = colors
+	}
+	colors := strings.Split(spec, ",")
+	if len(colors) != 10 && len(colors) != 18 {
+		return Theme{}, fmt.Errorf("unknown theme %q (expected one of %s, or bg,fg and 8 or 16 palette colors in hex)", spec, strings.Join(ThemeNames(), ", "))
+	}
+	var theme Theme
+	var err error
+	if theme.Background, err = parseHex(colors[0]); err != nil {
+		return Theme{}, err
+	}
+	if theme.Foreground, err = parseHex(colors[1]); err != nil {
+		return Theme{}, err
+	}
+	if err := theme.setPalette(colors[2:]); err != nil {
+		return Theme{}, err
+	}
+	return theme, nil
+}
+
+// HeaderTheme returns the theme stored in a recording's header, filling
+// in what it leaves out from the default theme. It reports false if the
+// header has no usable theme.
+func HeaderTheme(h *asciicast.Theme) (Theme, bool) {
+	if h == nil {
+		return Theme{}, false
+	}
+	theme, _ := ParseTheme(DefaultTheme)
+	ok := false
+	if c, err := parseHex(h.Foreground); err == nil {
+		theme.Foreground, ok = c, true
+	}
+	if c, err := parseHex(h.Background); err == nil {
+		theme.Background, ok = c, true
+	}
+	if h.Palette != "" && theme.setPalette(strings.Split(h.Palette, ":")) == nil {
+		ok = true
+	}
+	return theme, ok
+}
+
+// setPalette sets the palette from 8 or 16 hex colors; with 8, the
+// bright colors repeat the normal ones
+func (t *Theme) setPalette(colors []string) error {
+	if len(colors) != 8 && len(colors) != 16 {
+		return fmt.Errorf("palette has %d colors (expected 8 or 16)", len(colors))
+	}
+	var palette [16]color.RGBA
+	for i := range palette {
+		c, err := parseHex(colors[i%len(colors)])
+		if err != nil {
+			return err
+		}
+		palette[i] = c
+	}
+	t.Palette = palette
+	return nil
+}
+
+// parseHex parses a color written as rrggbb or #rrggbb
+func parseHex(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q (expected rrggbb)", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// cubeLevels are the channel values of the xterm 6x6x6 color cube
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+// Color returns the RGB value of a terminal color, using def for the
+// terminal default
+func (t Theme) Color(c vt.Color, def color.RGBA) color.RGBA {
+	switch {
+	case c == vt.DefaultColor:
+		return def
+	case c.IsRGB():
+		r, g, b := c.Components()
+		return color.RGBA{R: r, G: g, B: b, A: 0xff}
+	case c < 16:
+		return t.Palette[c]
+	case c < 232:
+		i := int(c) - 16
+		return color.RGBA{R: cubeLevels[i/36], G: cubeLevels[i/6%6], B: cubeLevels[i%6], A: 0xff}
+	case c < 256:
+		v := uint8(8 + 10*(int(c)-232))
+		return color.RGBA{R: v, G: v, B: v, A: 0xff}
+	}
+	return def
+}
+
+// blend mixes a fraction f of b into a
+func blend(a, b color.RGBA, f float64) color.RGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*f + 0.5)
+	}
+	return color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 0xff}
+}
@@ -0,0 +1,70 @@
+package raster
+
+import (
+	"io"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/ober/goasciinema/internal/vt"
+)
+
+// VideoOptions control the frames of a video
+type VideoOptions struct {
+	FPS       int     // frames per second
+	LastFrame float64 // seconds the final frame is held
+}
+
+// VideoSize returns the size in pixels of the frames WriteFrames writes
+// for a recording
+func (r *Renderer) VideoSize(header asciicast.Header, events []asciicast.Event) (width, height int) {
+	return r.Size(screenSize(header, events))
+}
+
+// WriteFrames replays a recording through a terminal emulator in real
+// time and writes it as raw RGBA frames at a constant frame rate, the
+// form video encoders such as ffmpeg read from a pipe. It returns the
+// length of the video in seconds.
+func (r *Renderer) WriteFrames(w io.Writer, header asciicast.Header, events []asciicast.Event, opts VideoOptions) (float64, error) {
+	fps := float64(max(opts.FPS, 1))
+	cols, rows := screenSize(header, events)
+	term := vt.New(header.Width, header.Height)
+
+	img := r.Render(term, cols, rows)
+	frames := 0
+	dirty := false
+	// writeUntil writes the frames shown before time t
+	writeUntil := func(t float64) error {
+		for ; float64(frames)/fps < t; frames++ {
+			if dirty {
+				img, dirty = r.Render(term, cols, rows), false
+			}
+			if _, err := w.Write(img.Pix); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var end float64
+	for _, event := range events {
+		if err := writeUntil(event.Time); err != nil {
+			return 0, err
+		}
+		end = max(end, event.Time)
+		switch event.Type {
+		case asciicast.EventTypeOutput:
+			term.Write(event.Data)
+		case asciicast.EventTypeResize:
+			if width, height, ok := asciicast.ParseResize(event.Data); ok {
+				term.Resize(width, height)
+			}
+		default:
+			continue
+		}
+		dirty = true
+	}
+	// Always at least one frame, showing the final screen
+	if err := writeUntil(max(end+opts.LastFrame, float64(frames+1)/fps)); err != nil {
+		return 0, err
+	}
+	return float64(frames) / fps, nil
+}
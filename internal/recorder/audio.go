@@ -0,0 +1,95 @@
+package recorder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// AudioOutputEnv names the environment variable holding the audio output
+// path for the audio capture command
+const AudioOutputEnv = "GOASCIINEMA_AUDIO"
+
+// audioSyncInterval is how often a sync marker is written while audio is
+// being recorded
+const audioSyncInterval = 10 * time.Second
+
+// audioStopTimeout is how long the capture command gets to finish writing
+// its file after being interrupted
+const audioStopTimeout = 5 * time.Second
+
+// DefaultAudioCommand returns the capture command used when none is
+// configured. It records the default microphone with ffmpeg.
+func DefaultAudioCommand() string {
+	input := "-f pulse -i default"
+	if runtime.GOOS == "darwin" {
+		input = "-f avfoundation -i :0"
+	}
+	return fmt.Sprintf(`exec ffmpeg -nostdin -loglevel error %s -y "$%s"`, input, AudioOutputEnv)
+}
+
+// audioRecorder supervises an external audio capture command
+type audioRecorder struct {
+	cmd     *exec.Cmd
+	stderr  bytes.Buffer
+	started time.Time
+	exited  chan error
+}
+
+// startAudio starts the capture command with its output path in
+// $GOASCIINEMA_AUDIO. The command runs in its own process group so it can
+// be interrupted cleanly when recording ends.
+func startAudio(command, output string) (*audioRecorder, error) {
+	a := &audioRecorder{exited: make(chan error, 1)}
+	a.cmd = exec.Command("/bin/sh", "-c", command)
+	a.cmd.Env = append(os.Environ(), AudioOutputEnv+"="+output)
+	a.cmd.Stderr = &a.stderr
+	a.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := a.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start audio recorder: %w", err)
+	}
+	a.started = time.Now()
+
+	go func() {
+		a.exited <- a.cmd.Wait()
+	}()
+	return a, nil
+}
+
+// elapsed returns how long audio has been recording
+func (a *audioRecorder) elapsed() float64 {
+	return time.Since(a.started).Seconds()
+}
+
+// stop interrupts the capture command, waits for it to finish writing, and
+// reports any failure along with the command's error output
+func (a *audioRecorder) stop() error {
+	var err error
+	select {
+	case err = <-a.exited:
+		// The recorder stopped on its own, which is always a failure
+		if err == nil {
+			err = fmt.Errorf("exited early")
+		}
+	default:
+		syscall.Kill(-a.cmd.Process.Pid, syscall.SIGINT)
+		select {
+		case <-a.exited:
+			return nil
+		case <-time.After(audioStopTimeout):
+			syscall.Kill(-a.cmd.Process.Pid, syscall.SIGKILL)
+			err = <-a.exited
+		}
+	}
+
+	if msg := strings.TrimSpace(a.stderr.String()); msg != "" {
+		return fmt.Errorf("audio recorder failed: %v: %s", err, msg)
+	}
+	return fmt.Errorf("audio recorder failed: %v", err)
+}
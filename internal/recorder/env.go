@@ -0,0 +1,62 @@
+package recorder
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// secretEnvWords mark variable names that likely hold secrets. Patterns
+// never capture them; only naming one exactly does.
+var secretEnvWords = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "PASS", "KEY", "CREDENTIAL", "AUTH", "COOKIE", "PRIVATE"}
+
+// captureEnv returns the environment variables selected by rules, in
+// order: NAME or a pattern like LC_* captures variables, and -NAME or
+// -PATTERN drops ones captured by earlier rules. Unset variables are
+// skipped.
+func captureEnv(rules []string) map[string]string {
+	env := make(map[string]string)
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		deny := strings.HasPrefix(rule, "-")
+		pattern := strings.TrimPrefix(rule, "-")
+		if pattern == "" {
+			continue
+		}
+
+		if deny {
+			for name := range env {
+				if matched, _ := path.Match(pattern, name); matched {
+					delete(env, name)
+				}
+			}
+			continue
+		}
+		if !strings.ContainsAny(pattern, "*?[") {
+			if value, ok := os.LookupEnv(pattern); ok {
+				env[pattern] = value
+			}
+			continue
+		}
+		for _, entry := range os.Environ() {
+			name, value, _ := strings.Cut(entry, "=")
+			if matched, _ := path.Match(pattern, name); matched && !secretEnvName(name) {
+				env[name] = value
+			}
+		}
+	}
+	return env
+}
+
+// secretEnvName reports whether a variable name looks like it holds a
+// secret, such as GITHUB_TOKEN or AWS_SECRET_ACCESS_KEY
+func secretEnvName(name string) bool {
+	for _, part := range strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool { return r == '_' || r == '-' }) {
+		for _, word := range secretEnvWords {
+			if part == word || strings.HasSuffix(part, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package recorder
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Recording indicator styles
+const (
+	IndicatorTitle  = "title"  // show status in the window title
+	IndicatorStatus = "status" // show status on the terminal's status line
+)
+
+// indicatorInterval is how often the indicator is refreshed
+const indicatorInterval = time.Second
+
+// Escape sequences for the indicator. The window title is saved and
+// restored with the xterm title stack; the status line uses the DEC
+// host-writable status line (DECSSDT/DECSASD).
+const (
+	titlePush        = "\x1b[22;2t"
+	titlePop         = "\x1b[23;2t"
+	statusLineShow   = "\x1b[2$~"
+	statusLineHide   = "\x1b[0$~"
+	statusLineSelect = "\x1b[1$}"
+	mainDisplay      = "\x1b[0$}"
+)
+
+// runIndicator refreshes the recording indicator until done is closed
+func (r *Recorder) runIndicator(done <-chan struct{}) {
+	switch r.options.Indicator {
+	case IndicatorTitle:
+		os.Stdout.WriteString(titlePush)
+		defer os.Stdout.WriteString(titlePop)
+	case IndicatorStatus:
+		os.Stdout.WriteString(statusLineShow)
+		defer os.Stdout.WriteString(statusLineHide)
+	}
+
+	ticker := time.NewTicker(indicatorInterval)
+	defer ticker.Stop()
+
+	for {
+		r.drawIndicator()
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drawIndicator writes the current recording state to the indicator
+func (r *Recorder) drawIndicator() {
+	r.mu.Lock()
+	state := "REC"
+	if !r.pausedAt.IsZero() {
+		state = "PAUSED"
+	}
+	total := int(r.elapsedTime() - r.idleCut)
+	text := fmt.Sprintf("%s %02d:%02d:%02d, %d chars", state, total/3600, (total%3600)/60, total%60, r.chars)
+	r.mu.Unlock()
+
+	switch r.options.Indicator {
+	case IndicatorTitle:
+		os.Stdout.WriteString("\x1b]2;" + text + "\x07")
+	case IndicatorStatus:
+		os.Stdout.WriteString(statusLineSelect + "\r\x1b[2K" + text + mainDisplay)
+	}
+}
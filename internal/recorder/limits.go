@@ -0,0 +1,141 @@
+package recorder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+)
+
+// openWriter starts writing the recording to a cast file
+func (r *Recorder) openWriter(filename string, header asciicast.Header, appendTo bool) error {
+	writer, err := asciicast.NewWriter(filename, header, appendTo)
+	if err != nil {
+		return fmt.Errorf("failed to create writer: %w", err)
+	}
+
+	// Never let clock adjustments or append offsets produce out-of-order events
+	writer.SetTimePolicy(asciicast.TimeClamp)
+	writer.SetFlushPolicy(r.options.FlushInterval, r.options.Fsync)
+	writer.SetWriteDuration(true)
+
+	r.writer = writer
+	r.files = append(r.files, filename)
+	return nil
+}
+
+// limitCheckInterval is how often MaxDuration is checked while nothing is
+// being recorded
+const limitCheckInterval = time.Second
+
+// closeWriter finishes a cast file in the background. Rewriting the
+// header with the duration copies the whole file, which must not hold up
+// recording. Errors are kept for Record to report once the terminal is
+// restored. Callers must hold r.mu.
+func (r *Recorder) closeWriter(writer *asciicast.Writer) {
+	r.closing.Add(1)
+	go func() {
+		defer r.closing.Done()
+		if err := writer.Close(); err != nil {
+			r.mu.Lock()
+			r.writeErrs = append(r.writeErrs, fmt.Errorf("failed to finish recording: %w", err))
+			r.mu.Unlock()
+		}
+	}()
+}
+
+// recordedTime returns the recording clock as an event written now would
+// see it, without moving it. Callers must hold r.mu.
+func (r *Recorder) recordedTime() float64 {
+	t := r.elapsedTime() - r.idleCut
+	if limit := r.options.IdleTimeLimit; limit > 0 {
+		t = min(t, r.lastEvent+limit)
+	}
+	return t
+}
+
+// watchDuration finishes the current file once it is over MaxDuration,
+// even if the session is idle, until done is closed
+func (r *Recorder) watchDuration(done <-chan struct{}) {
+	ticker := time.NewTicker(limitCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		r.mu.Lock()
+		if r.writer != nil {
+			if limit := r.limitReached(r.recordedTime()); limit != "" {
+				r.finishPart(limit)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// limitReached returns which limit the current file has gone over by
+// time t, or "" if none. Callers must hold r.mu.
+func (r *Recorder) limitReached(t float64) string {
+	if limit := r.options.MaxDuration; limit > 0 && t-r.partStart > limit.Seconds() {
+		return "maximum duration " + limit.String()
+	}
+	if limit := r.options.MaxSize; limit > 0 && r.writer.Size() >= limit {
+		return fmt.Sprintf("maximum size %d bytes", limit)
+	}
+	return ""
+}
+
+// finishPart closes the current file at a limit. With Rotate the next
+// event starts a new file; otherwise the session is hung up, which ends
+// the recording. Callers must hold r.mu.
+func (r *Recorder) finishPart(limit string) {
+	r.closeWriter(r.writer)
+	r.writer = nil
+	if r.options.Rotate {
+		r.rotating = true
+		return
+	}
+	r.stopped = limit
+	if r.session != nil {
+		r.session.Signal(syscall.SIGHUP)
+	}
+}
+
+// startPart opens the next file of a rotated recording, starting at event
+// time t. Callers must hold r.mu.
+func (r *Recorder) startPart(t float64) {
+	r.rotating = false
+	header := r.header
+	header.Timestamp = time.Now().Unix()
+	filename := nextPart(r.files[0], len(r.files)+1)
+	if err := r.openWriter(filename, header, false); err != nil {
+		r.writeErrs = append(r.writeErrs, err)
+		r.stopped = "failure to start " + filename
+		return
+	}
+	r.partStart = t
+}
+
+// nextPart returns the filename for part n of a rotated recording, like
+// demo.2.cast for demo.cast, counting up past files that already exist
+func nextPart(filename string, n int) string {
+	suffix := ""
+	if asciicast.IsCompressedName(filename) {
+		suffix = asciicast.ZstdExt
+		filename = strings.TrimSuffix(filename, suffix)
+	}
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	for ; ; n++ {
+		name := fmt.Sprintf("%s.%d%s%s", base, n, ext, suffix)
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			return name
+		}
+	}
+}
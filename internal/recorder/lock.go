@@ -0,0 +1,151 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// lockPollInterval is how often the lock state is polled where no
+// notification mechanism is available
+const lockPollInterval = 5 * time.Second
+
+// lockEvent reports the screen being locked (or the machine going to sleep)
+// and unlocked again
+type lockEvent struct {
+	Locked bool
+	Reason string
+}
+
+// watchScreenLock reports lock state changes until done is closed. On Linux
+// it listens for logind and screensaver signals via dbus-monitor; on macOS
+// it polls the console session's lock flag. Time spent suspended is already
+// excluded from the monotonic recording clock, but sleep signals are still
+// reported so a marker shows where the gap was.
+func watchScreenLock(done <-chan struct{}) <-chan lockEvent {
+	events := make(chan lockEvent, 4)
+	switch runtime.GOOS {
+	case "linux":
+		watchDBus(done, events, "--system",
+			"type='signal',interface='org.freedesktop.login1.Manager',member='PrepareForSleep'",
+			"type='signal',interface='org.freedesktop.login1.Session',member='Lock'",
+			"type='signal',interface='org.freedesktop.login1.Session',member='Unlock'")
+		watchDBus(done, events, "--session",
+			"type='signal',interface='org.freedesktop.ScreenSaver',member='ActiveChanged'",
+			"type='signal',interface='org.gnome.ScreenSaver',member='ActiveChanged'")
+	case "darwin":
+		go pollConsoleLock(done, events)
+	}
+	return events
+}
+
+// watchDBus runs dbus-monitor on one bus and translates matching signals
+// into lock events. It does nothing if dbus-monitor is not installed.
+func watchDBus(done <-chan struct{}, events chan<- lockEvent, bus string, rules ...string) {
+	path, err := exec.LookPath("dbus-monitor")
+	if err != nil {
+		return
+	}
+
+	monitor := exec.Command(path, append([]string{bus}, rules...)...)
+	stdout, err := monitor.StdoutPipe()
+	if err != nil {
+		return
+	}
+	if err := monitor.Start(); err != nil {
+		return
+	}
+
+	go func() {
+		<-done
+		monitor.Process.Kill()
+	}()
+
+	go func() {
+		defer monitor.Wait()
+		scanner := bufio.NewScanner(stdout)
+		var member string
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			switch {
+			case strings.HasPrefix(line, "signal "):
+				member = dbusField(line, "member=")
+				switch member {
+				case "Lock":
+					send(done, events, lockEvent{Locked: true, Reason: "screen locked"})
+				case "Unlock":
+					send(done, events, lockEvent{Locked: false, Reason: "screen unlocked"})
+				}
+			case strings.HasPrefix(line, "boolean "):
+				active := line == "boolean true"
+				switch member {
+				case "PrepareForSleep":
+					if active {
+						send(done, events, lockEvent{Locked: true, Reason: "system sleeping"})
+					} else {
+						send(done, events, lockEvent{Locked: false, Reason: "system resumed"})
+					}
+				case "ActiveChanged":
+					if active {
+						send(done, events, lockEvent{Locked: true, Reason: "screen locked"})
+					} else {
+						send(done, events, lockEvent{Locked: false, Reason: "screen unlocked"})
+					}
+				}
+				member = ""
+			}
+		}
+	}()
+}
+
+// dbusField extracts a key=value field from a dbus-monitor signal line
+func dbusField(line, key string) string {
+	i := strings.Index(line, key)
+	if i < 0 {
+		return ""
+	}
+	value := line[i+len(key):]
+	if j := strings.IndexAny(value, " ;"); j >= 0 {
+		value = value[:j]
+	}
+	return value
+}
+
+// pollConsoleLock polls ioreg for the macOS console lock flag
+func pollConsoleLock(done <-chan struct{}, events chan<- lockEvent) {
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	var locked bool
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			out, err := exec.Command("ioreg", "-n", "Root", "-d1").Output()
+			if err != nil {
+				return
+			}
+			now := bytes.Contains(out, []byte(`"CGSSessionScreenIsLocked"=Yes`))
+			if now == locked {
+				continue
+			}
+			locked = now
+			if locked {
+				send(done, events, lockEvent{Locked: true, Reason: "screen locked"})
+			} else {
+				send(done, events, lockEvent{Locked: false, Reason: "screen unlocked"})
+			}
+		}
+	}
+}
+
+func send(done <-chan struct{}, events chan<- lockEvent, event lockEvent) {
+	select {
+	case events <- event:
+	case <-done:
+	}
+}
@@ -6,12 +6,16 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/creack/pty"
 	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/ober/goasciinema/internal/sanitize"
 	ttypkg "github.com/ober/goasciinema/internal/tty"
 )
 
@@ -19,19 +23,103 @@ import (
 type Options struct {
 	Command       string
 	Title         string
+	Series        string
 	IdleTimeLimit float64
 	RecordStdin   bool
 	Append        bool
 	Cols          int
 	Rows          int
+	// Env selects the environment variables recorded in the header (see
+	// captureEnv); SHELL and TERM if nil
 	Env           []string
+	NotifyMarkers string // "", NotifyMarkersOSC or NotifyMarkersAll
+	SecretScan    string // "", SecretScanMask or SecretScanWarn
+	// Args runs a program directly with arguments instead of Command
+	Args []string
+	// CaptureEnvExtended records terminal capabilities (COLORTERM, LANG,
+	// TERMINFO, truecolor support) in the header env
+	CaptureEnvExtended bool
+	// CaptureTheme asks the terminal for its colors and records them as
+	// the header theme
+	CaptureTheme bool
+	// PauseOnLock stops the recording clock while the screen is locked or
+	// the machine is asleep
+	PauseOnLock bool
+	Indicator   string // "", IndicatorTitle or IndicatorStatus
+	// Audio is the path of an audio narration sidecar recorded alongside
+	// the session by AudioCommand (DefaultAudioCommand if empty)
+	Audio        string
+	AudioCommand string
+	// RawLog additionally writes the plain output stream to a file, and
+	// Relay streams the recording to a TCP address. Failures in either
+	// never affect the cast file.
+	RawLog string
+	Relay  string
+	// Headless records without a controlling terminal (cron, CI): no raw
+	// mode, resizing, keyboard input or indicator, and the size is Cols x
+	// Rows or 80x24. Mirror still copies the output to stdout.
+	Headless bool
+	Mirror   bool
+	// MaxDuration and MaxSize end the cast file once its recorded time or
+	// size goes over them. With Rotate the recording continues in a new
+	// file (demo.2.cast, demo.3.cast, ...); otherwise the session is hung
+	// up. A file may go over MaxSize by one event.
+	MaxDuration time.Duration
+	MaxSize     int64
+	Rotate      bool
+	// Raw writes the output exactly as the PTY produced it to the
+	// recording file instead of a cast, without framing or timestamps
+	Raw bool
+	// Transports ships events to external collectors as they happen (see
+	// ParseTransport for the accepted URLs)
+	Transports []string
+	// FlushInterval is how often buffered events are written to the cast
+	// file, and Fsync also commits them to disk each time
+	FlushInterval time.Duration
+	Fsync         bool
 }
 
+// Secret scanning modes
+const (
+	SecretScanMask = "mask" // replace secrets in recorded output
+	SecretScanWarn = "warn" // record output as-is, with a warning marker
+)
+
+// Notification marker modes
+const (
+	NotifyMarkersOSC = "osc" // OSC 9/777 desktop notifications only
+	NotifyMarkersAll = "all" // notifications and terminal bells
+)
+
+// resizeDebounce is how long the window size must be stable before a
+// resize is applied and recorded
+const resizeDebounce = 100 * time.Millisecond
+
 // Recorder handles terminal recording
 type Recorder struct {
 	options   Options
 	writer    *asciicast.Writer
+	raw       *rawWriter // the recording file in raw mode, instead of writer
+	header    asciicast.Header
+	files     []string       // cast files written, more than one when rotated
+	partStart float64        // event time at which the current file starts
+	rotating  bool           // the next event starts a new file
+	closing   sync.WaitGroup // files being finished in the background
+	writeErrs []error        // cast file failures, reported at the end
+	stopped   string         // the limit that ended the recording, if any
+	session   *os.Process    // the recorded command
+	audio     *audioRecorder // narration being recorded, if any
+	heldBack  string         // output that may start a secret, see recordOutput
+	oscTail   string         // output that may start a notification
 	startTime time.Time
+	pausedAt  time.Time     // zero unless the clock is paused
+	paused    time.Duration // total time spent paused
+	muted     bool          // paused from the keyboard: nothing is recorded
+	lastEvent float64       // time of the last event written
+	idleCut   float64       // idle time left out by IdleTimeLimit
+	chars     int           // characters of output recorded
+	sinks     []*asyncSink  // secondary outputs besides the cast file
+	exitCode  int
 	mu        sync.Mutex
 }
 
@@ -46,7 +134,14 @@ func New(options Options) *Recorder {
 func (r *Recorder) Record(filename string) error {
 	// Get terminal size
 	cols, rows := r.options.Cols, r.options.Rows
-	if cols == 0 || rows == 0 {
+	if r.options.Headless {
+		if cols == 0 {
+			cols = 80
+		}
+		if rows == 0 {
+			rows = 24
+		}
+	} else if cols == 0 || rows == 0 {
 		var err error
 		cols, rows, err = ttypkg.GetSize(ttypkg.GetStdoutFd())
 		if err != nil {
@@ -57,36 +152,88 @@ func (r *Recorder) Record(filename string) error {
 	// Create header
 	header := asciicast.NewHeader(cols, rows)
 	header.Title = r.options.Title
+	header.Series = r.options.Series
 	header.IdleTimeLimit = r.options.IdleTimeLimit
 	header.Command = r.options.Command
+	if len(r.options.Args) > 0 {
+		header.Command = strings.Join(r.options.Args, " ")
+	}
+	header.Audio = r.options.Audio
+	header.Timezone = asciicast.LocalTimezone()
 
 	// Set environment
-	header.Env = map[string]string{
-		"SHELL": os.Getenv("SHELL"),
-		"TERM":  os.Getenv("TERM"),
+	envRules := r.options.Env
+	if envRules == nil {
+		envRules = []string{"SHELL", "TERM"}
+	}
+	header.Env = captureEnv(envRules)
+	if r.options.CaptureEnvExtended {
+		captureTerminalEnv(header.Env)
+	}
+	if r.options.CaptureTheme && !r.options.Headless {
+		header.Theme = captureTheme()
 	}
 
-	// Create writer
-	writer, err := asciicast.NewWriter(filename, header, r.options.Append)
-	if err != nil {
-		return fmt.Errorf("failed to create writer: %w", err)
+	// Create writer. In raw mode the output bytes go straight to the file
+	// instead, and events only reach the secondary sinks.
+	if r.options.Raw {
+		raw, err := newRawWriter(filename, r.options.Append)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := raw.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to finish recording: %v\n", err)
+			}
+		}()
+		r.raw = raw
+	} else {
+		r.header = header
+		if err := r.openWriter(filename, header, r.options.Append); err != nil {
+			return err
+		}
+		// Closed last, once the terminal is restored, so errors can be reported
+		defer func() {
+			r.mu.Lock()
+			if r.writer != nil {
+				r.closeWriter(r.writer)
+				r.writer = nil
+			}
+			r.rotating = false
+			r.mu.Unlock()
+			r.closing.Wait()
+			for _, err := range r.writeErrs {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			if r.stopped != "" {
+				fmt.Fprintf(os.Stderr, "Recording stopped at the %s\n", r.stopped)
+			}
+		}()
 	}
-	defer writer.Close()
 
-	r.writer = writer
+	// Secondary sinks are closed after the terminal is restored, so their
+	// failures can be reported normally
+	r.openSinks(header)
+	defer r.closeSinks()
 
-	// Determine shell/command to run
-	shell := r.options.Command
-	if shell == "" {
-		shell = os.Getenv("SHELL")
+	// Create command. A custom command line runs through sh -c like in
+	// asciinema, so it can have arguments, quoting and pipes; without one
+	// the user's shell runs directly.
+	var cmd *exec.Cmd
+	switch {
+	case len(r.options.Args) > 0:
+		cmd = exec.Command(r.options.Args[0], r.options.Args[1:]...)
+	case r.options.Command != "":
+		cmd = exec.Command("/bin/sh", "-c", r.options.Command)
+	default:
+		shell := os.Getenv("SHELL")
 		if shell == "" {
 			shell = "/bin/sh"
 		}
+		cmd = exec.Command(shell)
 	}
-
-	// Create command
-	cmd := exec.Command(shell)
-	cmd.Env = append(os.Environ(), "GOASCIINEMA_REC=1")
+	// GOASCIINEMA_PID lets scripts in the session signal the recorder
+	cmd.Env = append(os.Environ(), "GOASCIINEMA_REC=1", fmt.Sprintf("GOASCIINEMA_PID=%d", os.Getpid()))
 
 	// Start PTY
 	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{
@@ -98,69 +245,196 @@ func (r *Recorder) Record(filename string) error {
 	}
 	defer ptmx.Close()
 
-	// Set up raw mode on stdin
-	restore, err := ttypkg.RawMode(ttypkg.GetStdinFd())
-	if err != nil {
-		return fmt.Errorf("failed to set raw mode: %w", err)
+	r.mu.Lock()
+	r.session = cmd.Process
+	r.startTime = time.Now()
+	r.mu.Unlock()
+
+	if r.options.MaxDuration > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go r.watchDuration(done)
 	}
-	defer restore()
 
-	// Handle window size changes
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGWINCH)
+	// Audio is stopped after the terminal is restored, so failures can be
+	// reported normally
+	if r.options.Audio != "" {
+		command := r.options.AudioCommand
+		if command == "" {
+			command = DefaultAudioCommand()
+		}
+		audio, err := startAudio(command, r.options.Audio)
+		if err != nil {
+			return err
+		}
+		r.writeMarker("audio start")
+		r.mu.Lock()
+		r.audio = audio
+		r.mu.Unlock()
+
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			r.syncAudio(audio, done)
+			close(stopped)
+		}()
+		defer func() {
+			close(done)
+			<-stopped
+			r.mu.Lock()
+			r.audio = nil
+			r.mu.Unlock()
+			if err := audio.stop(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}()
+	}
+
+	if r.options.Headless {
+		// Without a terminal, interrupts meant for the session reach the
+		// recorder; pass them on so the recording still ends cleanly
+		stopCh := make(chan os.Signal, 1)
+		signal.Notify(stopCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		go func() {
+			for sig := range stopCh {
+				cmd.Process.Signal(sig)
+			}
+		}()
+		defer func() {
+			signal.Stop(stopCh)
+			close(stopCh)
+		}()
+	} else {
+		// Set up raw mode on stdin
+		restore, err := ttypkg.RawMode(ttypkg.GetStdinFd())
+		if err != nil {
+			return fmt.Errorf("failed to set raw mode (use --headless without a terminal): %w", err)
+		}
+		defer restore()
+	}
+
+	// Handle window size changes. Drag-resizing sends a burst of SIGWINCH,
+	// so only apply and record the size once it has settled. Headless
+	// recordings keep their size.
+	if !r.options.Headless {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGWINCH)
+		go func() {
+			var settled <-chan time.Time
+			for {
+				select {
+				case _, ok := <-sigCh:
+					if !ok {
+						return
+					}
+					settled = time.After(resizeDebounce)
+				case <-settled:
+					settled = nil
+					if newCols, newRows, err := ttypkg.GetSize(ttypkg.GetStdoutFd()); err == nil {
+						pty.Setsize(ptmx, &pty.Winsize{
+							Rows: uint16(newRows),
+							Cols: uint16(newCols),
+						})
+						r.writeResize(newCols, newRows)
+					}
+				}
+			}
+		}()
+		defer func() {
+			signal.Stop(sigCh)
+			close(sigCh) // Close channel to unblock the goroutine
+		}()
+	}
+
+	// Scripts in the session add markers with SIGUSR1 and pause or resume
+	// with SIGUSR2
+	usrCh := make(chan os.Signal, 1)
+	signal.Notify(usrCh, syscall.SIGUSR1, syscall.SIGUSR2)
 	go func() {
-		for range sigCh {
-			if newCols, newRows, err := ttypkg.GetSize(ttypkg.GetStdoutFd()); err == nil {
-				pty.Setsize(ptmx, &pty.Winsize{
-					Rows: uint16(newRows),
-					Cols: uint16(newCols),
-				})
-				r.writeResize(newCols, newRows)
+		for sig := range usrCh {
+			if sig == syscall.SIGUSR1 {
+				r.writeMarker("")
+			} else {
+				r.togglePause()
 			}
 		}
 	}()
 	defer func() {
-		signal.Stop(sigCh)
-		close(sigCh) // Close channel to unblock the goroutine
+		signal.Stop(usrCh)
+		close(usrCh)
 	}()
 
-	r.startTime = time.Now()
+	if r.options.PauseOnLock {
+		done := make(chan struct{})
+		defer close(done)
+		go r.pauseOnLock(watchScreenLock(done))
+	}
 
-	// Create a pipe to make stdin reading interruptible
-	stdinReader, stdinWriter, err := os.Pipe()
-	if err != nil {
-		return fmt.Errorf("failed to create pipe: %w", err)
+	if r.options.Indicator != "" && !r.options.Headless {
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			r.runIndicator(done)
+			close(stopped)
+		}()
+		defer func() {
+			close(done)
+			<-stopped
+		}()
 	}
-	defer stdinReader.Close()
-	defer stdinWriter.Close() // Close write side to unblock any pending reads
 
-	// Goroutine to copy from real stdin to the pipe
-	go func() {
-		io.Copy(stdinWriter, os.Stdin)
-	}()
+	// Headless recordings have no keyboard input
+	if !r.options.Headless {
+		// Create a pipe to make stdin reading interruptible
+		stdinReader, stdinWriter, err := os.Pipe()
+		if err != nil {
+			return fmt.Errorf("failed to create pipe: %w", err)
+		}
+		defer stdinReader.Close()
+		defer stdinWriter.Close() // Close write side to unblock any pending reads
 
-	// Copy from pipe to pty (interruptible by closing stdinReader)
-	go func() {
-		buf := make([]byte, 4096)
-		for {
-			n, err := stdinReader.Read(buf)
-			if err != nil {
-				return
-			}
-			if n > 0 {
-				data := buf[:n]
-				if _, err := ptmx.Write(data); err != nil {
-					return // PTY closed
+		// Goroutine to copy from real stdin to the pipe. The input is stopped
+		// when recording ends, so it doesn't swallow what is typed afterwards.
+		input, stopInput := ttypkg.OpenInput()
+		defer stopInput()
+		go func() {
+			io.Copy(stdinWriter, input)
+		}()
+
+		// Copy from pipe to pty (interruptible by closing stdinReader)
+		go func() {
+			buf := make([]byte, 4096)
+			var pending []byte // a character split between reads
+			var shortcuts shortcutState
+			for {
+				n, err := stdinReader.Read(buf)
+				if err != nil {
+					return
 				}
-				if r.options.RecordStdin {
-					r.writeInput(string(data))
+				if n > 0 {
+					data := r.shortcuts(buf[:n], &shortcuts)
+					if len(data) == 0 {
+						continue
+					}
+					if _, err := ptmx.Write(data); err != nil {
+						return // PTY closed
+					}
+					if r.options.RecordStdin {
+						var text string
+						text, pending = asciicast.SplitUTF8(append(pending, data...))
+						if text != "" {
+							r.writeInput(text)
+						}
+					}
 				}
 			}
-		}
-	}()
+		}()
+	}
 
-	// Copy pty output to stdout and record
+	// Copy pty output to stdout and record. Reads can end mid-character,
+	// so an incomplete one is held back until the rest arrives.
 	buf := make([]byte, 32768)
+	var pending []byte
 	for {
 		n, err := ptmx.Read(buf)
 		if err != nil {
@@ -172,35 +446,399 @@ func (r *Recorder) Record(filename string) error {
 		}
 		if n > 0 {
 			data := buf[:n]
-			os.Stdout.Write(data)
-			r.writeOutput(string(data))
+			if !r.options.Headless || r.options.Mirror {
+				os.Stdout.Write(data)
+			}
+			r.writeRaw(data)
+			var text string
+			text, pending = asciicast.SplitUTF8(append(pending, data...))
+			if text == "" {
+				continue
+			}
+			r.recordOutput(text)
+			if r.options.NotifyMarkers != "" {
+				r.markNotifications(text)
+			}
 		}
 	}
+	if len(pending) > 0 {
+		r.recordOutput(strings.ToValidUTF8(string(pending), string(utf8.RuneError)))
+	}
+	r.flushOutput()
 
-	// Wait for command to finish
-	cmd.Wait()
+	// Wait for command to finish, and record how it did
+	r.exitCode = exitCode(cmd.Wait())
+	r.writeExit(r.exitCode)
 
 	return nil
 }
 
+// Files returns the cast files written once Record has returned: the
+// recording file, followed by any parts it was rotated into
+func (r *Recorder) Files() []string {
+	return r.files
+}
+
+// ExitCode returns the exit status of the recorded command once Record
+// has returned
+func (r *Recorder) ExitCode() int {
+	return r.exitCode
+}
+
+// exitCode converts the result of waiting for a command into a shell-style
+// exit status, 128+N for a command killed by signal N
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// openSinks starts the configured secondary outputs. A sink that cannot
+// be opened is reported and skipped.
+func (r *Recorder) openSinks(header asciicast.Header) {
+	if r.options.RawLog != "" {
+		if sink, err := newRawLogSink(r.options.RawLog); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			r.sinks = append(r.sinks, newAsyncSink("raw log", sink))
+		}
+	}
+	if r.options.Relay != "" {
+		if sink, err := dialRelay(r.options.Relay, header); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			r.sinks = append(r.sinks, newAsyncSink("relay "+r.options.Relay, sink))
+		}
+	}
+	for _, transport := range r.options.Transports {
+		if sink, err := openTransport(transport, header); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			r.sinks = append(r.sinks, newAsyncSink("transport "+transport, sink))
+		}
+	}
+}
+
+// closeSinks flushes and closes the secondary outputs
+func (r *Recorder) closeSinks() {
+	r.mu.Lock()
+	sinks := r.sinks
+	r.sinks = nil
+	r.mu.Unlock()
+
+	for _, sink := range sinks {
+		if err := sink.close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// captureTerminalEnv adds a snapshot of terminal capabilities to env
+func captureTerminalEnv(env map[string]string) {
+	for _, name := range []string{"COLORTERM", "LANG", "LC_ALL", "TERMINFO", "TERM_PROGRAM"} {
+		if value := os.Getenv(name); value != "" {
+			env[name] = value
+		}
+	}
+	if ttypkg.SupportsTruecolor() {
+		env["TRUECOLOR"] = "1"
+	} else {
+		env["TRUECOLOR"] = "0"
+	}
+}
+
+// themeQueryTimeout bounds how long the terminal has to report its colors
+const themeQueryTimeout = 500 * time.Millisecond
+
+// captureTheme queries the terminal's colors, returning nil if it is not a
+// terminal or does not report them
+func captureTheme() *asciicast.Theme {
+	in, out := ttypkg.GetStdinFd(), ttypkg.GetStdoutFd()
+	if !ttypkg.IsTerminal(in) || !ttypkg.IsTerminal(out) {
+		return nil
+	}
+	restore, err := ttypkg.RawMode(in)
+	if err != nil {
+		return nil
+	}
+	colors, err := ttypkg.QueryColors(in, out, themeQueryTimeout)
+	restore()
+	if err != nil || colors.Foreground == "" || colors.Background == "" {
+		return nil
+	}
+	return &asciicast.Theme{
+		Foreground: colors.Foreground,
+		Background: colors.Background,
+		Palette:    strings.Join(colors.Palette, ":"),
+	}
+}
+
+// elapsedTime returns the recording clock, which excludes paused time.
+// Callers must hold r.mu.
 func (r *Recorder) elapsedTime() float64 {
-	return time.Since(r.startTime).Seconds()
+	now := time.Now()
+	if !r.pausedAt.IsZero() {
+		now = r.pausedAt
+	}
+	return (now.Sub(r.startTime) - r.paused).Seconds()
+}
+
+// eventTime returns the time for the next event: the recording clock,
+// with idle gaps longer than IdleTimeLimit shortened to the limit. Callers
+// must hold r.mu.
+func (r *Recorder) eventTime() float64 {
+	t := r.elapsedTime() - r.idleCut
+	if limit := r.options.IdleTimeLimit; limit > 0 && t-r.lastEvent > limit {
+		r.idleCut += t - r.lastEvent - limit
+		t = r.lastEvent + limit
+	}
+	r.lastEvent = max(r.lastEvent, t)
+	return t
+}
+
+// pauseOnLock pauses the clock while the screen is locked
+func (r *Recorder) pauseOnLock(events <-chan lockEvent) {
+	for event := range events {
+		if event.Locked {
+			r.pause(event.Reason)
+		} else {
+			r.resume()
+		}
+	}
+}
+
+// pause stops the recording clock and marks where it stopped
+func (r *Recorder) pause(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopClock(reason)
+}
+
+// resume restarts the recording clock, unless recording was paused from
+// the keyboard
+func (r *Recorder) resume() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.muted {
+		r.startClock()
+	}
+}
+
+// togglePause pauses or resumes recording from the keyboard. While paused
+// the clock is stopped and output and input aren't recorded, so the pause
+// leaves no gap in the recording.
+func (r *Recorder) togglePause() {
+	r.mu.Lock()
+	if r.muted {
+		r.muted = false
+		r.startClock()
+	} else {
+		r.stopClock("by request")
+		r.muted = true
+	}
+	r.mu.Unlock()
+
+	if r.options.Indicator != "" && !r.options.Headless {
+		r.drawIndicator()
+	}
+}
+
+// stopClock stops the recording clock with a marker saying why. Callers
+// must hold r.mu.
+func (r *Recorder) stopClock(reason string) {
+	if !r.pausedAt.IsZero() {
+		return
+	}
+	r.emit(asciicast.EventTypeMarker, "paused: "+reason)
+	r.pausedAt = time.Now()
+}
+
+// startClock restarts the recording clock. Callers must hold r.mu.
+func (r *Recorder) startClock() {
+	if r.pausedAt.IsZero() {
+		return
+	}
+	r.paused += time.Since(r.pausedAt)
+	r.pausedAt = time.Time{}
+	// The audio kept going while the clock was stopped
+	r.markAudio()
+}
+
+// syncAudio writes a marker with the audio position every
+// audioSyncInterval, so narration can be aligned even when the recording
+// clock was paused
+func (r *Recorder) syncAudio(audio *audioRecorder, done <-chan struct{}) {
+	ticker := time.NewTicker(audioSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			r.markAudio()
+			r.mu.Unlock()
+		}
+	}
+}
+
+// markAudio writes an "audio sync <position>" marker pairing the
+// recording clock with the audio position, when audio is being recorded.
+// The marker leaves the idle time bookkeeping alone, so it neither counts
+// as activity nor has its gap shortened. Callers must hold r.mu.
+func (r *Recorder) markAudio() {
+	if r.audio == nil {
+		return
+	}
+	r.write(asciicast.Event{
+		Time: r.recordedTime(),
+		Type: asciicast.EventTypeMarker,
+		Data: fmt.Sprintf("audio sync %.3f", r.audio.elapsed()),
+	})
+}
+
+// emit writes an event to the cast file and queues it for the secondary
+// sinks. Callers must hold r.mu.
+func (r *Recorder) emit(eventType, data string) {
+	idleCut := r.idleCut
+	event := asciicast.Event{Time: r.eventTime(), Type: eventType, Data: data}
+	if r.idleCut > idleCut {
+		// Audio from the idle time that was cut is skipped
+		r.markAudio()
+	}
+	r.write(event)
+}
+
+// write writes an event at its given time to the cast file and the
+// secondary sinks. Callers must hold r.mu.
+func (r *Recorder) write(event asciicast.Event) {
+	if r.writer != nil {
+		if limit := r.limitReached(event.Time); limit != "" {
+			r.finishPart(limit)
+		}
+	}
+	if r.rotating {
+		r.startPart(event.Time)
+	}
+	if r.writer != nil {
+		part := event
+		part.Time -= r.partStart
+		r.writer.WriteEvent(part)
+	}
+	for _, sink := range r.sinks {
+		sink.send(event)
+	}
 }
 
 func (r *Recorder) writeOutput(data string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.writer.WriteOutput(r.elapsedTime(), data)
+	if r.muted {
+		return
+	}
+	r.chars += utf8.RuneCountInString(data)
+	r.emit(asciicast.EventTypeOutput, data)
+}
+
+// writeRaw writes PTY output to the recording file in raw mode
+func (r *Recorder) writeRaw(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.raw == nil || r.muted {
+		return
+	}
+	r.raw.Write(data)
 }
 
 func (r *Recorder) writeInput(data string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.writer.WriteInput(r.elapsedTime(), data)
+	if r.muted {
+		return
+	}
+	r.emit(asciicast.EventTypeInput, data)
+}
+
+// recordOutput writes an output event, scanning it for secrets first when
+// enabled. Output that may be the start of a secret is held back until
+// the next read completes it, so secrets split across reads are caught.
+func (r *Recorder) recordOutput(data string) {
+	if r.options.SecretScan == "" {
+		r.writeOutput(data)
+		return
+	}
+	data = r.heldBack + data
+	cut := sanitize.IncompleteSecret(data)
+	r.heldBack = data[cut:]
+	if cut > 0 {
+		r.scanOutput(data[:cut])
+	}
+}
+
+// flushOutput writes output held back by recordOutput
+func (r *Recorder) flushOutput() {
+	if r.heldBack != "" {
+		r.scanOutput(r.heldBack)
+		r.heldBack = ""
+	}
+}
+
+// scanOutput writes an output event according to the secret scan mode
+func (r *Recorder) scanOutput(data string) {
+	switch r.options.SecretScan {
+	case SecretScanMask:
+		masked, secrets := sanitize.MaskSecrets(data)
+		r.writeOutput(masked)
+		for _, s := range secrets {
+			r.writeMarker("secret masked: " + s.Kind)
+		}
+	case SecretScanWarn:
+		r.writeOutput(data)
+		for _, s := range sanitize.FindSecrets(data) {
+			r.writeMarker("secret detected: " + s.Kind)
+		}
+	default:
+		r.writeOutput(data)
+	}
+}
+
+// markNotifications writes marker events for bells and desktop
+// notifications. A notification split across reads is held back until
+// the read that completes it.
+func (r *Recorder) markNotifications(data string) {
+	data = r.oscTail + data
+	cut := sanitize.IncompleteNotification(data)
+	r.oscTail = data[cut:]
+	for _, n := range sanitize.FindNotifications(data[:cut]) {
+		if n.Kind == sanitize.NotifyBell && r.options.NotifyMarkers != NotifyMarkersAll {
+			continue
+		}
+		r.writeMarker(n.Message)
+	}
+}
+
+func (r *Recorder) writeMarker(label string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emit(asciicast.EventTypeMarker, label)
+}
+
+func (r *Recorder) writeExit(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emit(asciicast.EventTypeExit, strconv.Itoa(code))
 }
 
 func (r *Recorder) writeResize(cols, rows int) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.writer.WriteResize(r.elapsedTime(), cols, rows)
+	r.header.Width, r.header.Height = cols, rows
+	r.emit(asciicast.EventTypeResize, fmt.Sprintf("%dx%d", cols, rows))
 }
@@ -0,0 +1,101 @@
+package recorder
+
+import (
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	ttypkg "github.com/ober/goasciinema/internal/tty"
+)
+
+// prefixKey starts a recorder shortcut: Ctrl+\ followed by a command key.
+// Pressing it twice sends a single Ctrl+\ on to the recorded program.
+const prefixKey = 0x1c
+
+// shortcutState is what the keyboard input handled so far leaves pending
+type shortcutState struct {
+	prefixed bool   // the prefix key was pressed
+	labeling bool   // a marker label is being typed
+	label    []byte // the label typed so far
+}
+
+// shortcuts handles recorder shortcuts in keyboard input and returns the
+// rest, to pass on to the recorded program. A prefix followed by a key
+// that isn't a shortcut is passed on unchanged. While a marker label is
+// being typed, all input goes to the label.
+func (r *Recorder) shortcuts(data []byte, state *shortcutState) []byte {
+	out := make([]byte, 0, len(data))
+	for _, b := range data {
+		switch {
+		case state.labeling:
+			r.labelKey(state, b)
+		case state.prefixed:
+			state.prefixed = false
+			switch b {
+			case 'p':
+				r.togglePause()
+			case 'm':
+				r.writeMarker("")
+			case 'M':
+				state.labeling = true
+				state.label = state.label[:0]
+				drawPrompt(state.label)
+			case prefixKey:
+				out = append(out, prefixKey)
+			default:
+				out = append(out, prefixKey, b)
+			}
+		case b == prefixKey:
+			state.prefixed = true
+		default:
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// labelKey edits the marker label being typed. Enter writes the marker,
+// and Escape or Ctrl+C drops it.
+func (r *Recorder) labelKey(state *shortcutState, b byte) {
+	switch b {
+	case '\r', '\n':
+		state.labeling = false
+		clearPrompt()
+		r.writeMarker(string(state.label))
+		return
+	case 0x1b, 0x03:
+		state.labeling = false
+		clearPrompt()
+		return
+	case 0x7f, 0x08:
+		if len(state.label) > 0 {
+			_, size := utf8.DecodeLastRune(state.label)
+			state.label = state.label[:len(state.label)-size]
+		}
+	default:
+		if b < 0x20 {
+			return
+		}
+		state.label = append(state.label, b)
+	}
+	drawPrompt(state.label)
+}
+
+// drawPrompt shows the marker label prompt on the bottom row of the
+// recording terminal. It goes to stderr, so it isn't recorded.
+func drawPrompt(label []byte) {
+	_, rows, err := ttypkg.GetSize(ttypkg.GetStdoutFd())
+	if err != nil || rows <= 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\x1b7\x1b[%d;1H\x1b[2K\x1b[7m Marker label (Enter to add, Esc to cancel): %s\x1b[0m\x1b8", rows, label)
+}
+
+// clearPrompt erases the marker label prompt
+func clearPrompt() {
+	_, rows, err := ttypkg.GetSize(ttypkg.GetStdoutFd())
+	if err != nil || rows <= 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\x1b7\x1b[%d;1H\x1b[2K\x1b8", rows)
+}
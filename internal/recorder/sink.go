@@ -0,0 +1,173 @@
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"time"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+)
+
+// sinkQueueSize is the number of events buffered for each secondary sink
+const sinkQueueSize = 4096
+
+// relayDialTimeout bounds how long connecting to a relay may delay the
+// start of a recording
+const relayDialTimeout = 5 * time.Second
+
+// Sink receives recorded events in addition to the cast file
+type Sink interface {
+	WriteEvent(event asciicast.Event) error
+	Close() error
+}
+
+// asyncSink feeds a secondary sink from its own goroutine so that a slow
+// or broken sink never stalls or corrupts the local recording. Events are
+// dropped while its queue is full, and the sink is abandoned after its
+// first error.
+type asyncSink struct {
+	name    string
+	sink    Sink
+	queue   chan asciicast.Event
+	done    chan struct{}
+	err     error
+	dropped int
+}
+
+func newAsyncSink(name string, sink Sink) *asyncSink {
+	s := &asyncSink{
+		name:  name,
+		sink:  sink,
+		queue: make(chan asciicast.Event, sinkQueueSize),
+		done:  make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *asyncSink) run() {
+	defer close(s.done)
+	for event := range s.queue {
+		if s.err != nil {
+			continue
+		}
+		if err := s.sink.WriteEvent(event); err != nil {
+			s.err = err
+		}
+	}
+}
+
+// send queues an event without blocking
+func (s *asyncSink) send(event asciicast.Event) {
+	select {
+	case s.queue <- event:
+	default:
+		s.dropped++
+	}
+}
+
+// close drains the queue, closes the sink, and returns the first problem
+// encountered
+func (s *asyncSink) close() error {
+	close(s.queue)
+	<-s.done
+	closeErr := s.sink.Close()
+
+	switch {
+	case s.err != nil:
+		return fmt.Errorf("%s failed: %w", s.name, s.err)
+	case s.dropped > 0:
+		return fmt.Errorf("%s fell behind, %d event(s) dropped", s.name, s.dropped)
+	case closeErr != nil:
+		return fmt.Errorf("%s failed: %w", s.name, closeErr)
+	}
+	return nil
+}
+
+// rawLogSink writes only the output stream, like script(1)
+type rawLogSink struct {
+	file *os.File
+}
+
+func newRawLogSink(path string) (*rawLogSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create raw log: %w", err)
+	}
+	return &rawLogSink{file: file}, nil
+}
+
+func (s *rawLogSink) WriteEvent(event asciicast.Event) error {
+	if event.Type != asciicast.EventTypeOutput {
+		return nil
+	}
+	_, err := s.file.WriteString(event.Data)
+	return err
+}
+
+func (s *rawLogSink) Close() error {
+	return s.file.Close()
+}
+
+// rawWriter writes the output bytes exactly as the PTY produced them. The
+// first write error is kept and returned by Close.
+type rawWriter struct {
+	file *os.File
+	err  error
+}
+
+func newRawWriter(path string, appendTo bool) (*rawWriter, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendTo {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create raw recording: %w", err)
+	}
+	return &rawWriter{file: file}, nil
+}
+
+func (w *rawWriter) Write(data []byte) {
+	if w.err == nil {
+		_, w.err = w.file.Write(data)
+	}
+}
+
+func (w *rawWriter) Close() error {
+	err := w.file.Close()
+	if w.err != nil {
+		return w.err
+	}
+	return err
+}
+
+// relaySink streams the recording as asciicast v2 lines over TCP
+type relaySink struct {
+	conn net.Conn
+	enc  *json.Encoder
+}
+
+func dialRelay(addr string, header asciicast.Header) (*relaySink, error) {
+	conn, err := net.DialTimeout("tcp", addr, relayDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to relay: %w", err)
+	}
+	s := &relaySink{conn: conn, enc: json.NewEncoder(conn)}
+	if err := s.enc.Encode(header); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send header to relay: %w", err)
+	}
+	return s, nil
+}
+
+func (s *relaySink) WriteEvent(event asciicast.Event) error {
+	return s.enc.Encode([]interface{}{math.Round(event.Time*1e6) / 1e6, event.Type, event.Value()})
+}
+
+func (s *relaySink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,262 @@
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/ober/goasciinema/internal/asciicast"
+)
+
+// Transport opens a sink that ships events to an external collector. The
+// header describes the recording being shipped.
+type Transport func(target *url.URL, header asciicast.Header) (Sink, error)
+
+// transports maps URL schemes to the transports that handle them
+var transports = map[string]Transport{
+	"tcp":        openTCPTransport,
+	"udp":        openUDPTransport,
+	"syslog":     openSyslogTransport,
+	"syslog+udp": openSyslogTransport,
+	"syslog+tcp": openSyslogTransport,
+	"kafka":      openKafkaTransport,
+}
+
+// RegisterTransport makes a transport available for URLs with the given
+// scheme, replacing any existing one
+func RegisterTransport(scheme string, transport Transport) {
+	transports[scheme] = transport
+}
+
+// TransportSchemes returns the supported transport URL schemes
+func TransportSchemes() []string {
+	var schemes []string
+	for scheme := range transports {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// ParseTransport checks that a transport URL is well formed and has a
+// supported scheme
+func ParseTransport(rawURL string) (*url.URL, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transport %q: %w", rawURL, err)
+	}
+	if _, ok := transports[target.Scheme]; !ok {
+		return nil, fmt.Errorf("invalid transport %q (expected one of %s)", rawURL, strings.Join(TransportSchemes(), ", "))
+	}
+	return target, nil
+}
+
+// openTransport opens the sink for a transport URL
+func openTransport(rawURL string, header asciicast.Header) (Sink, error) {
+	target, err := ParseTransport(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return transports[target.Scheme](target, header)
+}
+
+// maxMessageData bounds the event data carried by one message, since
+// datagrams and syslog messages are limited in size. Longer output is split
+// over several messages.
+const maxMessageData = 4096
+
+// transportMessage is one event as shipped to a message-based transport.
+// Each message stands alone, so it names its session and host.
+type transportMessage struct {
+	Session string            `json:"session"`
+	Host    string            `json:"host"`
+	User    string            `json:"user,omitempty"`
+	Time    float64           `json:"time"`
+	Type    string            `json:"type"`
+	Data    string            `json:"data,omitempty"`
+	Header  *asciicast.Header `json:"header,omitempty"`
+}
+
+// messageSink encodes events as JSON messages for a message-based transport
+type messageSink struct {
+	send    func(msg []byte) error
+	close   func() error
+	session string
+	host    string
+	user    string
+}
+
+// newMessageSink creates a sink and announces the recording with a
+// "start" message carrying its header
+func newMessageSink(header asciicast.Header, send func([]byte) error, close func() error) (*messageSink, error) {
+	host, _ := os.Hostname()
+	s := &messageSink{
+		send:    send,
+		close:   close,
+		session: uuid.NewString(),
+		host:    host,
+		user:    os.Getenv("USER"),
+	}
+	if err := s.write(transportMessage{Type: "start", Header: &header}); err != nil {
+		close()
+		return nil, fmt.Errorf("failed to send header: %w", err)
+	}
+	return s, nil
+}
+
+func (s *messageSink) write(msg transportMessage) error {
+	msg.Session = s.session
+	msg.Host = s.host
+	msg.User = s.user
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return s.send(data)
+}
+
+func (s *messageSink) WriteEvent(event asciicast.Event) error {
+	t := math.Round(event.Time*1e6) / 1e6
+	for _, chunk := range splitData(event.Data, maxMessageData) {
+		if err := s.write(transportMessage{Time: t, Type: event.Type, Data: chunk}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *messageSink) Close() error {
+	return s.close()
+}
+
+// splitData splits data into chunks of at most size bytes without
+// breaking UTF-8 sequences
+func splitData(data string, size int) []string {
+	if len(data) <= size {
+		return []string{data}
+	}
+	var chunks []string
+	for len(data) > size {
+		cut := size
+		for cut > 0 && !utf8.RuneStart(data[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			cut = size
+		}
+		chunks = append(chunks, data[:cut])
+		data = data[cut:]
+	}
+	if data != "" {
+		chunks = append(chunks, data)
+	}
+	return chunks
+}
+
+// openTCPTransport streams asciicast v2 lines, like --relay
+func openTCPTransport(target *url.URL, header asciicast.Header) (Sink, error) {
+	return dialRelay(target.Host, header)
+}
+
+// openUDPTransport sends one JSON message per datagram
+func openUDPTransport(target *url.URL, header asciicast.Header) (Sink, error) {
+	conn, err := net.DialTimeout("udp", target.Host, relayDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to UDP collector: %w", err)
+	}
+	return newMessageSink(header, func(msg []byte) error {
+		_, err := conn.Write(msg)
+		return err
+	}, conn.Close)
+}
+
+// openSyslogTransport sends one JSON message per syslog line. syslog: (no
+// host) uses the local syslog daemon; syslog://host:port and
+// syslog+udp://host:port use UDP, and syslog+tcp://host:port uses TCP.
+func openSyslogTransport(target *url.URL, header asciicast.Header) (Sink, error) {
+	network := "udp"
+	if target.Scheme == "syslog+tcp" {
+		network = "tcp"
+	}
+	addr := target.Host
+	if addr == "" {
+		network = ""
+	} else if target.Port() == "" {
+		addr = net.JoinHostPort(addr, "514")
+	}
+
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_AUTH, "goasciinema")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return newMessageSink(header, func(msg []byte) error {
+		return w.Info(string(msg))
+	}, w.Close)
+}
+
+// kafkaStopTimeout is how long kcat gets to deliver queued messages when
+// recording ends
+const kafkaStopTimeout = 10 * time.Second
+
+// openKafkaTransport produces one JSON message per event to
+// kafka://broker[,broker...]/topic through the kcat command-line producer
+func openKafkaTransport(target *url.URL, header asciicast.Header) (Sink, error) {
+	topic := strings.TrimPrefix(target.Path, "/")
+	if target.Host == "" || topic == "" {
+		return nil, fmt.Errorf("invalid kafka transport %q (expected kafka://broker/topic)", target.String())
+	}
+
+	producer := exec.Command("kcat", "-P", "-b", target.Host, "-t", topic)
+	stdin, err := producer.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start kafka producer: %w", err)
+	}
+	var stderr strings.Builder
+	producer.Stderr = &stderr
+	if err := producer.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start kafka producer: %w", err)
+	}
+
+	return newMessageSink(header, func(msg []byte) error {
+		_, err := stdin.Write(append(msg, '\n'))
+		return err
+	}, func() error {
+		return stopProducer(producer, stdin, &stderr)
+	})
+}
+
+// stopProducer closes the producer's input and waits for it to deliver
+// what it has queued
+func stopProducer(producer *exec.Cmd, stdin io.Closer, stderr *strings.Builder) error {
+	stdin.Close()
+	exited := make(chan error, 1)
+	go func() {
+		exited <- producer.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-exited:
+	case <-time.After(kafkaStopTimeout):
+		producer.Process.Kill()
+		err = <-exited
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("kafka producer failed: %v: %s", err, msg)
+		}
+		return fmt.Errorf("kafka producer failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+	"github.com/ober/goasciinema/internal/sanitize"
+)
+
+// Mask replaces each redacted secret
+const Mask = "****"
+
+// passwordPrompt matches output ending in a password prompt, after which
+// the typed input up to Enter is a password
+var passwordPrompt = regexp.MustCompile(`(?i)(?:\[sudo\] password for [^:\r\n]*|password(?: for [^:\r\n]*)?|passphrase(?: for [^:\r\n]*)?):\s*$`)
+
+// promptTail bounds how much recent output is kept to detect a prompt
+// that arrives over several events
+const promptTail = 256
+
+// Redactor replaces secrets in output and input events. The well-known
+// credential formats found by secret scanning are always redacted, along
+// with any extra patterns and passwords typed at password prompts.
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// New creates a redactor for the extra regular expressions in patterns
+func New(patterns []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// span is a byte range of a stream's text
+type span struct {
+	start, end int
+}
+
+// Redact rewrites events in place, without changing any timestamps, and
+// returns the number of secrets replaced. Output and input are each
+// searched as one continuous stream, so a secret split over several
+// events is still found; its mask goes in the event where it starts.
+// Events left empty are dropped.
+func (r *Redactor) Redact(events []asciicast.Event) ([]asciicast.Event, int) {
+	count := r.redactPasswords(events)
+	count += r.redactStream(events, asciicast.EventTypeOutput)
+	count += r.redactStream(events, asciicast.EventTypeInput)
+
+	kept := events[:0]
+	for _, event := range events {
+		if event.Data == "" && (event.Type == asciicast.EventTypeOutput || event.Type == asciicast.EventTypeInput) {
+			continue
+		}
+		kept = append(kept, event)
+	}
+	return kept, count
+}
+
+// redactStream redacts pattern matches in the events of one type
+func (r *Redactor) redactStream(events []asciicast.Event, eventType string) int {
+	var indexes []int
+	var bounds []int
+	var text strings.Builder
+	for i, event := range events {
+		if event.Type != eventType {
+			continue
+		}
+		indexes = append(indexes, i)
+		bounds = append(bounds, text.Len())
+		text.WriteString(event.Data)
+	}
+	bounds = append(bounds, text.Len())
+
+	matches := r.find(text.String())
+	if len(matches) == 0 {
+		return 0
+	}
+	for k, i := range indexes {
+		events[i].Data = rewrite(text.String(), bounds[k], bounds[k+1], matches)
+	}
+	return len(matches)
+}
+
+// find returns the secrets in text ordered by position, with overlapping
+// matches merged
+func (r *Redactor) find(text string) []span {
+	var found []span
+	for _, s := range sanitize.FindSecrets(text) {
+		found = append(found, span{s.Start, s.End})
+	}
+	for _, re := range r.patterns {
+		for _, m := range re.FindAllStringIndex(text, -1) {
+			if m[1] > m[0] {
+				found = append(found, span{m[0], m[1]})
+			}
+		}
+	}
+	if len(found) < 2 {
+		return found
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].start < found[j].start })
+	merged := found[:1]
+	for _, s := range found[1:] {
+		last := &merged[len(merged)-1]
+		if s.start < last.end {
+			if s.end > last.end {
+				last.end = s.end
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// rewrite returns the part of text between start and end with matches
+// masked. A match is masked where it starts and otherwise just removed.
+func rewrite(text string, start, end int, matches []span) string {
+	var out strings.Builder
+	pos := start
+	for _, m := range matches {
+		if m.end <= start || m.start >= end {
+			continue
+		}
+		if m.start > pos {
+			out.WriteString(text[pos:m.start])
+		}
+		if m.start >= start {
+			out.WriteString(Mask)
+		}
+		pos = min(m.end, end)
+	}
+	out.WriteString(text[pos:end])
+	return out.String()
+}
+
+// redactPasswords masks the input typed after a password prompt up to
+// Enter. Such input is only recorded with --stdin.
+func (r *Redactor) redactPasswords(events []asciicast.Event) int {
+	var tail string
+	var count int
+	typing, masked := false, false
+	for i := range events {
+		event := &events[i]
+		switch event.Type {
+		case asciicast.EventTypeOutput:
+			tail += event.Data
+			if len(tail) > promptTail {
+				tail = tail[len(tail)-promptTail:]
+			}
+			if passwordPrompt.MatchString(tail) {
+				typing, masked = true, false
+			}
+		case asciicast.EventTypeInput:
+			if !typing {
+				continue
+			}
+			password, rest, entered := event.Data, "", false
+			if n := strings.IndexAny(event.Data, "\r\n"); n >= 0 {
+				password, rest, entered = event.Data[:n], event.Data[n:], true
+			}
+			if password != "" && !masked {
+				event.Data = Mask + rest
+				masked = true
+				count++
+			} else {
+				event.Data = rest
+			}
+			if entered {
+				typing = false
+				tail = ""
+			}
+		}
+	}
+	return count
+}
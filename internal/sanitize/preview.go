@@ -0,0 +1,36 @@
+package sanitize
+
+import (
+	"regexp"
+	"strings"
+)
+
+// promptLine matches a shell prompt followed by a command, such as
+// "user@host:~$ make test" or "# apt update"
+var promptLine = regexp.MustCompile(`^\S*[$#%>❯]\s+(\S.*)$`)
+
+// previewScanLines limits how far into a session a prompt is looked for
+const previewScanLines = 50
+
+// Preview returns a one-line summary of cleaned session text: the first
+// command typed at a prompt if one can be found near the start, otherwise
+// the first non-empty line
+func Preview(text string) string {
+	var first string
+	for i, line := range strings.Split(text, "\n") {
+		if i >= previewScanLines {
+			break
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if m := promptLine.FindStringSubmatch(line); m != nil {
+			return strings.TrimSpace(m[1])
+		}
+		if first == "" {
+			first = line
+		}
+	}
+	return first
+}
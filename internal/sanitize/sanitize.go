@@ -59,3 +59,101 @@ func CleanLines(text string) string {
 	}
 	return strings.Join(out, "\n")
 }
+
+// oscNotify matches OSC 9 (iTerm2/ConEmu) and OSC 777 (urxvt/VTE) desktop
+// notification sequences, terminated by BEL or ST.
+var oscNotify = regexp.MustCompile(`\x1b\]((?:9|777);[^\x07\x1b]*)(?:\x07|\x1b\\)`)
+
+// Notification kinds returned by FindNotifications
+const (
+	NotifyBell = "bell"
+	NotifyOSC  = "osc"
+)
+
+// Notification is a terminal bell or desktop notification found in output
+type Notification struct {
+	Kind    string
+	Message string
+}
+
+// FindNotifications returns the bells and OSC 9/777 notifications in text,
+// in the order they appear. BEL characters that terminate OSC sequences are
+// not reported as bells.
+func FindNotifications(text string) []Notification {
+	var found []Notification
+	matches := oscNotify.FindAllStringSubmatchIndex(text, -1)
+
+	pos := 0
+	for _, m := range matches {
+		found = append(found, findBells(text[pos:m[0]])...)
+		found = append(found, Notification{Kind: NotifyOSC, Message: oscMessage(text[m[2]:m[3]])})
+		pos = m[1]
+	}
+	found = append(found, findBells(text[pos:])...)
+
+	return found
+}
+
+// maxNotificationTail bounds how much unterminated OSC sequence
+// IncompleteNotification holds back, in case it is never terminated
+const maxNotificationTail = 4096
+
+// IncompleteNotification returns the offset of an OSC sequence that may be
+// terminated past the end of text, such as a notification split between
+// two reads of a stream, or len(text) if there is none. Scanning
+// everything before the offset and carrying the rest over to the next
+// chunk keeps split notifications whole, and stops the BEL that ends one
+// from being reported as a bell.
+func IncompleteNotification(text string) int {
+	if start := strings.LastIndex(text, "\x1b]"); start >= 0 && len(text)-start <= maxNotificationTail {
+		rest := text[start+2:]
+		i := strings.IndexAny(rest, "\x07\x1b")
+		if i < 0 || (rest[i] == 0x1b && i == len(rest)-1) {
+			// Not terminated yet, or only the ESC of an ST seen so far
+			return start
+		}
+	}
+	if strings.HasSuffix(text, "\x1b") {
+		// Possibly the start of an OSC sequence
+		return len(text) - 1
+	}
+	return len(text)
+}
+
+// findBells reports bare BEL characters, skipping those that end other OSC sequences
+func findBells(text string) []Notification {
+	var found []Notification
+	inOSC := false
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == 0x1b && i+1 < len(text) && text[i+1] == ']':
+			inOSC = true
+			i++
+		case text[i] == 0x07:
+			if inOSC {
+				inOSC = false
+			} else {
+				found = append(found, Notification{Kind: NotifyBell, Message: "bell"})
+			}
+		case text[i] == 0x1b && inOSC:
+			inOSC = false
+		}
+	}
+	return found
+}
+
+// oscMessage extracts a readable label from an OSC 9 or 777 payload
+func oscMessage(payload string) string {
+	parts := strings.SplitN(payload, ";", 4)
+	if parts[0] == "777" {
+		// 777;notify;title;body
+		if len(parts) == 4 {
+			if parts[3] == "" {
+				return parts[2]
+			}
+			return parts[2] + ": " + parts[3]
+		}
+		return strings.Join(parts[1:], ";")
+	}
+	return strings.TrimPrefix(payload, "9;")
+}
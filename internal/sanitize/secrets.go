@@ -0,0 +1,142 @@
+package sanitize
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// secretPattern is a named pattern for a kind of secret. Prefixes are
+// the literal starts of a match and body the characters that follow, so a
+// secret cut off at the end of a chunk of output can be recognized.
+type secretPattern struct {
+	kind     string
+	re       *regexp.Regexp
+	prefixes []string
+	body     string
+}
+
+// Characters secret bodies are made of
+const (
+	upperDigits = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	alnum       = upperDigits + "abcdefghijklmnopqrstuvwxyz"
+)
+
+// secretPatterns lists well-known credential formats. Patterns are kept
+// specific to avoid masking ordinary output.
+var secretPatterns = []secretPattern{
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+		[]string{"AKIA", "ASIA"}, upperDigits},
+	{"github-token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,255}\b`),
+		[]string{"ghp_", "gho_", "ghu_", "ghs_", "ghr_"}, alnum},
+	{"github-pat", regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,255}\b`),
+		[]string{"github_pat_"}, alnum + "_"},
+	{"gitlab-token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_\-]{20,}\b`),
+		[]string{"glpat-"}, alnum + "_-"},
+	{"slack-token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`),
+		[]string{"xoxa-", "xoxb-", "xoxp-", "xoxo-", "xoxs-", "xoxr-"}, alnum + "-"},
+	{"stripe-key", regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{20,}\b`),
+		[]string{"sk_live_", "rk_live_"}, alnum},
+	{"google-api-key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`),
+		[]string{"AIza"}, alnum + "_-"},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{10,}\.eyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}\b`),
+		[]string{"eyJ"}, alnum + "_-."},
+	{"private-key", regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )?PRIVATE KEY-----`),
+		[]string{"-----BEGIN "}, upperDigits + " -"},
+}
+
+// maxSecretTail bounds how much text IncompleteSecret holds back; longer
+// secrets are only masked up to this length
+const maxSecretTail = 1024
+
+// Secret is a secret found in text
+type Secret struct {
+	Kind  string
+	Start int // byte offset of the match
+	End   int
+}
+
+// FindSecrets returns the secrets found in text ordered by position.
+// Overlapping matches are reported once.
+func FindSecrets(text string) []Secret {
+	var found []Secret
+	for _, p := range secretPatterns {
+		for _, m := range p.re.FindAllStringIndex(text, -1) {
+			found = append(found, Secret{Kind: p.kind, Start: m[0], End: m[1]})
+		}
+	}
+	if len(found) < 2 {
+		return found
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Start < found[j].Start })
+	merged := found[:1]
+	for _, s := range found[1:] {
+		last := &merged[len(merged)-1]
+		if s.Start < last.End {
+			if s.End > last.End {
+				last.End = s.End
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// MaskSecrets replaces each secret in text with a "[REDACTED:<kind>]"
+// placeholder and returns the masked text and the secrets found
+func MaskSecrets(text string) (string, []Secret) {
+	secrets := FindSecrets(text)
+	if len(secrets) == 0 {
+		return text, nil
+	}
+
+	var out []byte
+	pos := 0
+	for _, s := range secrets {
+		out = append(out, text[pos:s.Start]...)
+		out = append(out, "[REDACTED:"+s.Kind+"]"...)
+		pos = s.End
+	}
+	out = append(out, text[pos:]...)
+	return string(out), secrets
+}
+
+// IncompleteSecret returns the offset of a secret that may continue past
+// the end of text, such as a token split between two reads of a stream,
+// or len(text) if there is none. Scanning everything before the offset and
+// carrying the rest over to the next chunk keeps split secrets whole.
+func IncompleteSecret(text string) int {
+	for i := max(0, len(text)-maxSecretTail); i < len(text); i++ {
+		if couldStartSecret(text[:i], text[i:]) {
+			return i
+		}
+	}
+	return len(text)
+}
+
+// couldStartSecret reports whether tail, following before, is the start
+// of a secret whose end hasn't been seen yet
+func couldStartSecret(before, tail string) bool {
+	for _, p := range secretPatterns {
+		for _, prefix := range p.prefixes {
+			if isWordByte(prefix[0]) && before != "" && isWordByte(before[len(before)-1]) {
+				break
+			}
+			if len(tail) <= len(prefix) {
+				if strings.HasPrefix(prefix, tail) {
+					return true
+				}
+			} else if strings.HasPrefix(tail, prefix) && strings.Trim(tail[len(prefix):], p.body) == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isWordByte reports whether c is a regexp word character (\w)
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
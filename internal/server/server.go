@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ober/goasciinema/internal/asciicast"
+)
+
+// Options configures the server
+type Options struct {
+	Dir string // directory recordings are served from
+}
+
+// Server serves stored recordings over HTTP
+type Server struct {
+	options Options
+	mux     *http.ServeMux
+}
+
+// New creates a new server
+func New(options Options) *Server {
+	if options.Dir == "" {
+		options.Dir = "."
+	}
+	s := &Server{
+		options: options,
+		mux:     http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/stream/", s.handleStream)
+	s.mux.HandleFunc("/play/", s.handlePlay)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe starts serving on addr
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+// resolve maps a request path to a file inside the served directory
+func (s *Server) resolve(name string) (string, error) {
+	name = filepath.Clean("/" + name)
+	if name == "/" {
+		return "", fmt.Errorf("no recording specified")
+	}
+	path := filepath.Join(s.options.Dir, name)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", fmt.Errorf("recording not found")
+	}
+	return path, nil
+}
+
+// handleStream replays a recording with original timing, as server-sent
+// events or, when the request asks to upgrade, over a WebSocket. The speed
+// query parameter scales playback, and from skips ahead: events before
+// that offset are sent immediately.
+//
+// With server-sent events the header is sent as a "header" event, each
+// recording event as a default "message" event holding the asciicast v2
+// event array, and an "end" event once the recording is finished. Over a
+// WebSocket the first text message is the header, each following one an
+// event array, and the connection is closed normally at the end.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	path, err := s.resolve(strings.TrimPrefix(r.URL.Path, "/stream/"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	speed := 1.0
+	if v := r.URL.Query().Get("speed"); v != "" {
+		speed, err = strconv.ParseFloat(v, 64)
+		if err != nil || speed <= 0 {
+			http.Error(w, "invalid speed", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var from float64
+	if v := r.URL.Query().Get("from"); v != "" {
+		from, err = strconv.ParseFloat(v, 64)
+		if err != nil || from < 0 {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+	}
+
+	websocket := isWebSocket(r)
+	flusher, ok := w.(http.Flusher)
+	if !websocket && !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	reader, err := asciicast.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	var out eventStream
+	if websocket {
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		defer ws.conn.Close()
+		out = &wsStream{ws: ws}
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		out = &sseStream{w: w, flusher: flusher, ctx: r.Context()}
+	}
+	replay(reader, speed, from, out)
+}
+
+// eventStream is a connection a recording is replayed to
+type eventStream interface {
+	header(data []byte) error
+	event(data []byte) error
+	fail(err error)
+	end()
+	done() <-chan struct{} // closed when the client goes away
+}
+
+// replay sends the header and events of a recording to out with their
+// original timing, scaled by speed and starting at from
+func replay(reader *asciicast.Reader, speed, from float64, out eventStream) {
+	headerBytes, err := json.Marshal(reader.Header)
+	if err != nil {
+		out.fail(err)
+		out.end()
+		return
+	}
+	if out.header(headerBytes) != nil {
+		return
+	}
+
+	start := time.Now()
+	for {
+		event, err := reader.ReadEvent()
+		if err != nil {
+			if err != io.EOF {
+				out.fail(err)
+			}
+			break
+		}
+
+		// Wait until the event is due, relative to the start of the stream
+		due := start.Add(time.Duration((event.Time - from) / speed * float64(time.Second)))
+		if wait := time.Until(due); wait > 0 {
+			select {
+			case <-out.done():
+				return
+			case <-time.After(wait):
+			}
+		}
+
+		eventBytes, err := json.Marshal([]interface{}{event.Time, event.Type, event.Value()})
+		if err != nil {
+			continue
+		}
+		if out.event(eventBytes) != nil {
+			return
+		}
+	}
+	out.end()
+}
+
+// sseStream sends a replay as server-sent events
+type sseStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	ctx     context.Context
+}
+
+func (s *sseStream) send(kind string, data []byte) error {
+	if kind != "" {
+		fmt.Fprintf(s.w, "event: %s\n", kind)
+	}
+	_, err := fmt.Fprintf(s.w, "data: %s\n\n", data)
+	s.flusher.Flush()
+	return err
+}
+
+func (s *sseStream) header(data []byte) error { return s.send("header", data) }
+func (s *sseStream) event(data []byte) error  { return s.send("", data) }
+func (s *sseStream) fail(err error)           { s.send("error", []byte(err.Error())) }
+func (s *sseStream) end()                     { s.send("end", []byte("{}")) }
+func (s *sseStream) done() <-chan struct{}    { return s.ctx.Done() }
+
+// wsStream sends a replay over a WebSocket. A failed replay is closed
+// with an internal error status and the error as the reason.
+type wsStream struct {
+	ws  *wsConn
+	err error
+}
+
+func (s *wsStream) header(data []byte) error { return s.ws.WriteText(data) }
+func (s *wsStream) event(data []byte) error  { return s.ws.WriteText(data) }
+func (s *wsStream) fail(err error)           { s.err = err }
+func (s *wsStream) done() <-chan struct{}    { return s.ws.Done() }
+
+func (s *wsStream) end() {
+	if s.err != nil {
+		s.ws.Close(closeInternal, s.err.Error())
+		return
+	}
+	s.ws.Close(closeNormal, "end")
+}
+
+// handlePlay serves a minimal web player for a recording. A "#t=<seconds>"
+// fragment starts playback at that offset.
+func (s *Server) handlePlay(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/play/")
+	if _, err := s.resolve(name); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	playPage.Execute(w, struct{ Name, Stream string }{name, "/stream/" + url.PathEscape(name)})
+}
+
+// playPage is the web player. html/template escapes the values for their
+// context, including inside the script.
+var playPage = template.Must(template.New("play").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>body{background:#111;color:#ddd}pre{font-family:monospace;white-space:pre-wrap}</style>
+</head>
+<body>
+<pre id="screen"></pre>
+<script>
+var stream = {{.Stream}};
+var m = location.hash.match(/t=([0-9.]+)/);
+if (m) { stream += "?from=" + m[1]; }
+var screen = document.getElementById("screen");
+var ansi = /\x1b\[[0-9;?]*[ -\/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-_]|\r/g;
+var source = new EventSource(stream);
+source.onmessage = function(e) {
+  var ev = JSON.parse(e.data);
+  if (ev[1] === "o") {
+    screen.textContent += ev[2].replace(ansi, "");
+    window.scrollTo(0, document.body.scrollHeight);
+  }
+};
+source.addEventListener("end", function() { source.close(); });
+</script>
+</body>
+</html>
+`))
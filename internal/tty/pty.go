@@ -0,0 +1,66 @@
+package tty
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+)
+
+// PTY is a pseudo-terminal that no process has been started in, for
+// feeding output to whatever opens its device
+type PTY struct {
+	Master *os.File // written to; supports deadlines
+	Slave  *os.File // kept open so writes don't fail before a reader opens it
+}
+
+// OpenPTY allocates a pseudo-terminal with its slave side in raw mode, so
+// bytes written to the master are read from the device unchanged and
+// aren't echoed back
+func OpenPTY() (*PTY, error) {
+	master, slave, err := pty.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pty: %w", err)
+	}
+	if _, err := RawMode(int(slave.Fd())); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, fmt.Errorf("failed to set pty mode: %w", err)
+	}
+
+	// pty.Open leaves the master blocking; a non-blocking copy goes through
+	// the runtime poller, so a write waiting for a slow reader can be cut
+	// short with a deadline
+	fd, err := unix.Dup(int(master.Fd()))
+	master.Close()
+	if err == nil {
+		err = unix.SetNonblock(fd, true)
+	}
+	if err != nil {
+		slave.Close()
+		return nil, fmt.Errorf("failed to open pty: %w", err)
+	}
+	return &PTY{Master: os.NewFile(uintptr(fd), "/dev/ptmx"), Slave: slave}, nil
+}
+
+// Name returns the path of the device to read from
+func (p *PTY) Name() string {
+	return p.Slave.Name()
+}
+
+// SetSize sets the pseudo-terminal's window size
+func (p *PTY) SetSize(cols, rows int) error {
+	return pty.Setsize(p.Slave, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+}
+
+// Pending returns the number of bytes written that haven't been read yet
+func (p *PTY) Pending() (int, error) {
+	return unix.IoctlGetInt(int(p.Slave.Fd()), unix.TIOCINQ)
+}
+
+// Close closes both sides of the pseudo-terminal
+func (p *PTY) Close() error {
+	p.Master.Close()
+	return p.Slave.Close()
+}
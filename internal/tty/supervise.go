@@ -0,0 +1,108 @@
+package tty
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// SupervisedEnv is set in the environment of a supervised child process
+const SupervisedEnv = "GOASCIINEMA_SUPERVISED"
+
+// NoSuperviseEnv disables supervision when set to a non-empty value
+const NoSuperviseEnv = "GOASCIINEMA_NO_SUPERVISE"
+
+// ResetSequence leaves the alternate screen, shows the cursor, resets
+// attributes, and turns off mouse reporting and bracketed paste.
+const ResetSequence = "\x1b[?1049l\x1b[?25h\x1b[0m\x1b[?1000l\x1b[?1002l\x1b[?1003l\x1b[?1006l\x1b[?2004l"
+
+// ShouldSupervise reports whether the current process should run itself
+// under a supervisor: stdin is a terminal and we are not already supervised.
+// A supervised process drops SupervisedEnv from its environment here, so
+// it doesn't reach processes it starts, like a recorded shell running
+// goasciinema again.
+func ShouldSupervise() bool {
+	if os.Getenv(SupervisedEnv) != "" {
+		os.Unsetenv(SupervisedEnv)
+		return false
+	}
+	return os.Getenv(NoSuperviseEnv) == "" && IsTerminal(GetStdinFd())
+}
+
+// panicExitCode is the exit status of a Go program that panics or hits a
+// fatal runtime error
+const panicExitCode = 2
+
+// Supervise re-runs the current executable with the same arguments and
+// waits for it. If the child crashed, by panicking or being killed while
+// the terminal may have been in raw mode, the terminal state captured
+// before starting it is restored. Other exit codes, like those rec passes
+// on from the recorded command, leave the terminal alone. It returns the
+// child's exit code.
+func Supervise() (int, error) {
+	fd := GetStdinFd()
+	state, err := term.GetState(fd)
+	if err != nil {
+		return 0, err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	child := exec.Command(exe, os.Args[1:]...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	child.Env = append(os.Environ(), SupervisedEnv+"=1")
+
+	// The child shares our terminal and receives keyboard signals itself;
+	// forward the ones that are only sent to us.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGHUP)
+	defer func() {
+		signal.Stop(sigCh)
+		close(sigCh)
+	}()
+
+	if err := child.Start(); err != nil {
+		return 0, err
+	}
+
+	go func() {
+		for sig := range sigCh {
+			if sig == syscall.SIGTERM || sig == syscall.SIGHUP {
+				child.Process.Signal(sig)
+			}
+		}
+	}()
+
+	child.Wait()
+
+	code := child.ProcessState.ExitCode()
+	status, _ := child.ProcessState.Sys().(syscall.WaitStatus)
+	if status.Signaled() || code == panicExitCode {
+		term.Restore(fd, state)
+		os.Stdout.WriteString(ResetSequence)
+		if code < 0 {
+			code = 1
+		}
+	}
+
+	return code, nil
+}
+
+// Fix restores a terminal left in raw mode by another process
+func Fix() error {
+	stty := exec.Command("stty", "sane")
+	stty.Stdin = os.Stdin
+	stty.Stderr = os.Stderr
+	err := stty.Run()
+
+	os.Stdout.WriteString(ResetSequence)
+	return err
+}
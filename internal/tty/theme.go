@@ -0,0 +1,108 @@
+package tty
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Colors are a terminal's default colors, as #rrggbb strings
+type Colors struct {
+	Foreground string
+	Background string
+	Palette    []string // the 16 ANSI colors, or none if not reported
+}
+
+// paletteSize is how many palette entries are queried: the 8 normal and
+// 8 bright ANSI colors
+const paletteSize = 16
+
+// colorReply matches an OSC 4/10/11 color report, terminated by BEL or ST
+var colorReply = regexp.MustCompile(`\x1b\](4;(\d+)|10|11);rgb:([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})(?:\x07|\x1b\\)`)
+
+// daReply matches the primary device attributes report, which every
+// terminal sends and which is queried last, so it marks the end of the
+// replies
+var daReply = regexp.MustCompile(`\x1b\[\?[0-9;]*c`)
+
+// QueryColors asks the terminal for its foreground, background and
+// palette colors with OSC 10, 11 and 4, writing the queries to out and
+// reading the replies from in, which must already be in raw mode. Colors
+// the terminal doesn't report are left empty; an error is returned only
+// if it doesn't answer at all within timeout.
+func QueryColors(in, out int, timeout time.Duration) (Colors, error) {
+	var query strings.Builder
+	query.WriteString("\x1b]10;?\x07\x1b]11;?\x07")
+	for i := 0; i < paletteSize; i++ {
+		fmt.Fprintf(&query, "\x1b]4;%d;?\x07", i)
+	}
+	query.WriteString("\x1b[c")
+	if _, err := unix.Write(out, []byte(query.String())); err != nil {
+		return Colors{}, err
+	}
+
+	var replies []byte
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 1024)
+	for !daReply.Match(replies) {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return Colors{}, fmt.Errorf("terminal did not answer color queries")
+		}
+		fds := []unix.PollFd{{Fd: int32(in), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, int(remaining.Milliseconds())+1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return Colors{}, err
+		}
+		if n == 0 {
+			continue
+		}
+		n, err = unix.Read(in, buf)
+		if err != nil {
+			return Colors{}, err
+		}
+		replies = append(replies, buf[:n]...)
+	}
+
+	return parseColorReplies(replies), nil
+}
+
+// parseColorReplies collects the colors reported in replies
+func parseColorReplies(replies []byte) Colors {
+	var colors Colors
+	palette := make([]string, paletteSize)
+	reported := 0
+	for _, m := range colorReply.FindAllSubmatch(replies, -1) {
+		color := "#" + scaleChannel(m[3]) + scaleChannel(m[4]) + scaleChannel(m[5])
+		switch {
+		case bytes.Equal(m[1], []byte("10")):
+			colors.Foreground = color
+		case bytes.Equal(m[1], []byte("11")):
+			colors.Background = color
+		default:
+			if i, err := strconv.Atoi(string(m[2])); err == nil && i < paletteSize && palette[i] == "" {
+				palette[i] = color
+				reported++
+			}
+		}
+	}
+	if reported == paletteSize {
+		colors.Palette = palette
+	}
+	return colors
+}
+
+// scaleChannel converts a 1-4 digit hex color channel to 2 digits
+func scaleChannel(hex []byte) string {
+	v, _ := strconv.ParseUint(string(hex), 16, 16)
+	max := uint64(1)<<(4*len(hex)) - 1
+	return fmt.Sprintf("%02x", (v*255+max/2)/max)
+}
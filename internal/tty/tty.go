@@ -2,6 +2,8 @@ package tty
 
 import (
 	"os"
+	"strings"
+	"time"
 
 	"golang.org/x/term"
 )
@@ -28,6 +30,25 @@ func IsTerminal(fd int) bool {
 	return term.IsTerminal(fd)
 }
 
+// OpenInput returns the terminal on stdin opened again, as a file whose
+// reads can be cut short with SetReadDeadline, and a function that stops
+// reading and closes it. A goroutine reading os.Stdin itself can't be
+// stopped, and would swallow whatever is typed next. Stdin itself is
+// returned when it is not a terminal.
+func OpenInput() (*os.File, func()) {
+	if !IsTerminal(GetStdinFd()) {
+		return os.Stdin, func() {}
+	}
+	file, err := os.Open("/dev/tty")
+	if err != nil {
+		return os.Stdin, func() {}
+	}
+	return file, func() {
+		file.SetReadDeadline(time.Now())
+		file.Close()
+	}
+}
+
 // GetStdinFd returns stdin file descriptor
 func GetStdinFd() int {
 	return int(os.Stdin.Fd())
@@ -37,3 +58,13 @@ func GetStdinFd() int {
 func GetStdoutFd() int {
 	return int(os.Stdout.Fd())
 }
+
+// SupportsTruecolor reports whether the environment advertises 24-bit color
+func SupportsTruecolor() bool {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return true
+	}
+	term := os.Getenv("TERM")
+	return strings.HasSuffix(term, "-direct") || strings.Contains(term, "truecolor")
+}
@@ -0,0 +1,80 @@
+package vt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SGR returns the escape sequence that selects this rendition, starting
+// from a reset
+func (a Attr) SGR() string {
+	params := []string{"0"}
+	if a.Bold {
+		params = append(params, "1")
+	}
+	if a.Faint {
+		params = append(params, "2")
+	}
+	if a.Italic {
+		params = append(params, "3")
+	}
+	if a.Underline {
+		params = append(params, "4")
+	}
+	if a.Blink {
+		params = append(params, "5")
+	}
+	if a.Inverse {
+		params = append(params, "7")
+	}
+	if p := colorParams(a.FG, 30, 90, 38); p != "" {
+		params = append(params, p)
+	}
+	if p := colorParams(a.BG, 40, 100, 48); p != "" {
+		params = append(params, p)
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// colorParams returns the SGR parameters for a color, using the basic,
+// bright, or extended form as appropriate
+func colorParams(c Color, base, bright, extended int) string {
+	switch {
+	case c == DefaultColor:
+		return ""
+	case c.IsRGB():
+		r, g, b := c.Components()
+		return fmt.Sprintf("%d;2;%d;%d;%d", extended, r, g, b)
+	case c < 8:
+		return fmt.Sprintf("%d", base+int(c))
+	case c < 16:
+		return fmt.Sprintf("%d", bright+int(c)-8)
+	default:
+		return fmt.Sprintf("%d;5;%d", extended, c)
+	}
+}
+
+// ANSI renders the first width cells of the line with escape sequences
+// for their attributes. The result ends with the rendition reset.
+func (l Line) ANSI(width int) string {
+	var b strings.Builder
+	current := defaultAttr
+	for i, c := range l.Cells {
+		if i >= width {
+			break
+		}
+		if c.Attr != current {
+			b.WriteString(c.Attr.SGR())
+			current = c.Attr
+		}
+		if c.Char == 0 {
+			b.WriteRune(' ')
+		} else {
+			b.WriteRune(c.Char)
+		}
+	}
+	if current != defaultAttr {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
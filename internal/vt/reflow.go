@@ -0,0 +1,61 @@
+package vt
+
+import (
+	"strings"
+)
+
+// Transcript returns the scrollback followed by the visible screen, with
+// trailing blank rows removed
+func (t *Terminal) Transcript() []Line {
+	lines := append(append([]Line{}, t.scrollback...), t.lines...)
+	for len(lines) > 0 && lines[len(lines)-1].Text() == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// LogicalLines joins rows that were soft-wrapped at the right margin back
+// into the lines the program originally printed
+func LogicalLines(lines []Line) []string {
+	var out []string
+	var current strings.Builder
+	for _, l := range lines {
+		if l.Wrapped {
+			// A soft-wrapped row is full width; keep its trailing spaces
+			for _, c := range l.Cells {
+				current.WriteRune(c.Char)
+			}
+			continue
+		}
+		current.WriteString(l.Text())
+		out = append(out, current.String())
+		current.Reset()
+	}
+	if current.Len() > 0 {
+		out = append(out, strings.TrimRight(current.String(), " "))
+	}
+	return out
+}
+
+// Reflow re-wraps logical lines to the given width. A width of zero or
+// less leaves lines unwrapped.
+func Reflow(lines []string, width int) []string {
+	if width <= 0 {
+		return lines
+	}
+
+	var out []string
+	for _, line := range lines {
+		runes := []rune(line)
+		if len(runes) <= width {
+			out = append(out, line)
+			continue
+		}
+		for len(runes) > width {
+			out = append(out, string(runes[:width]))
+			runes = runes[width:]
+		}
+		out = append(out, strings.TrimRight(string(runes), " "))
+	}
+	return out
+}
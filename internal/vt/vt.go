@@ -0,0 +1,778 @@
+package vt
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Color is a terminal color. Indexed colors (0-255) are stored as is;
+// DefaultColor means the terminal default and RGB colors have RGBFlag set.
+type Color uint32
+
+const (
+	DefaultColor Color = 1 << 31
+	RGBFlag      Color = 1 << 30
+)
+
+// RGB returns a truecolor Color
+func RGB(r, g, b uint8) Color {
+	return RGBFlag | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+// IsRGB reports whether c is a truecolor value
+func (c Color) IsRGB() bool {
+	return c&RGBFlag != 0 && c != DefaultColor
+}
+
+// Components returns the red, green and blue parts of an RGB color
+func (c Color) Components() (r, g, b uint8) {
+	return uint8(c >> 16), uint8(c >> 8), uint8(c)
+}
+
+// Attr holds the rendition of a cell
+type Attr struct {
+	FG        Color
+	BG        Color
+	Bold      bool
+	Faint     bool
+	Italic    bool
+	Underline bool
+	Blink     bool
+	Inverse   bool
+}
+
+var defaultAttr = Attr{FG: DefaultColor, BG: DefaultColor}
+
+// Cell is a single character position on the screen
+type Cell struct {
+	Char rune
+	Attr Attr
+}
+
+// Line is a row of cells. Wrapped is set when the text continues on the
+// next row because it reached the right margin (a soft wrap).
+type Line struct {
+	Cells   []Cell
+	Wrapped bool
+}
+
+// Text returns the line's characters with trailing spaces removed
+func (l Line) Text() string {
+	var b strings.Builder
+	for _, c := range l.Cells {
+		if c.Char == 0 {
+			b.WriteRune(' ')
+		} else {
+			b.WriteRune(c.Char)
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+func newLine(width int) Line {
+	cells := make([]Cell, width)
+	for i := range cells {
+		cells[i] = Cell{Char: ' ', Attr: defaultAttr}
+	}
+	return Line{Cells: cells}
+}
+
+// parser states
+const (
+	stateGround = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCSI
+	stateOSC
+	stateOSCEscape
+	stateString // DCS, SOS, PM, APC: ignored until ST
+	stateStringEscape
+)
+
+// Terminal is a minimal VT100/xterm emulator that keeps the screen and
+// scrollback, enough to render recordings as text or images.
+type Terminal struct {
+	width, height int
+
+	lines      []Line // visible screen
+	scrollback []Line
+	altLines   []Line // primary screen saved while the alternate screen is active
+	altScreen  bool
+
+	// ScrollbackLimit caps the number of scrollback lines kept (0 = unlimited)
+	ScrollbackLimit int
+
+	x, y          int
+	wrapPending   bool
+	attr          Attr
+	savedX        int
+	savedY        int
+	savedAttr     Attr
+	scrollTop     int
+	scrollBottom  int
+	autoWrap      bool
+	cursorVisible bool
+
+	state   int
+	params  []byte
+	partial []byte // incomplete UTF-8 sequence from the previous Write
+}
+
+// New creates a terminal of the given size
+func New(width, height int) *Terminal {
+	if width <= 0 {
+		width = 80
+	}
+	if height <= 0 {
+		height = 24
+	}
+	t := &Terminal{width: width, height: height}
+	t.reset()
+	return t
+}
+
+func (t *Terminal) reset() {
+	t.lines = make([]Line, t.height)
+	for i := range t.lines {
+		t.lines[i] = newLine(t.width)
+	}
+	t.altLines = nil
+	t.altScreen = false
+	t.x, t.y = 0, 0
+	t.wrapPending = false
+	t.attr = defaultAttr
+	t.savedAttr = defaultAttr
+	t.scrollTop = 0
+	t.scrollBottom = t.height - 1
+	t.autoWrap = true
+	t.cursorVisible = true
+	t.state = stateGround
+}
+
+// Clone returns a copy of the terminal that can be written to without
+// affecting t
+func (t *Terminal) Clone() *Terminal {
+	c := *t
+	c.lines = cloneLines(t.lines)
+	c.altLines = cloneLines(t.altLines)
+	// Scrolled-off lines are never modified, only dropped or appended to,
+	// so they can be shared as long as appends don't
+	c.scrollback = t.scrollback[:len(t.scrollback):len(t.scrollback)]
+	c.params = append([]byte(nil), t.params...)
+	c.partial = append([]byte(nil), t.partial...)
+	return &c
+}
+
+func cloneLines(lines []Line) []Line {
+	if lines == nil {
+		return nil
+	}
+	out := make([]Line, len(lines))
+	for i, l := range lines {
+		out[i] = Line{Cells: append([]Cell(nil), l.Cells...), Wrapped: l.Wrapped}
+	}
+	return out
+}
+
+// Size returns the terminal dimensions
+func (t *Terminal) Size() (width, height int) {
+	return t.width, t.height
+}
+
+// Cursor returns the cursor position and whether it is visible
+func (t *Terminal) Cursor() (x, y int, visible bool) {
+	return t.x, t.y, t.cursorVisible
+}
+
+// Lines returns the visible screen
+func (t *Terminal) Lines() []Line {
+	return t.lines
+}
+
+// Scrollback returns lines scrolled off the top of the primary screen
+func (t *Terminal) Scrollback() []Line {
+	return t.scrollback
+}
+
+// ScreenText returns the visible screen as text, one string per row
+func (t *Terminal) ScreenText() []string {
+	out := make([]string, len(t.lines))
+	for i, l := range t.lines {
+		out[i] = l.Text()
+	}
+	return out
+}
+
+// Resize changes the terminal size, keeping content anchored at the top
+// left. Rows that no longer fit above the cursor move to scrollback.
+func (t *Terminal) Resize(width, height int) {
+	if width <= 0 || height <= 0 || (width == t.width && height == t.height) {
+		return
+	}
+
+	for t.y >= height {
+		t.pushScrollback(t.lines[0])
+		t.lines = t.lines[1:]
+		t.y--
+	}
+	for len(t.lines) < height {
+		t.lines = append(t.lines, newLine(width))
+	}
+	t.lines = t.lines[:height]
+
+	for i := range t.lines {
+		t.lines[i].Cells = resizeCells(t.lines[i].Cells, width)
+	}
+
+	t.width, t.height = width, height
+	t.scrollTop, t.scrollBottom = 0, height-1
+	if t.x >= width {
+		t.x = width - 1
+	}
+	t.wrapPending = false
+}
+
+func resizeCells(cells []Cell, width int) []Cell {
+	if len(cells) >= width {
+		return cells[:width]
+	}
+	for len(cells) < width {
+		cells = append(cells, Cell{Char: ' ', Attr: defaultAttr})
+	}
+	return cells
+}
+
+// Write feeds terminal output to the emulator
+func (t *Terminal) Write(data string) {
+	if len(t.partial) > 0 {
+		data = string(t.partial) + data
+		t.partial = nil
+	}
+
+	for i := 0; i < len(data); {
+		b := data[i]
+		if b < utf8.RuneSelf || t.state != stateGround {
+			t.feedByte(b)
+			i++
+			continue
+		}
+
+		if !utf8.FullRuneInString(data[i:]) {
+			t.partial = []byte(data[i:])
+			return
+		}
+		r, size := utf8.DecodeRuneInString(data[i:])
+		t.put(r)
+		i += size
+	}
+}
+
+func (t *Terminal) feedByte(b byte) {
+	switch t.state {
+	case stateGround:
+		t.ground(b)
+	case stateEscape:
+		t.escape(b)
+	case stateEscapeIntermediate:
+		// Charset designation and similar: consume the final byte
+		if b >= 0x30 {
+			t.state = stateGround
+		}
+	case stateCSI:
+		if b >= 0x40 && b <= 0x7e {
+			t.csi(b)
+			t.state = stateGround
+		} else if b == 0x1b {
+			t.state = stateEscape
+		} else {
+			t.params = append(t.params, b)
+		}
+	case stateOSC:
+		if b == 0x07 {
+			t.state = stateGround
+		} else if b == 0x1b {
+			t.state = stateOSCEscape
+		}
+	case stateOSCEscape, stateStringEscape:
+		t.state = stateGround
+		if b != '\\' {
+			t.escape(b)
+		}
+	case stateString:
+		if b == 0x1b {
+			t.state = stateStringEscape
+		}
+	}
+}
+
+func (t *Terminal) ground(b byte) {
+	switch b {
+	case 0x1b:
+		t.state = stateEscape
+	case '\r':
+		t.x = 0
+		t.wrapPending = false
+	case '\n', 0x0b, 0x0c:
+		t.lineFeed()
+	case '\b':
+		if t.x > 0 {
+			t.x--
+		}
+		t.wrapPending = false
+	case '\t':
+		next := (t.x/8 + 1) * 8
+		if next >= t.width {
+			next = t.width - 1
+		}
+		t.x = next
+	case 0x07, 0x00, 0x0e, 0x0f:
+		// BEL, NUL, SO, SI: no visible effect
+	default:
+		if b >= 0x20 && b != 0x7f {
+			t.put(rune(b))
+		}
+	}
+}
+
+func (t *Terminal) escape(b byte) {
+	t.state = stateGround
+	switch b {
+	case '[':
+		t.params = t.params[:0]
+		t.state = stateCSI
+	case ']':
+		t.state = stateOSC
+	case 'P', 'X', '^', '_':
+		t.state = stateString
+	case '(', ')', '*', '+', '#', '%':
+		t.state = stateEscapeIntermediate
+	case '7':
+		t.saveCursor()
+	case '8':
+		t.restoreCursor()
+	case 'D':
+		t.lineFeed()
+	case 'E':
+		t.x = 0
+		t.lineFeed()
+	case 'M':
+		t.reverseIndex()
+	case 'c':
+		t.reset()
+	}
+}
+
+func (t *Terminal) put(r rune) {
+	if t.wrapPending {
+		if t.autoWrap {
+			t.lines[t.y].Wrapped = true
+			t.x = 0
+			t.lineFeed()
+		}
+		t.wrapPending = false
+	}
+
+	t.lines[t.y].Cells[t.x] = Cell{Char: r, Attr: t.attr}
+	if t.x == t.width-1 {
+		t.wrapPending = true
+	} else {
+		t.x++
+	}
+}
+
+func (t *Terminal) lineFeed() {
+	t.wrapPending = false
+	if t.y == t.scrollBottom {
+		t.scrollUp(1)
+	} else if t.y < t.height-1 {
+		t.y++
+	}
+}
+
+func (t *Terminal) reverseIndex() {
+	t.wrapPending = false
+	if t.y == t.scrollTop {
+		t.scrollDown(1)
+	} else if t.y > 0 {
+		t.y--
+	}
+}
+
+func (t *Terminal) pushScrollback(l Line) {
+	if t.altScreen {
+		return
+	}
+	t.scrollback = append(t.scrollback, l)
+	if t.ScrollbackLimit > 0 && len(t.scrollback) > t.ScrollbackLimit {
+		t.scrollback = t.scrollback[len(t.scrollback)-t.ScrollbackLimit:]
+	}
+}
+
+// scrollUp scrolls the scroll region up by n lines
+func (t *Terminal) scrollUp(n int) {
+	for i := 0; i < n; i++ {
+		if t.scrollTop == 0 {
+			t.pushScrollback(t.lines[0])
+		}
+		copy(t.lines[t.scrollTop:t.scrollBottom], t.lines[t.scrollTop+1:t.scrollBottom+1])
+		t.lines[t.scrollBottom] = newLine(t.width)
+	}
+}
+
+// scrollDown scrolls the scroll region down by n lines
+func (t *Terminal) scrollDown(n int) {
+	for i := 0; i < n; i++ {
+		copy(t.lines[t.scrollTop+1:t.scrollBottom+1], t.lines[t.scrollTop:t.scrollBottom])
+		t.lines[t.scrollTop] = newLine(t.width)
+	}
+}
+
+func (t *Terminal) saveCursor() {
+	t.savedX, t.savedY, t.savedAttr = t.x, t.y, t.attr
+}
+
+func (t *Terminal) restoreCursor() {
+	t.x, t.y, t.attr = t.savedX, t.savedY, t.savedAttr
+	t.clampCursor()
+	t.wrapPending = false
+}
+
+func (t *Terminal) clampCursor() {
+	if t.x < 0 {
+		t.x = 0
+	}
+	if t.x >= t.width {
+		t.x = t.width - 1
+	}
+	if t.y < 0 {
+		t.y = 0
+	}
+	if t.y >= t.height {
+		t.y = t.height - 1
+	}
+}
+
+// parseParams splits CSI parameters; a private marker ('?', '>', ...) is
+// returned separately
+func parseParams(raw []byte) (private byte, params []int) {
+	s := string(raw)
+	if len(s) > 0 && (s[0] == '?' || s[0] == '>' || s[0] == '<' || s[0] == '=') {
+		private = s[0]
+		s = s[1:]
+	}
+	// Drop intermediate bytes
+	s = strings.TrimRight(s, " !\"#$%&'()*+,-./")
+	if s == "" {
+		return private, nil
+	}
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ':' }) {
+		n, _ := strconv.Atoi(part)
+		params = append(params, n)
+	}
+	return private, params
+}
+
+func param(params []int, i, def int) int {
+	if i < len(params) && params[i] > 0 {
+		return params[i]
+	}
+	return def
+}
+
+func (t *Terminal) csi(final byte) {
+	private, params := parseParams(t.params)
+
+	if private == '?' {
+		if final == 'h' || final == 'l' {
+			t.setPrivateModes(params, final == 'h')
+		}
+		return
+	}
+	if private != 0 {
+		return
+	}
+
+	switch final {
+	case 'A':
+		top := 0
+		if t.y >= t.scrollTop {
+			top = t.scrollTop
+		}
+		t.y -= param(params, 0, 1)
+		if t.y < top {
+			t.y = top
+		}
+	case 'B', 'e':
+		bottom := t.height - 1
+		if t.y <= t.scrollBottom {
+			bottom = t.scrollBottom
+		}
+		t.y += param(params, 0, 1)
+		if t.y > bottom {
+			t.y = bottom
+		}
+	case 'C', 'a':
+		t.x += param(params, 0, 1)
+	case 'D':
+		t.x -= param(params, 0, 1)
+	case 'E':
+		t.y += param(params, 0, 1)
+		t.x = 0
+	case 'F':
+		t.y -= param(params, 0, 1)
+		t.x = 0
+	case 'G', '`':
+		t.x = param(params, 0, 1) - 1
+	case 'H', 'f':
+		t.y = param(params, 0, 1) - 1
+		t.x = param(params, 1, 1) - 1
+	case 'd':
+		t.y = param(params, 0, 1) - 1
+	case 'J':
+		t.eraseDisplay(param(params, 0, 0))
+	case 'K':
+		t.eraseLine(param(params, 0, 0))
+	case 'L':
+		t.insertLines(param(params, 0, 1))
+	case 'M':
+		t.deleteLines(param(params, 0, 1))
+	case 'P':
+		t.deleteChars(param(params, 0, 1))
+	case '@':
+		t.insertChars(param(params, 0, 1))
+	case 'X':
+		t.eraseChars(param(params, 0, 1))
+	case 'S':
+		t.scrollUp(param(params, 0, 1))
+	case 'T':
+		t.scrollDown(param(params, 0, 1))
+	case 'r':
+		top := param(params, 0, 1) - 1
+		bottom := param(params, 1, t.height) - 1
+		if top < bottom && bottom < t.height {
+			t.scrollTop, t.scrollBottom = top, bottom
+			t.x, t.y = 0, 0
+		}
+	case 's':
+		t.saveCursor()
+	case 'u':
+		t.restoreCursor()
+	case 'm':
+		t.sgr(params)
+	}
+
+	t.clampCursor()
+	t.wrapPending = false
+}
+
+func (t *Terminal) setPrivateModes(params []int, on bool) {
+	for _, p := range params {
+		switch p {
+		case 7:
+			t.autoWrap = on
+		case 25:
+			t.cursorVisible = on
+		case 47, 1047, 1049:
+			if p == 1049 {
+				if on {
+					t.saveCursor()
+				}
+			}
+			t.switchScreen(on)
+			if p == 1049 && !on {
+				t.restoreCursor()
+			}
+		}
+	}
+}
+
+func (t *Terminal) switchScreen(alt bool) {
+	if alt == t.altScreen {
+		return
+	}
+	if alt {
+		t.altLines = t.lines
+		t.lines = make([]Line, t.height)
+		for i := range t.lines {
+			t.lines[i] = newLine(t.width)
+		}
+	} else {
+		t.lines = t.altLines
+		t.altLines = nil
+		// The primary screen may predate a resize
+		for len(t.lines) < t.height {
+			t.lines = append(t.lines, newLine(t.width))
+		}
+		t.lines = t.lines[:t.height]
+		for i := range t.lines {
+			t.lines[i].Cells = resizeCells(t.lines[i].Cells, t.width)
+		}
+	}
+	t.altScreen = alt
+}
+
+func (t *Terminal) blank() Cell {
+	return Cell{Char: ' ', Attr: Attr{FG: DefaultColor, BG: t.attr.BG}}
+}
+
+func (t *Terminal) clearCells(y, from, to int) {
+	cells := t.lines[y].Cells
+	for x := from; x < to && x < len(cells); x++ {
+		cells[x] = t.blank()
+	}
+}
+
+func (t *Terminal) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		t.clearCells(t.y, t.x, t.width)
+		for y := t.y + 1; y < t.height; y++ {
+			t.clearCells(y, 0, t.width)
+			t.lines[y].Wrapped = false
+		}
+	case 1:
+		for y := 0; y < t.y; y++ {
+			t.clearCells(y, 0, t.width)
+			t.lines[y].Wrapped = false
+		}
+		t.clearCells(t.y, 0, t.x+1)
+	case 2, 3:
+		for y := 0; y < t.height; y++ {
+			t.clearCells(y, 0, t.width)
+			t.lines[y].Wrapped = false
+		}
+		if mode == 3 {
+			t.scrollback = nil
+		}
+	}
+}
+
+func (t *Terminal) eraseLine(mode int) {
+	switch mode {
+	case 0:
+		t.clearCells(t.y, t.x, t.width)
+		t.lines[t.y].Wrapped = false
+	case 1:
+		t.clearCells(t.y, 0, t.x+1)
+	case 2:
+		t.clearCells(t.y, 0, t.width)
+		t.lines[t.y].Wrapped = false
+	}
+}
+
+func (t *Terminal) insertLines(n int) {
+	if t.y < t.scrollTop || t.y > t.scrollBottom {
+		return
+	}
+	for i := 0; i < n; i++ {
+		copy(t.lines[t.y+1:t.scrollBottom+1], t.lines[t.y:t.scrollBottom])
+		t.lines[t.y] = newLine(t.width)
+	}
+	t.x = 0
+}
+
+func (t *Terminal) deleteLines(n int) {
+	if t.y < t.scrollTop || t.y > t.scrollBottom {
+		return
+	}
+	for i := 0; i < n; i++ {
+		copy(t.lines[t.y:t.scrollBottom], t.lines[t.y+1:t.scrollBottom+1])
+		t.lines[t.scrollBottom] = newLine(t.width)
+	}
+	t.x = 0
+}
+
+func (t *Terminal) deleteChars(n int) {
+	cells := t.lines[t.y].Cells
+	if n > t.width-t.x {
+		n = t.width - t.x
+	}
+	copy(cells[t.x:], cells[t.x+n:])
+	for x := t.width - n; x < t.width; x++ {
+		cells[x] = t.blank()
+	}
+}
+
+func (t *Terminal) insertChars(n int) {
+	cells := t.lines[t.y].Cells
+	if n > t.width-t.x {
+		n = t.width - t.x
+	}
+	copy(cells[t.x+n:], cells[t.x:t.width-n])
+	for x := t.x; x < t.x+n; x++ {
+		cells[x] = t.blank()
+	}
+}
+
+func (t *Terminal) eraseChars(n int) {
+	t.clearCells(t.y, t.x, t.x+n)
+}
+
+func (t *Terminal) sgr(params []int) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == 0:
+			t.attr = defaultAttr
+		case p == 1:
+			t.attr.Bold = true
+		case p == 2:
+			t.attr.Faint = true
+		case p == 3:
+			t.attr.Italic = true
+		case p == 4:
+			t.attr.Underline = true
+		case p == 5:
+			t.attr.Blink = true
+		case p == 7:
+			t.attr.Inverse = true
+		case p == 22:
+			t.attr.Bold, t.attr.Faint = false, false
+		case p == 23:
+			t.attr.Italic = false
+		case p == 24:
+			t.attr.Underline = false
+		case p == 25:
+			t.attr.Blink = false
+		case p == 27:
+			t.attr.Inverse = false
+		case p >= 30 && p <= 37:
+			t.attr.FG = Color(p - 30)
+		case p == 39:
+			t.attr.FG = DefaultColor
+		case p >= 40 && p <= 47:
+			t.attr.BG = Color(p - 40)
+		case p == 49:
+			t.attr.BG = DefaultColor
+		case p >= 90 && p <= 97:
+			t.attr.FG = Color(p - 90 + 8)
+		case p >= 100 && p <= 107:
+			t.attr.BG = Color(p - 100 + 8)
+		case p == 38 || p == 48:
+			c, consumed := extendedColor(params[i+1:])
+			i += consumed
+			if consumed > 0 {
+				if p == 38 {
+					t.attr.FG = c
+				} else {
+					t.attr.BG = c
+				}
+			}
+		}
+	}
+}
+
+// extendedColor parses "5;n" or "2;r;g;b" following SGR 38/48
+func extendedColor(params []int) (Color, int) {
+	if len(params) >= 2 && params[0] == 5 {
+		return Color(params[1] & 0xff), 2
+	}
+	if len(params) >= 4 && params[0] == 2 {
+		return RGB(uint8(params[1]), uint8(params[2]), uint8(params[3])), 4
+	}
+	return DefaultColor, len(params)
+}